@@ -0,0 +1,255 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/logger"
+	"github.com/gigcodes/launch-util/scheduler"
+	"github.com/gigcodes/launch-util/storage"
+)
+
+const (
+	// default lines of GET /api/logs
+	defaultLogLines = 100
+)
+
+var (
+	server     *http.Server
+	listen     string
+	serverLock = sync.Mutex{}
+)
+
+func init() {
+	config.OnConfigChange(func(in fsnotify.Event) {
+		serverLock.Lock()
+		changed := config.API.Enabled != (server != nil) || config.API.Listen != listen
+		serverLock.Unlock()
+
+		if changed {
+			if err := Restart(); err != nil {
+				logger.Tag("API").Error(err)
+			}
+		}
+	})
+}
+
+// Start the HTTP API server when `api.enabled` is true
+//
+//	GET  /api/models                    models with their schedules and jobs
+//	GET  /api/models/:name              a model with the schedule and the job
+//	POST /api/models/:name/perform      perform a model in background
+//	GET  /api/models/:name/backups      backups of a model, ?storage= to list one storage
+//	GET  /api/jobs                      running and last jobs
+//	GET  /api/logs                      tail of the log file, ?lines= default 100
+func Start() error {
+	logger := logger.Tag("API")
+
+	serverLock.Lock()
+	defer serverLock.Unlock()
+
+	if !config.API.Enabled {
+		return nil
+	}
+
+	listener, err := helper.Listen(config.API.Listen)
+	if err != nil {
+		return fmt.Errorf("api listen on %s failed: %w", config.API.Listen, err)
+	}
+	if len(config.API.Token) == 0 && !helper.IsUnixSocket(config.API.Listen) {
+		logger.Warnf("api.token is not set, anyone able to connect to %s can perform the models", config.API.Listen)
+	}
+
+	server = &http.Server{
+		Handler:           newHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	listen = config.API.Listen
+
+	go func(server *http.Server) {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("Serve failed: %v", err)
+		}
+	}(server)
+	logger.Info("Listening on", listen)
+
+	return nil
+}
+
+// Stop the server, wait the requests in progress for 5 seconds
+func Stop() {
+	serverLock.Lock()
+	defer serverLock.Unlock()
+
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Tag("API").Errorf("Shutdown failed: %v", err)
+	}
+	server = nil
+	listen = ""
+}
+
+func Restart() error {
+	logger.Tag("API").Info("Reloading...")
+	Stop()
+	return Start()
+}
+
+func newHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/models", handleModels)
+	mux.HandleFunc("/api/models/", handleModel)
+	mux.HandleFunc("/api/jobs", handleJobs)
+	mux.HandleFunc("/api/logs", handleLogs)
+
+	return authorize(mux)
+}
+
+// authorize the requests with the bearer token
+func authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := config.API.Token; len(token) > 0 {
+			expected := []byte("Bearer " + token)
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+				writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func handleModels(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, scheduler.Status())
+}
+
+// handleModel /api/models/:name, /api/models/:name/perform, /api/models/:name/backups
+func handleModel(w http.ResponseWriter, r *http.Request) {
+	name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/models/"), "/")
+
+	modelConfig := config.GetModelConfigByName(name)
+	if modelConfig == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("model %s not found", name))
+		return
+	}
+
+	switch action {
+	case "":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		for _, status := range scheduler.Status() {
+			if status.Name == name {
+				writeJSON(w, http.StatusOK, status)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, fmt.Errorf("model %s not found", name))
+	case "perform":
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		job, err := scheduler.Perform(name, "api")
+		if errors.Is(err, scheduler.ErrJobRunning) {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, job)
+	case "backups":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		backups, err := storage.List(*modelConfig, r.URL.Query().Get("storage"))
+		if err != nil && len(backups) == 0 {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		if backups == nil {
+			backups = []storage.Backup{}
+		}
+		writeJSON(w, http.StatusOK, backups)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s not found", r.URL.Path))
+	}
+}
+
+func handleJobs(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, scheduler.Jobs())
+}
+
+func handleLogs(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	lines := defaultLogLines
+	if value := r.URL.Query().Get("lines"); len(value) > 0 {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid lines %q", value))
+			return
+		}
+		lines = n
+	}
+
+	data, err := tail(config.LogFilePath, lines)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write(data)
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Tag("API").Errorf("Write response failed: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/longbridgeapp/assert"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/scheduler"
+)
+
+func request(method, target, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	if len(token) > 0 {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	newHandler().ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthorize(t *testing.T) {
+	config.API = config.APIConfig{Token: "secret"}
+	config.Models = []config.ModelConfig{{Name: "foo"}}
+	defer func() { config.API = config.APIConfig{} }()
+
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/api/models", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/api/models", "wrong").Code)
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/api/models", "secret").Code)
+}
+
+func TestModels(t *testing.T) {
+	config.Models = []config.ModelConfig{{Name: "foo"}, {Name: "bar"}}
+
+	w := request(http.MethodGet, "/api/models", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var status []scheduler.ModelStatus
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Len(t, status, 2)
+	assert.Equal(t, "bar", status[0].Name)
+	assert.Equal(t, "disabled", status[0].Schedule)
+
+	w = request(http.MethodGet, "/api/models/foo", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), `"name":"foo"`))
+
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/api/models/baz", "").Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodPost, "/api/models/baz/perform", "").Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/api/models/foo/unknown", "").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, request(http.MethodGet, "/api/models/foo/perform", "").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, request(http.MethodPost, "/api/models", "").Code)
+}
+
+func TestLogs(t *testing.T) {
+	logFilePath := config.LogFilePath
+	config.LogFilePath = filepath.Join(t.TempDir(), "launch.log")
+	defer func() { config.LogFilePath = logFilePath }()
+
+	assert.NoError(t, os.WriteFile(config.LogFilePath, []byte("line 1\nline 2\nline 3\n"), 0660))
+
+	w := request(http.MethodGet, "/api/logs?lines=2", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "line 2\nline 3\n", w.Body.String())
+
+	assert.Equal(t, "line 1\nline 2\nline 3\n", request(http.MethodGet, "/api/logs", "").Body.String())
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodGet, "/api/logs?lines=x", "").Code)
+}
+
+func TestTail(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "launch.log")
+
+	data, err := tail(filePath, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(data))
+
+	var lines []string
+	for i := 0; i < 10000; i++ {
+		lines = append(lines, strings.Repeat("x", 20))
+	}
+	lines = append(lines, "last line without newline")
+	assert.NoError(t, os.WriteFile(filePath, []byte(strings.Join(lines, "\n")), 0660))
+
+	data, err = tail(filePath, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("x", 20)+"\nlast line without newline", string(data))
+}
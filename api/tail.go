@@ -0,0 +1,56 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// tail return the last `lines` lines of the file, read backwards by blocks so the size of the log does not matter
+func tail(filePath string, lines int) ([]byte, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []byte{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	const blockSize = 64 * 1024
+	var data []byte
+	offset := info.Size()
+	for offset > 0 {
+		n := min(blockSize, offset)
+		offset -= n
+
+		block := make([]byte, n)
+		if _, err := f.ReadAt(block, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		data = append(block, data...)
+
+		// One more newline for the line ending of the last line
+		if bytes.Count(data, []byte("\n")) > lines {
+			break
+		}
+	}
+
+	// Drop the lines before the last `lines` lines
+	count := 0
+	for i := len(bytes.TrimSuffix(data, []byte("\n"))) - 1; i >= 0; i-- {
+		if data[i] == '\n' {
+			count++
+			if count == lines {
+				return data[i+1:], nil
+			}
+		}
+	}
+
+	return data, nil
+}
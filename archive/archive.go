@@ -5,12 +5,18 @@ import (
 	"path"
 	"path/filepath"
 
+	"github.com/gigcodes/launch-util/compressor"
 	"github.com/gigcodes/launch-util/config"
 	"github.com/gigcodes/launch-util/helper"
 	"github.com/gigcodes/launch-util/logger"
 )
 
-// Run archive
+// Run archive, with `mode: incremental` or `mode: differential` only the files changed since
+// the last archive are included, see incremental
+//
+//	archive:
+//	  mode: incremental
+//	  full_every: 7
 func Run(model config.ModelConfig) error {
 	logger := logger.Tag("Archive")
 
@@ -23,23 +29,73 @@ func Run(model config.ModelConfig) error {
 		return err
 	}
 
-	includes := model.Archive.GetStringSlice("includes")
-	includes = cleanPaths(includes)
+	includes, excludes, err := rules(model)
+	if err != nil {
+		return err
+	}
+	logger.Info("=> includes", len(includes), "rules")
 
-	excludes := model.Archive.GetStringSlice("excludes")
-	excludes = cleanPaths(excludes)
+	mode, err := mode(model)
+	if err != nil {
+		return err
+	}
+	if mode != modeFull {
+		return incremental(model, mode, includes, excludes)
+	}
 
-	if len(includes) == 0 {
-		return fmt.Errorf("archive.includes have no config")
+	if compressor.IsNative(model) {
+		return compressor.Archive(path.Join(model.DumpPath, "archive.tar"), includes, excludes)
 	}
-	logger.Info("=> includes", len(includes), "rules")
 
 	opts := options(model.DumpPath, excludes, includes)
 
-	_, err := helper.Exec("tar", opts...)
+	_, err = helper.Exec("tar", opts...)
 	return err
 }
 
+// Stream add the includes into the stream archive under the `archive` directory
+func Stream(model config.ModelConfig, stream *compressor.Stream) error {
+	logger := logger.Tag("Archive")
+
+	if model.Archive == nil {
+		return nil
+	}
+
+	includes, excludes, err := rules(model)
+	if err != nil {
+		return err
+	}
+	logger.Info("=> includes", len(includes), "rules (stream)")
+
+	if mode, err := mode(model); err != nil {
+		return err
+	} else if mode != modeFull {
+		return fmt.Errorf("archive.mode %s is not supported in stream mode", mode)
+	}
+
+	for _, include := range includes {
+		if err := stream.AddPath("archive", include, excludes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func rules(model config.ModelConfig) (includes, excludes []string, err error) {
+	includes = model.Archive.GetStringSlice("includes")
+	includes = cleanPaths(includes)
+
+	excludes = model.Archive.GetStringSlice("excludes")
+	excludes = cleanPaths(excludes)
+
+	if len(includes) == 0 {
+		return nil, nil, fmt.Errorf("archive.includes have no config")
+	}
+
+	return
+}
+
 func options(dumpPath string, excludes, includes []string) (opts []string) {
 	tarPath := path.Join(dumpPath, "archive.tar")
 	if helper.IsGnuTar {
@@ -0,0 +1,199 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gigcodes/launch-util/compressor"
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/logger"
+)
+
+const (
+	modeFull         = "full"
+	modeIncremental  = "incremental"
+	modeDifferential = "differential"
+
+	defaultFullEvery = 7
+)
+
+var (
+	snapshotPath = filepath.Join(config.LaunchAgentDir, "archive")
+)
+
+// snapshot of the files of the last archive, the next archive only contains the files changed since.
+//
+// A chain starts with a full (level 0) archive, in `incremental` mode each archive is one level
+// above the previous one, in `differential` mode all archives are level 1 and only need the full one.
+type snapshot struct {
+	Chain    string               `json:"chain"`
+	Level    int                  `json:"level"`
+	Count    int                  `json:"count"`
+	Includes []string             `json:"includes"`
+	Files    map[string]fileState `json:"files"`
+}
+
+type fileState struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// manifest is written as `archive.json` beside `archive.tar`
+type manifest struct {
+	Mode    string   `json:"mode"`
+	Chain   string   `json:"chain"`
+	Level   int      `json:"level"`
+	Deleted []string `json:"deleted,omitempty"`
+}
+
+func mode(model config.ModelConfig) (string, error) {
+	mode := model.Archive.GetString("mode")
+	switch mode {
+	case "":
+		return modeFull, nil
+	case modeFull, modeIncremental, modeDifferential:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("archive.mode %s is not supported", mode)
+}
+
+// incremental archive the files changed since the snapshot into `archive.tar`, a full archive is made
+// every `full_every` archives or when the includes are changed.
+// The new snapshot is pending until Commit, so a failed backup is archived again in the next run.
+func incremental(model config.ModelConfig, mode string, includes, excludes []string) error {
+	logger := logger.Tag("Archive")
+
+	fullEvery := model.Archive.GetInt("full_every")
+	if fullEvery <= 0 {
+		fullEvery = defaultFullEvery
+	}
+
+	fileName := snapshotFileName(model)
+	prev, err := loadSnapshot(fileName)
+	if err != nil {
+		logger.Warnf("Load snapshot %s failed, make a full archive: %v", fileName, err)
+	}
+
+	full := prev == nil || prev.Count >= fullEvery || !slices.Equal(prev.Includes, includes)
+
+	next := &snapshot{Includes: includes, Files: map[string]fileState{}}
+	if full {
+		next.Chain = time.Now().Format("2006.01.02.15.04.05")
+		next.Count = 1
+	} else {
+		next.Chain = prev.Chain
+		next.Count = prev.Count + 1
+		next.Level = 1
+		if mode == modeIncremental {
+			next.Level = prev.Level + 1
+		}
+	}
+	logger.Infof("=> %s archive level %d of chain %s", mode, next.Level, next.Chain)
+
+	var changed int
+	err = compressor.ArchiveFilter(path.Join(model.DumpPath, "archive.tar"), includes, excludes, func(filePath string, info os.FileInfo) bool {
+		state := fileState{Size: info.Size(), ModTime: info.ModTime()}
+		next.Files[filePath] = state
+		if !full {
+			last, ok := prev.Files[filePath]
+			if ok && last.Size == state.Size && last.ModTime.Equal(state.ModTime) {
+				return false
+			}
+		}
+		changed++
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	m := manifest{Mode: mode, Chain: next.Chain, Level: next.Level}
+	if !full {
+		for filePath := range prev.Files {
+			if _, ok := next.Files[filePath]; !ok {
+				m.Deleted = append(m.Deleted, filePath)
+			}
+		}
+		sort.Strings(m.Deleted)
+
+		// The differential archives are all compared with the full one
+		if mode == modeDifferential {
+			next.Files = prev.Files
+		}
+	}
+	logger.Infof("=> %d changed, %d deleted files", changed, len(m.Deleted))
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path.Join(model.DumpPath, "archive.json"), data, 0660); err != nil {
+		return err
+	}
+
+	if err := saveSnapshot(fileName+".pending", next); err != nil {
+		return err
+	}
+
+	if model.Viper != nil {
+		model.Viper.Set("ArchiveChain", next.Chain)
+		model.Viper.Set("ArchiveLevel", next.Level)
+	}
+
+	return nil
+}
+
+// Commit the pending snapshot of the incremental archive after the backup is stored
+func Commit(model config.ModelConfig) error {
+	if model.Archive == nil {
+		return nil
+	}
+
+	fileName := snapshotFileName(model)
+	if !helper.IsExistsPath(fileName + ".pending") {
+		return nil
+	}
+
+	return os.Rename(fileName+".pending", fileName)
+}
+
+func snapshotFileName(model config.ModelConfig) string {
+	return filepath.Join(snapshotPath, model.Name+".json")
+}
+
+// loadSnapshot return nil without error when the snapshot does not exist
+func loadSnapshot(fileName string) (*snapshot, error) {
+	data, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func saveSnapshot(fileName string, s *snapshot) error {
+	if err := helper.MkdirP(filepath.Dir(fileName)); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, data, 0660)
+}
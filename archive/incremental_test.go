@@ -0,0 +1,122 @@
+package archive
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
+)
+
+func readArchive(t *testing.T, dumpPath string) ([]string, manifest) {
+	f, err := os.Open(filepath.Join(dumpPath, "archive.tar"))
+	assert.NoError(t, err)
+	defer f.Close()
+
+	var names []string
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		if header.Typeflag == tar.TypeReg {
+			names = append(names, filepath.Base(header.Name))
+		}
+	}
+	sort.Strings(names)
+
+	var m manifest
+	data, err := os.ReadFile(filepath.Join(dumpPath, "archive.json"))
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &m))
+	return names, m
+}
+
+func TestRun_incremental(t *testing.T) {
+	for _, mode := range []string{modeIncremental, modeDifferential} {
+		t.Run(mode, func(t *testing.T) {
+			snapshotPath = t.TempDir()
+			dir := t.TempDir()
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0640))
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0640))
+
+			archiveViper := viper.New()
+			archiveViper.Set("includes", []string{dir})
+			archiveViper.Set("mode", mode)
+			archiveViper.Set("full_every", 3)
+			model := config.ModelConfig{
+				Name:     "test",
+				DumpPath: t.TempDir(),
+				Archive:  archiveViper,
+				Viper:    viper.New(),
+			}
+
+			// level 0
+			assert.NoError(t, Run(model))
+			names, m := readArchive(t, model.DumpPath)
+			assert.Equal(t, []string{"a.txt", "b.txt"}, names)
+			assert.Equal(t, 0, m.Level)
+			assert.Equal(t, m.Chain, model.Viper.GetString("ArchiveChain"))
+			chain := m.Chain
+
+			// Not committed, the next archive is still full
+			assert.NoError(t, Run(model))
+			_, m = readArchive(t, model.DumpPath)
+			assert.Equal(t, 0, m.Level)
+			assert.NoError(t, Commit(model))
+
+			// level 1, b.txt changed and a.txt deleted
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("bb"), 0640))
+			assert.NoError(t, os.Remove(filepath.Join(dir, "a.txt")))
+			assert.NoError(t, Run(model))
+			assert.NoError(t, Commit(model))
+			names, m = readArchive(t, model.DumpPath)
+			assert.Equal(t, []string{"b.txt"}, names)
+			assert.Equal(t, 1, m.Level)
+			assert.Equal(t, []string{filepath.Join(dir, "a.txt")}, m.Deleted)
+			assert.Equal(t, 1, model.Viper.GetInt("ArchiveLevel"))
+
+			// level 2 is compared with the last archive, differential is compared with the full one
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, "c.txt"), []byte("c"), 0640))
+			assert.NoError(t, Run(model))
+			assert.NoError(t, Commit(model))
+			names, m = readArchive(t, model.DumpPath)
+			if mode == modeIncremental {
+				assert.Equal(t, []string{"c.txt"}, names)
+				assert.Equal(t, 2, m.Level)
+				assert.Equal(t, 0, len(m.Deleted))
+			} else {
+				assert.Equal(t, []string{"b.txt", "c.txt"}, names)
+				assert.Equal(t, 1, m.Level)
+				assert.Equal(t, []string{filepath.Join(dir, "a.txt")}, m.Deleted)
+			}
+			assert.Equal(t, chain, m.Chain)
+
+			// full_every starts a new chain
+			time.Sleep(time.Second)
+			assert.NoError(t, Run(model))
+			names, m = readArchive(t, model.DumpPath)
+			assert.Equal(t, []string{"b.txt", "c.txt"}, names)
+			assert.Equal(t, 0, m.Level)
+			assert.NotEqual(t, chain, m.Chain)
+		})
+	}
+}
+
+func TestStream_incremental(t *testing.T) {
+	archiveViper := viper.New()
+	archiveViper.Set("includes", []string{"/tmp"})
+	archiveViper.Set("mode", modeIncremental)
+
+	err := Stream(config.ModelConfig{Archive: archiveViper}, nil)
+	assert.EqualError(t, err, "archive.mode incremental is not supported in stream mode")
+}
@@ -39,15 +39,9 @@ func newBase(model config.ModelConfig) (base Base) {
 	return
 }
 
-// Run compressor, return archive path
-func Run(model config.ModelConfig) (string, error) {
-	logger := logger.Tag("Compressor")
-
-	base := newBase(model)
-
-	var c Compressor
-	var ext, parallelProgram string
-	switch model.CompressWith.Type {
+// lookup the archive extension and the parallel compress program of the compress type
+func lookup(compressType string) (ext string, parallelProgram string, err error) {
+	switch compressType {
 	case "gz", "tgz", "taz", "tar.gz":
 		ext = ".tar.gz"
 		parallelProgram = "pigz"
@@ -67,13 +61,33 @@ func Run(model config.ModelConfig) (string, error) {
 		parallelProgram = "pixz"
 	case "zst", "tzst", "tar.zst":
 		ext = ".tar.zst"
-	case "tar":
-		ext = ".tar"
-	case "":
+	case "tar", "":
 		ext = ".tar"
-		model.CompressWith.Type = "tar"
 	default:
-		return "", fmt.Errorf("Unsupported compress type: %s", model.CompressWith.Type)
+		err = fmt.Errorf("Unsupported compress type: %s", compressType)
+	}
+
+	return
+}
+
+// IsNative return true when `compress_with.native` is enabled
+func IsNative(model config.ModelConfig) bool {
+	return model.CompressWith.Viper != nil && model.CompressWith.Viper.GetBool("native")
+}
+
+// Run compressor, return archive path
+func Run(model config.ModelConfig) (string, error) {
+	logger := logger.Tag("Compressor")
+
+	base := newBase(model)
+
+	var c Compressor
+	if len(model.CompressWith.Type) == 0 {
+		model.CompressWith.Type = "tar"
+	}
+	ext, parallelProgram, err := lookup(model.CompressWith.Type)
+	if err != nil {
+		return "", err
 	}
 
 	// save Extension
@@ -81,9 +95,13 @@ func Run(model config.ModelConfig) (string, error) {
 
 	base.ext = ext
 	base.parallelProgram = parallelProgram
-	c = &Tar{Base: base}
-
-	logger.Info("=> Compress | " + model.CompressWith.Type)
+	if IsNative(model) {
+		c = &Native{Base: base}
+		logger.Info("=> Compress | " + model.CompressWith.Type + " (native)")
+	} else {
+		c = &Tar{Base: base}
+		logger.Info("=> Compress | " + model.CompressWith.Type)
+	}
 
 	if err := helper.MkdirP(model.DumpPath); err != nil {
 		logger.Errorf("Failed to mkdir dump path %s: %v", model.DumpPath, err)
@@ -0,0 +1,270 @@
+package compressor
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+
+	"github.com/gigcodes/launch-util/logger"
+)
+
+// Native compress with archive/tar and the Go compression writers instead of the system `tar`
+//
+//	compress_with:
+//	  type: tgz
+//	  native: true
+//	  level: 6
+//	  threads: 4
+//
+// Supports tar, gz, zst, xz, lzma and bz2, Z, lz and lzo need the system `tar`. `level` is 1-9 (1-22 for zst),
+// `threads` only applies to gz and zst, both are checked when the config loads. The entries are sorted and keep
+// their owners like `tar`. Unreadable files are skipped with a warning per path, like `--ignore-failed-read`,
+// the file which shrinks while being read is padded with zeros to its size in the header with a warning, like `tar`.
+type Native struct {
+	Base
+}
+
+// xz has no preset levels, use the dictionary size of `xz -0` to `xz -9`
+var xzDictCaps = []int{256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+
+func (n *Native) perform() (archivePath string, err error) {
+	logger := logger.Tag("Compressor")
+
+	var level, threads int
+	if n.viper != nil {
+		level = n.viper.GetInt("level")
+		threads = n.viper.GetInt("threads")
+	}
+
+	archivePath = n.archiveFilePath(n.ext)
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	cw, err := newCompressWriter(n.ext, f, level, threads)
+	if err != nil {
+		return "", err
+	}
+
+	var tw *tar.Writer
+	if cw != nil {
+		tw = tar.NewWriter(cw)
+	} else {
+		tw = tar.NewWriter(f)
+	}
+
+	// model/mysql/mysql1/dump.sql
+	parent := filepath.Dir(n.model.DumpPath)
+	err = writeTree(tw, n.model.DumpPath, func(filePath string) string {
+		name, _ := filepath.Rel(parent, filePath)
+		return filepath.ToSlash(name)
+	}, nil, nil, logger)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if cw != nil {
+		if err := cw.Close(); err != nil {
+			return "", err
+		}
+	}
+
+	return archivePath, f.Close()
+}
+
+// Archive write `includes` into the tar file at `tarPath` natively, keep the names as given like `tar -P`
+func Archive(tarPath string, includes, excludes []string) error {
+	return ArchiveFilter(tarPath, includes, excludes, nil)
+}
+
+// ArchiveFilter is Archive which only writes the files accepted by `filter`, the directories are always written.
+// `filter` is called for every file not excluded, e.g. to collect the incremental snapshot.
+func ArchiveFilter(tarPath string, includes, excludes []string, filter func(filePath string, info os.FileInfo) bool) error {
+	logger := logger.Tag("Archive")
+
+	f, err := os.Create(tarPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for _, include := range includes {
+		err := writeTree(tw, include, func(filePath string) string {
+			return filepath.ToSlash(filePath)
+		}, excludes, filter, logger)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// newCompressWriter return the compression writer of the archive extension, nil for `.tar`
+func newCompressWriter(ext string, w io.Writer, level, threads int) (io.WriteCloser, error) {
+	switch ext {
+	case ".tar":
+		return nil, nil
+	case ".tar.gz":
+		if level == 0 {
+			level = pgzip.DefaultCompression
+		}
+		gw, err := pgzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, err
+		}
+		if threads > 0 {
+			if err := gw.SetConcurrency(1<<20, threads); err != nil {
+				return nil, err
+			}
+		}
+		return gw, nil
+	case ".tar.zst":
+		opts := []zstd.EOption{}
+		if level > 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		if threads > 0 {
+			opts = append(opts, zstd.WithEncoderConcurrency(threads))
+		}
+		return zstd.NewWriter(w, opts...)
+	case ".tar.xz":
+		config := xz.WriterConfig{}
+		if level > 0 {
+			config.DictCap = xzDictCaps[min(level, len(xzDictCaps)-1)]
+		}
+		return config.NewWriter(w)
+	case ".tar.lzma":
+		config := lzma.WriterConfig{}
+		if level > 0 {
+			config.DictCap = xzDictCaps[min(level, len(xzDictCaps)-1)]
+		}
+		return config.NewWriter(w)
+	case ".tar.bz2":
+		return bzip2.NewWriter(w, &bzip2.WriterConfig{Level: level})
+	}
+
+	return nil, fmt.Errorf("compress type %s is not supported by the native compressor", ext)
+}
+
+// writeTree write the file or directory `root` into the archive with the name returned by `name`,
+// files matched `excludes` or rejected by `filter` are skipped, unreadable files are logged and skipped.
+func writeTree(tw *tar.Writer, root string, name func(filePath string) string, excludes []string, filter func(filePath string, info os.FileInfo) bool, logger logger.Logger) error {
+	// Walk in lexical order for the reproducible archive
+	return filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			logger.Warnf("Skip %s: %v", filePath, err)
+			return nil
+		}
+
+		if isExcluded(filePath, excludes) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filter != nil && !info.IsDir() && !filter(filePath, info) {
+			return nil
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(filePath); err != nil {
+				logger.Warnf("Skip %s: %v", filePath, err)
+				return nil
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			logger.Warnf("Skip %s: %v", filePath, err)
+			return nil
+		}
+		header.Name = name(filePath)
+		if info.IsDir() {
+			header.Name = path.Clean(header.Name) + "/"
+		}
+		normalizeHeader(header)
+
+		if !info.Mode().IsRegular() {
+			return tw.WriteHeader(header)
+		}
+
+		f, err := os.Open(filePath)
+		if err != nil {
+			logger.Warnf("Skip %s: %v", filePath, err)
+			return nil
+		}
+		defer f.Close()
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		r := &fileReader{r: f}
+		n, err := io.CopyN(tw, r, header.Size)
+		if err == nil {
+			return nil
+		}
+		if err != io.EOF && r.err == nil {
+			return fmt.Errorf("write %s failed: %v", filePath, err)
+		}
+		if r.err != nil {
+			err = r.err
+		}
+		logger.Warnf("%s: read failed after %d of %d bytes, padding with zeros: %v", filePath, n, header.Size, err)
+		if _, err := io.CopyN(tw, zeroReader{}, header.Size-n); err != nil {
+			return fmt.Errorf("write %s failed: %v", filePath, err)
+		}
+		return nil
+	})
+}
+
+// fileReader keep the error of reading the file apart from the errors of writing the archive
+type fileReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *fileReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// zeroReader pads the file which shrinks while being read
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// normalizeHeader clear the access and change times, which change on every read, for the reproducible archive
+func normalizeHeader(header *tar.Header) {
+	header.ModTime = header.ModTime.Truncate(time.Second)
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.Format = tar.FormatUnknown
+}
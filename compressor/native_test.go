@@ -0,0 +1,164 @@
+package compressor
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/logger"
+)
+
+func newTestNative(t *testing.T, compressType string, level int) *Native {
+	tempPath := t.TempDir()
+	dumpPath := filepath.Join(tempPath, "test")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dumpPath, "mysql", "mysql1"), 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(dumpPath, "mysql", "mysql1", "db.sql"), []byte("hello world"), 0640))
+	assert.NoError(t, os.WriteFile(filepath.Join(dumpPath, "mysql", "mysql1", "a.sql"), []byte("foo"), 0640))
+
+	v := viper.New()
+	v.Set("native", true)
+	v.Set("level", level)
+	model := config.ModelConfig{
+		Name:         "test",
+		TempPath:     tempPath,
+		DumpPath:     dumpPath,
+		CompressWith: config.SubConfig{Type: compressType, Viper: v},
+	}
+
+	ext, _, err := lookup(compressType)
+	assert.NoError(t, err)
+	base := newBase(model)
+	base.ext = ext
+	return &Native{Base: base}
+}
+
+func readNativeArchive(t *testing.T, archivePath string) map[string]string {
+	f, err := os.Open(archivePath)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	var r io.Reader
+	switch filepath.Ext(archivePath) {
+	case ".gz":
+		r, err = gzip.NewReader(f)
+	case ".zst":
+		r, err = zstd.NewReader(f)
+	case ".xz":
+		r, err = xz.NewReader(f)
+	case ".lzma":
+		r, err = lzma.NewReader(f)
+	case ".bz2":
+		r = bzip2.NewReader(f)
+	default:
+		r = f
+	}
+	assert.NoError(t, err)
+
+	entries := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, os.Getuid(), header.Uid)
+
+		data, err := io.ReadAll(tr)
+		assert.NoError(t, err)
+		entries[header.Name] = string(data)
+	}
+	return entries
+}
+
+func TestNative_perform(t *testing.T) {
+	for _, compressType := range []string{"tar", "tgz", "zst", "xz", "lzma", "bz2"} {
+		t.Run(compressType, func(t *testing.T) {
+			n := newTestNative(t, compressType, 9)
+			archivePath, err := n.perform()
+			assert.NoError(t, err)
+
+			assert.Equal(t, map[string]string{
+				"test/":                    "",
+				"test/mysql/":              "",
+				"test/mysql/mysql1/":       "",
+				"test/mysql/mysql1/a.sql":  "foo",
+				"test/mysql/mysql1/db.sql": "hello world",
+			}, readNativeArchive(t, archivePath))
+		})
+	}
+
+	n := newTestNative(t, "lzo", 0)
+	_, err := n.perform()
+	assert.Error(t, err)
+}
+
+func TestNative_reproducible(t *testing.T) {
+	n := newTestNative(t, "tgz", 0)
+	n.viper.Set("threads", 2)
+
+	archivePath1, err := n.perform()
+	assert.NoError(t, err)
+	data1, err := os.ReadFile(archivePath1)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Remove(archivePath1))
+
+	archivePath2, err := n.perform()
+	assert.NoError(t, err)
+	data2, err := os.ReadFile(archivePath2)
+	assert.NoError(t, err)
+
+	assert.True(t, bytes.Equal(data1, data2))
+}
+
+func TestWriteTree_shrink(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "app.log")
+	assert.NoError(t, os.WriteFile(filePath, []byte("hello world"), 0640))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "z.log"), []byte("foo"), 0640))
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	err := writeTree(tw, dir, filepath.Base, nil, func(path string, info os.FileInfo) bool {
+		// The file shrinks after its header is made
+		if path == filePath {
+			assert.NoError(t, os.Truncate(filePath, 5))
+		}
+		return true
+	}, logger.Tag("Test"))
+	assert.NoError(t, err)
+	assert.NoError(t, tw.Close())
+
+	tarPath := filepath.Join(t.TempDir(), "archive.tar")
+	assert.NoError(t, os.WriteFile(tarPath, buf.Bytes(), 0640))
+	entries := readNativeArchive(t, tarPath)
+	assert.Equal(t, "hello\x00\x00\x00\x00\x00\x00", entries["app.log"])
+	assert.Equal(t, "foo", entries["z.log"])
+}
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "www", "logs"), 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "www", "index.html"), []byte("hello"), 0640))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "www", "logs", "access.log"), []byte("log"), 0640))
+
+	tarPath := filepath.Join(t.TempDir(), "archive.tar")
+	err := Archive(tarPath, []string{filepath.Join(dir, "www"), filepath.Join(dir, "not-found")}, []string{filepath.Join(dir, "www", "logs")})
+	assert.NoError(t, err)
+
+	entries := readNativeArchive(t, tarPath)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "hello", entries[filepath.ToSlash(filepath.Join(dir, "www", "index.html"))])
+}
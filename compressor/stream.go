@@ -0,0 +1,206 @@
+package compressor
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/logger"
+)
+
+const (
+	// Default size of each tar entry for the streamed dumps, in MB
+	defaultStreamChunkSize = 64
+)
+
+var (
+	// my_db.sql.part000001
+	streamPartRegexp = regexp.MustCompile(`\.part\d{6}$`)
+)
+
+// Stream writes the tar archive to a writer on the fly for stream mode.
+//
+// A tar header requires the size of the entry, so the dumps from stdout are
+// buffered in memory by `stream_chunk_size` MB. A dump larger than the chunk is
+// written into entries with `.part000000` suffix, which are joined by JoinParts.
+type Stream struct {
+	model  config.ModelConfig
+	buf    []byte
+	tw     *tar.Writer
+	cw     io.WriteCloser
+	logger logger.Logger
+}
+
+// NewStream create a stream writes the archive to `w`, return the stream and the archive file name
+func NewStream(model config.ModelConfig, w io.Writer) (*Stream, string, error) {
+	ext, _, err := lookup(model.CompressWith.Type)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var level, threads int
+	if model.CompressWith.Viper != nil {
+		level = model.CompressWith.Viper.GetInt("level")
+		threads = model.CompressWith.Viper.GetInt("threads")
+	}
+
+	cw, err := newCompressWriter(ext, w, level, threads)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if model.Viper != nil {
+		// save Extension
+		model.Viper.Set("Ext", ext)
+	}
+
+	chunkSize := model.StreamChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultStreamChunkSize
+	}
+
+	s := &Stream{
+		model:  model,
+		buf:    make([]byte, chunkSize*1024*1024),
+		cw:     cw,
+		logger: logger.Tag("Compressor"),
+	}
+	if cw != nil {
+		s.tw = tar.NewWriter(cw)
+	} else {
+		s.tw = tar.NewWriter(w)
+	}
+
+	fileName := time.Now().Format("2006.01.02.15.04.05") + ext
+	return s, fileName, nil
+}
+
+// AddStream add the content of `r` as `name` into the archive, under the model directory
+func (s *Stream) AddStream(name string, r io.Reader) error {
+	name = path.Join(s.model.Name, name)
+
+	for i := 0; ; i++ {
+		n, err := io.ReadFull(r, s.buf)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return err
+		}
+
+		// The stream ends at the chunk boundary
+		if last && n == 0 && i > 0 {
+			return nil
+		}
+
+		entry := name
+		if !last || i > 0 {
+			entry = fmt.Sprintf("%s.part%06d", name, i)
+		}
+
+		if err := s.tw.WriteHeader(&tar.Header{
+			Name:    entry,
+			Mode:    0640,
+			Size:    int64(n),
+			ModTime: time.Now(),
+		}); err != nil {
+			return err
+		}
+		if _, err := s.tw.Write(s.buf[:n]); err != nil {
+			return err
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// AddPath add the file or directory into the archive under `prefix` of the model directory,
+// files matched `excludes` are skipped, unreadable files are logged and skipped.
+func (s *Stream) AddPath(prefix, root string, excludes []string) error {
+	return writeTree(s.tw, root, func(filePath string) string {
+		return path.Join(s.model.Name, prefix, filepath.ToSlash(filePath))
+	}, excludes, nil, s.logger)
+}
+
+// Close the archive and flush the compressor
+func (s *Stream) Close() error {
+	if err := s.tw.Close(); err != nil {
+		return err
+	}
+	if s.cw != nil {
+		return s.cw.Close()
+	}
+	return nil
+}
+
+func isExcluded(filePath string, excludes []string) bool {
+	for _, exclude := range excludes {
+		if filePath == exclude || strings.HasPrefix(filePath, exclude+"/") {
+			return true
+		}
+		if matched, _ := filepath.Match(exclude, filePath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// JoinParts join the `.part000000` entries of the streamed dumps in `dir` back into the dump files
+func JoinParts(dir string) error {
+	parts := map[string][]string{}
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && streamPartRegexp.MatchString(filePath) {
+			name := streamPartRegexp.ReplaceAllString(filePath, "")
+			parts[name] = append(parts[name], filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for name, files := range parts {
+		sort.Strings(files)
+		if err := joinFiles(name, files); err != nil {
+			return fmt.Errorf("join %s failed: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func joinFiles(name string, files []string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, file := range files {
+		part, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, part)
+		part.Close()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
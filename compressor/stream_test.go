@@ -0,0 +1,90 @@
+package compressor
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/longbridgeapp/assert"
+)
+
+func extractTar(t *testing.T, r io.Reader, dir string) []string {
+	var names []string
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		names = append(names, header.Name)
+
+		target := filepath.Join(dir, header.Name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(target), 0750))
+		data, err := io.ReadAll(tr)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(target, data, 0640))
+	}
+	return names
+}
+
+func TestStream(t *testing.T) {
+	model := config.ModelConfig{
+		Name:         "test",
+		CompressWith: config.SubConfig{Type: "tgz"},
+	}
+
+	var buf bytes.Buffer
+	stream, fileName, err := NewStream(model, &buf)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(fileName, ".tar.gz"))
+
+	// Use a tiny chunk to split the dump into parts
+	stream.buf = make([]byte, 4)
+	assert.NoError(t, stream.AddStream("mysql/mysql1/db.sql", strings.NewReader("hello world")))
+	assert.NoError(t, stream.AddStream("mysql/mysql1/tiny.sql", strings.NewReader("hi")))
+	assert.NoError(t, stream.AddStream("mysql/mysql1/even.sql", strings.NewReader("12345678")))
+	assert.NoError(t, stream.Close())
+
+	gr, err := gzip.NewReader(&buf)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	names := extractTar(t, gr, dir)
+	assert.Equal(t, []string{
+		"test/mysql/mysql1/db.sql.part000000",
+		"test/mysql/mysql1/db.sql.part000001",
+		"test/mysql/mysql1/db.sql.part000002",
+		"test/mysql/mysql1/tiny.sql",
+		"test/mysql/mysql1/even.sql.part000000",
+		"test/mysql/mysql1/even.sql.part000001",
+	}, names)
+
+	assert.NoError(t, JoinParts(dir))
+
+	data, err := os.ReadFile(filepath.Join(dir, "test/mysql/mysql1/db.sql"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+
+	data, err = os.ReadFile(filepath.Join(dir, "test/mysql/mysql1/even.sql"))
+	assert.NoError(t, err)
+	assert.Equal(t, "12345678", string(data))
+
+	_, err = os.Stat(filepath.Join(dir, "test/mysql/mysql1/db.sql.part000000"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestNewStream_unsupported(t *testing.T) {
+	model := config.ModelConfig{
+		CompressWith: config.SubConfig{Type: "tar.lzo"},
+	}
+
+	_, _, err := NewStream(model, io.Discard)
+	assert.Error(t, err)
+}
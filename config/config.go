@@ -2,8 +2,12 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -34,18 +38,166 @@ var (
 
 	Pulse PulseConfig
 
+	// API of the `run` daemon
+	API APIConfig
+
+	// Metrics in Prometheus format
+	Metrics MetricsConfig
+
+	// Supervisor watchdog
+	Supervisor SupervisorConfig
+
+	// Notifiers of the pulse and the supervisor status events
+	Notifiers map[string]SubConfig
+
+	// ProcessManagers of the daemons not run by supervisor, systemd units and Docker containers
+	ProcessManagers map[string]SubConfig
+
+	// DefaultRetry of the storages and notifiers without `retry`
+	DefaultRetry = helper.RetryConfig{
+		Attempts:     3,
+		InitialDelay: time.Second,
+		MaxDelay:     30 * time.Second,
+		Errors:       []string{helper.RetryNetwork, helper.RetryTimeout, helper.RetryServer},
+	}
+
+	// RateLimit the total upload rate of all storages in bytes per second, 0 is unlimited
+	RateLimit int64
+
 	onConfigChanges = make([]func(fsnotify.Event), 0)
 )
 
 type PulseConfig struct {
-	Enabled bool          `json:"enabled,omitempty"`
-	Webhook WebhookConfig `json:"webhook,omitempty"`
+	Enabled bool `json:"enabled,omitempty"`
+	// Interval of fetching the system stats
+	Interval time.Duration `json:"interval,omitempty"`
+	// Report the system stats to the notifiers on every interval, false to only send the alerts
+	Report bool `json:"report,omitempty"`
+	// Alerts evaluated on every interval, sent with event "pulse_alert" and "pulse_resolved"
+	Alerts []AlertRule `json:"alerts,omitempty"`
+	// Hysteresis in percent of the threshold, the alert `disk_used_percent > 90` is resolved below 85.5 with 5
+	Hysteresis float64 `json:"hysteresis,omitempty"`
+}
+
+// AlertRule of the pulse, `disk_used_percent > 90 for 10m`
+type AlertRule struct {
+	Rule      string        `json:"rule"`
+	Metric    string        `json:"metric"`
+	Operator  string        `json:"operator"`
+	Threshold float64       `json:"threshold"`
+	For       time.Duration `json:"for,omitempty"`
+}
+
+// AlertMetrics of the pulse alert rules
+var AlertMetrics = []string{"load", "cpu_percent", "disk_used_percent", "disk_free_bytes", "memory_used_percent"}
+
+// String of the rule
+func (rule AlertRule) String() string {
+	return rule.Rule
+}
+
+// Firing return true when the value matches the rule
+func (rule AlertRule) Firing(value float64) bool {
+	switch rule.Operator {
+	case ">":
+		return value > rule.Threshold
+	case ">=":
+		return value >= rule.Threshold
+	case "<":
+		return value < rule.Threshold
+	case "<=":
+		return value <= rule.Threshold
+	}
+	return false
+}
+
+// Resolved return true when the value is back beyond the threshold by `hysteresis` percent of the threshold
+func (rule AlertRule) Resolved(value, hysteresis float64) bool {
+	margin := math.Abs(rule.Threshold) * hysteresis / 100
+	switch rule.Operator {
+	case ">", ">=":
+		return value < rule.Threshold-margin
+	default:
+		return value > rule.Threshold+margin
+	}
+}
+
+// ParseAlertRule parse `<metric> <operator> <threshold> [for <duration>]`, the operator is >, >=, < or <=
+func ParseAlertRule(rule string) (AlertRule, error) {
+	fields := strings.Fields(rule)
+	if len(fields) != 3 && (len(fields) != 5 || fields[3] != "for") {
+		return AlertRule{}, fmt.Errorf("alert %q must be `<metric> <operator> <threshold> [for <duration>]`", rule)
+	}
+
+	alert := AlertRule{Rule: strings.Join(fields, " "), Metric: fields[0], Operator: fields[1]}
+	if !slices.Contains(AlertMetrics, alert.Metric) {
+		return AlertRule{}, fmt.Errorf("alert %q metric %s is not supported, supported metrics: %s", rule, alert.Metric, strings.Join(AlertMetrics, ", "))
+	}
+
+	switch alert.Operator {
+	case ">", ">=", "<", "<=":
+	default:
+		return AlertRule{}, fmt.Errorf("alert %q operator %s is not supported", rule, alert.Operator)
+	}
+
+	threshold, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return AlertRule{}, fmt.Errorf("alert %q threshold %s is not a number", rule, fields[2])
+	}
+	alert.Threshold = threshold
+
+	if len(fields) == 5 {
+		alert.For, err = time.ParseDuration(fields[4])
+		if err != nil || alert.For < 0 {
+			return AlertRule{}, fmt.Errorf("alert %q duration %s is invalid", rule, fields[4])
+		}
+	}
+
+	return alert, nil
+}
+
+// APIConfig of the HTTP API server in the `run` daemon
+type APIConfig struct {
+	Enabled bool
+	// Listen on a TCP address `127.0.0.1:2703`, or a unix socket `unix:///var/run/launch-agent.sock`
+	Listen string
+	// Token is required as `Authorization: Bearer <token>` when it is set
+	Token string
+}
+
+// MetricsConfig of the Prometheus metrics
+type MetricsConfig struct {
+	// Enabled serves `/metrics` on `Listen` in the `run` daemon
+	Enabled bool
+	Listen  string
+	// Textfile is written after each perform in the node_exporter textfile collector format when it is set
+	Textfile string
+}
+
+// SupervisorConfig of the supervisor watchdog in the `run` daemon
+type SupervisorConfig struct {
+	// Enabled polls the processes every `Interval` and notifies the state changes
+	Enabled  bool
+	Interval time.Duration
+	// Instances of supervisord on the host, sorted by name
+	Instances []SupervisorInstance
+	// RestartFatal restart the FATAL processes, up to `MaxRestartsPerHour` times per process
+	RestartFatal       bool
+	MaxRestartsPerHour int
+	// A process with `FlapThreshold` state changes in `FlapWindow` is flapping,
+	// its state changes are not notified until it is stable for `FlapWindow`
+	FlapThreshold int
+	FlapWindow    time.Duration
 }
 
-type WebhookConfig struct {
-	Url     string
-	Method  string
-	Headers map[string]string
+// SupervisorInstance of supervisord, over the unix socket `Socket` of `unix_http_server`,
+// or over TCP to the `URL` of `inet_http_server`. The username and password are sent with basic auth.
+type SupervisorInstance struct {
+	Name     string `json:"name"`
+	Socket   string `json:"socket,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Username string `json:"-"`
+	Password string `json:"-"`
 }
 
 type ScheduleConfig struct {
@@ -66,18 +218,29 @@ func (sc ScheduleConfig) String() string {
 
 // ModelConfig for special case
 type ModelConfig struct {
-	Name           string
-	WorkDir        string
-	TempPath       string
-	DumpPath       string
-	Schedule       ScheduleConfig
-	CompressWith   SubConfig
-	Archive        *viper.Viper
-	Databases      map[string]SubConfig
-	Storages       map[string]SubConfig
-	DefaultStorage string
-	Webhook        WebhookConfig
-	Viper          *viper.Viper
+	Name              string
+	WorkDir           string
+	TempPath          string
+	DumpPath          string
+	Schedule          ScheduleConfig
+	CompressWith      SubConfig
+	EncryptWith       SubConfig
+	SplitIntoChunksOf int
+	Stream            bool
+	StreamChunkSize   int
+	Verify            bool
+	ParallelDumps     int
+	ParallelUploads   int
+	// DiskCheckFactor refuse to start when the free disk of the workdir is below the size of the last backup
+	// times the factor, 0 disables the check
+	DiskCheckFactor float64
+	Retry           helper.RetryConfig
+	Archive         *viper.Viper
+	Databases       map[string]SubConfig
+	Storages        map[string]SubConfig
+	DefaultStorage  string
+	Notifiers       map[string]SubConfig
+	Viper           *viper.Viper
 }
 
 func getLaunchAgentDir() string {
@@ -196,7 +359,13 @@ func loadConfig() error {
 		viper.Set("useTempWorkDir", true)
 	}
 
+	rateLimit, err := helper.ParseRate(viper.GetString("rate_limit"))
+	if err != nil {
+		return err
+	}
+
 	Exist = true
+	RateLimit = rateLimit
 	Models = []ModelConfig{}
 	for key := range viper.GetStringMap("models") {
 		model, err := loadModel(key)
@@ -207,13 +376,67 @@ func loadConfig() error {
 		Models = append(Models, model)
 	}
 
+	viper.SetDefault("pulse.interval", "5m")
+	viper.SetDefault("pulse.report", true)
+	viper.SetDefault("pulse.hysteresis", 5)
 	Pulse = PulseConfig{
-		Enabled: viper.GetBool("pulse.enabled"),
-		Webhook: WebhookConfig{
-			Url:     viper.GetString("pulse.webhook.url"),
-			Method:  viper.GetString("pulse.webhook.method"),
-			Headers: viper.GetStringMapString("pulse.webhook.headers"),
-		},
+		Enabled:    viper.GetBool("pulse.enabled"),
+		Interval:   viper.GetDuration("pulse.interval"),
+		Report:     viper.GetBool("pulse.report"),
+		Hysteresis: viper.GetFloat64("pulse.hysteresis"),
+	}
+	if Pulse.Interval <= 0 {
+		return fmt.Errorf("pulse.interval must be greater than 0")
+	}
+	for _, rule := range viper.GetStringSlice("pulse.alerts") {
+		alert, err := ParseAlertRule(rule)
+		if err != nil {
+			return err
+		}
+		Pulse.Alerts = append(Pulse.Alerts, alert)
+	}
+	// Backward compatible with `pulse.webhook`
+	Notifiers, err = loadNotifiersConfig(viper.GetViper(), viper.Sub("pulse"))
+	if err != nil {
+		return err
+	}
+
+	viper.SetDefault("api.listen", "127.0.0.1:2703")
+	API = APIConfig{
+		Enabled: viper.GetBool("api.enabled"),
+		Listen:  viper.GetString("api.listen"),
+		Token:   viper.GetString("api.token"),
+	}
+
+	viper.SetDefault("supervisor.interval", "30s")
+	viper.SetDefault("supervisor.max_restarts_per_hour", 3)
+	viper.SetDefault("supervisor.flap_threshold", 5)
+	viper.SetDefault("supervisor.flap_window", "10m")
+	Supervisor = SupervisorConfig{
+		Enabled:            viper.GetBool("supervisor.enabled"),
+		Interval:           viper.GetDuration("supervisor.interval"),
+		Instances:          loadSupervisorInstances(viper.GetViper()),
+		RestartFatal:       viper.GetBool("supervisor.restart_fatal"),
+		MaxRestartsPerHour: viper.GetInt("supervisor.max_restarts_per_hour"),
+		FlapThreshold:      viper.GetInt("supervisor.flap_threshold"),
+		FlapWindow:         viper.GetDuration("supervisor.flap_window"),
+	}
+	if Supervisor.Interval <= 0 {
+		return fmt.Errorf("supervisor.interval must be greater than 0")
+	}
+
+	ProcessManagers = loadProcessManagersConfig(viper.GetViper())
+	for _, instance := range Supervisor.Instances {
+		if _, ok := ProcessManagers[instance.Name]; ok {
+			return fmt.Errorf("process manager %s has the name of a supervisor instance", instance.Name)
+		}
+	}
+
+	viper.SetDefault("metrics.listen", "127.0.0.1:9612")
+	Metrics = MetricsConfig{
+		Enabled:  viper.GetBool("metrics.enabled"),
+		Listen:   viper.GetString("metrics.listen"),
+		Textfile: viper.GetString("metrics.textfile"),
 	}
 
 	UpdatedAt = time.Now()
@@ -239,17 +462,46 @@ func loadModel(key string) (ModelConfig, error) {
 		Viper: model.Viper.Sub("compress_with"),
 	}
 
-	model.Archive = model.Viper.Sub("archive")
+	model.EncryptWith = SubConfig{
+		Type:  model.Viper.GetString("encrypt_with.type"),
+		Viper: model.Viper.Sub("encrypt_with"),
+	}
+	if model.EncryptWith.Type == "openssl" {
+		if err := ValidateOpenSSLCipher(model.Viper.GetString("encrypt_with.cipher")); err != nil {
+			return ModelConfig{}, fmt.Errorf("encrypt_with of model %s: %w", model.Name, err)
+		}
+	}
 
-	model.Webhook = WebhookConfig{
-		Url:     model.Viper.GetString("webhook.url"),
-		Method:  model.Viper.GetString("webhook.method"),
-		Headers: model.Viper.GetStringMapString("webhook.headers"),
+	model.SplitIntoChunksOf = model.Viper.GetInt("split_into_chunks_of")
+	model.Stream = model.Viper.GetBool("stream")
+	if err := validateNativeCompress(model.CompressWith, model.Stream); err != nil {
+		return ModelConfig{}, fmt.Errorf("compress_with of model %s: %w", model.Name, err)
+	}
+	model.StreamChunkSize = model.Viper.GetInt("stream_chunk_size")
+	model.Verify = model.Viper.GetBool("verify")
+	model.ParallelDumps = model.Viper.GetInt("parallel_dumps")
+	model.ParallelUploads = model.Viper.GetInt("parallel_uploads")
+	model.Viper.SetDefault("disk_check_factor", 2)
+	model.DiskCheckFactor = model.Viper.GetFloat64("disk_check_factor")
+
+	retry, err := LoadRetryConfig(model.Viper.Sub("retry"), DefaultRetry)
+	if err != nil {
+		return ModelConfig{}, err
 	}
+	model.Retry = retry
+
+	model.Archive = model.Viper.Sub("archive")
 
 	loadScheduleConfig(&model)
 	loadDatabasesConfig(&model)
-	loadStoragesConfig(&model)
+	if err := loadStoragesConfig(&model); err != nil {
+		return ModelConfig{}, err
+	}
+	// Backward compatible with the model `webhook`
+	model.Notifiers, err = loadNotifiersConfig(model.Viper, model.Viper)
+	if err != nil {
+		return ModelConfig{}, fmt.Errorf("model %s: %v", model.Name, err)
+	}
 
 	if len(model.Storages) == 0 {
 		return ModelConfig{}, fmt.Errorf("no storage found in model %s", model.Name)
@@ -258,6 +510,89 @@ func loadModel(key string) (ModelConfig, error) {
 	return model, nil
 }
 
+// validateNativeCompress reject the compress types, the `level` and the `threads` which the native compressor
+// does not support, see compressor.Native, the stream mode always compresses natively
+func validateNativeCompress(compressWith SubConfig, stream bool) error {
+	native := compressWith.Viper != nil && compressWith.Viper.GetBool("native")
+	if !native && !stream {
+		return nil
+	}
+
+	switch compressWith.Type {
+	case "Z", "taZ", "tar.Z", "lz", "tar.lz", "lzo", "tar.lzo":
+		return fmt.Errorf("compress type %s is not supported by the native compressor", compressWith.Type)
+	}
+
+	if compressWith.Viper != nil && compressWith.Viper.GetInt("threads") > 1 {
+		switch compressWith.Type {
+		case "gz", "tgz", "taz", "tar.gz", "zst", "tzst", "tar.zst":
+		default:
+			return fmt.Errorf("threads is not supported by the native compressor with compress type %s, only gz and zst", compressWith.Type)
+		}
+	}
+
+	// 0 is the default level of the compress type, tar is not compressed
+	if compressWith.Viper != nil && compressWith.Type != "tar" && compressWith.Type != "" {
+		maxLevel := 9
+		switch compressWith.Type {
+		case "zst", "tzst", "tar.zst":
+			maxLevel = 22
+		}
+		if level := compressWith.Viper.GetInt("level"); level < 0 || level > maxLevel {
+			return fmt.Errorf("level %d is not supported by the native compressor with compress type %s, only 1-%d", level, compressWith.Type, maxLevel)
+		}
+	}
+
+	return nil
+}
+
+// ValidateOpenSSLCipher reject the AEAD ciphers like aes-256-gcm, `openssl enc` does not support them
+func ValidateOpenSSLCipher(cipher string) error {
+	cipher = strings.ToLower(strings.TrimPrefix(cipher, "-"))
+	for _, suffix := range []string{"-gcm", "-ccm", "-ocb", "-siv", "-poly1305"} {
+		if strings.HasSuffix(cipher, suffix) {
+			return fmt.Errorf("openssl cipher %s is not supported, `openssl enc` does not support the AEAD ciphers, use age instead", cipher)
+		}
+	}
+	return nil
+}
+
+// LoadRetryConfig load the `retry` block, the fields not set are taken from `defaults`
+//
+//	retry:
+//	  attempts: 3
+//	  initial_delay: 1s
+//	  max_delay: 30s
+//	  errors: [network, timeout, server]
+func LoadRetryConfig(v *viper.Viper, defaults helper.RetryConfig) (helper.RetryConfig, error) {
+	retry := defaults
+	if v == nil {
+		return retry, nil
+	}
+
+	if v.IsSet("attempts") {
+		retry.Attempts = v.GetInt("attempts")
+	}
+	if v.IsSet("initial_delay") {
+		retry.InitialDelay = v.GetDuration("initial_delay")
+	}
+	if v.IsSet("max_delay") {
+		retry.MaxDelay = v.GetDuration("max_delay")
+	}
+	if v.IsSet("errors") {
+		retry.Errors = v.GetStringSlice("errors")
+		for _, class := range retry.Errors {
+			switch class {
+			case helper.RetryNetwork, helper.RetryTimeout, helper.RetryServer, helper.RetryAll:
+			default:
+				return retry, fmt.Errorf("retry.errors %s is not supported", class)
+			}
+		}
+	}
+
+	return retry, nil
+}
+
 func loadScheduleConfig(model *ModelConfig) {
 	subViper := model.Viper.Sub("schedule")
 	model.Schedule = ScheduleConfig{Enabled: false}
@@ -284,7 +619,9 @@ func loadDatabasesConfig(model *ModelConfig) {
 	}
 }
 
-func loadStoragesConfig(model *ModelConfig) {
+// loadStoragesConfig load the storages of the model, the settings which are only used at upload time
+// are validated here, so an invalid one fails the config load instead of the perform.
+func loadStoragesConfig(model *ModelConfig) error {
 	storageConfigs := map[string]SubConfig{}
 
 	model.DefaultStorage = model.Viper.GetString("default_storage")
@@ -292,6 +629,21 @@ func loadStoragesConfig(model *ModelConfig) {
 	subViper := model.Viper.Sub("storages")
 	for key := range model.Viper.GetStringMap("storages") {
 		storageViper := subViper.Sub(key)
+		if storageViper == nil {
+			storageViper = viper.New()
+		}
+		if _, err := helper.ParseRate(storageViper.GetString("rate_limit")); err != nil {
+			return fmt.Errorf("storage %s of model %s: %w", key, model.Name, err)
+		}
+		if maxAge := storageViper.GetString("retention.max_age"); len(maxAge) > 0 {
+			if _, err := helper.ParseDuration(maxAge); err != nil {
+				return fmt.Errorf("storage %s of model %s: invalid retention max_age %q: %v", key, model.Name, maxAge, err)
+			}
+		}
+		if _, err := LoadRetryConfig(storageViper.Sub("retry"), model.Retry); err != nil {
+			return fmt.Errorf("storage %s of model %s: %w", key, model.Name, err)
+		}
+
 		storageConfigs[key] = SubConfig{
 			Name:  key,
 			Type:  storageViper.GetString("type"),
@@ -305,6 +657,154 @@ func loadStoragesConfig(model *ModelConfig) {
 	}
 	model.Storages = storageConfigs
 
+	return nil
+}
+
+// loadSupervisorInstances load the `supervisor.instances` block of `v`, or the instance `default`
+// of the `supervisor` block without instances. The instance without `socket` and `url` uses
+// the socket `/var/run/supervisor.sock`, unless only `process_managers` are configured.
+func loadSupervisorInstances(v *viper.Viper) []SupervisorInstance {
+	load := func(name string, instanceViper *viper.Viper) SupervisorInstance {
+		instance := SupervisorInstance{
+			Name:     name,
+			Socket:   instanceViper.GetString("socket"),
+			URL:      instanceViper.GetString("url"),
+			Username: instanceViper.GetString("username"),
+			Password: instanceViper.GetString("password"),
+		}
+		if len(instance.Socket) == 0 && len(instance.URL) == 0 {
+			instance.Socket = "/var/run/supervisor.sock"
+		}
+		return instance
+	}
+
+	keys := v.GetStringMap("supervisor.instances")
+	if len(keys) == 0 {
+		// The host without supervisor, only with systemd units or Docker containers
+		if v.IsSet("process_managers") && !v.IsSet("supervisor.socket") && !v.IsSet("supervisor.url") {
+			return nil
+		}
+
+		supervisorViper := v.Sub("supervisor")
+		if supervisorViper == nil {
+			supervisorViper = viper.New()
+		}
+		return []SupervisorInstance{load("default", supervisorViper)}
+	}
+
+	instances := make([]SupervisorInstance, 0, len(keys))
+	subViper := v.Sub("supervisor.instances")
+	for key := range keys {
+		instanceViper := subViper.Sub(key)
+		if instanceViper == nil {
+			instanceViper = viper.New()
+		}
+		instances = append(instances, load(key, instanceViper))
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Name < instances[j].Name })
+
+	return instances
+}
+
+// GetSupervisorInstances get the supervisor instances by name, all instances when `names` is empty
+func GetSupervisorInstances(names []string) ([]SupervisorInstance, error) {
+	if len(names) == 0 {
+		return Supervisor.Instances, nil
+	}
+
+	var instances []SupervisorInstance
+	for _, name := range names {
+		found := false
+		for _, instance := range Supervisor.Instances {
+			if instance.Name == name {
+				instances = append(instances, instance)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("supervisor instance %s not found", name)
+		}
+	}
+
+	return instances, nil
+}
+
+// GetDaemonSources get the supervisor instances and the process managers by name for the daemon statuses,
+// all of them when `names` is empty
+func GetDaemonSources(names []string) ([]SupervisorInstance, map[string]SubConfig, error) {
+	if len(names) == 0 {
+		return Supervisor.Instances, ProcessManagers, nil
+	}
+
+	var instances []SupervisorInstance
+	managers := map[string]SubConfig{}
+	for _, name := range names {
+		if manager, ok := ProcessManagers[name]; ok {
+			managers[name] = manager
+			continue
+		}
+
+		found, err := GetSupervisorInstances([]string{name})
+		if err != nil {
+			return nil, nil, fmt.Errorf("supervisor instance or process manager %s not found", name)
+		}
+		instances = append(instances, found...)
+	}
+
+	return instances, managers, nil
+}
+
+// loadProcessManagersConfig load the `process_managers` block of `v`
+func loadProcessManagersConfig(v *viper.Viper) map[string]SubConfig {
+	managers := map[string]SubConfig{}
+
+	subViper := v.Sub("process_managers")
+	for key := range v.GetStringMap("process_managers") {
+		managerViper := subViper.Sub(key)
+		if managerViper == nil {
+			managerViper = viper.New()
+		}
+		managers[key] = SubConfig{
+			Name:  key,
+			Type:  managerViper.GetString("type"),
+			Viper: managerViper,
+		}
+	}
+
+	return managers
+}
+
+// loadNotifiersConfig load the `notifiers` block of `v`, the `webhook` block of `legacy`
+// is added as the `webhook` notifier unless a notifier has the name.
+func loadNotifiersConfig(v *viper.Viper, legacy *viper.Viper) (map[string]SubConfig, error) {
+	notifierConfigs := map[string]SubConfig{}
+
+	subViper := v.Sub("notifiers")
+	for key := range v.GetStringMap("notifiers") {
+		// The empty or scalar entry, e.g. `slack:`
+		notifierViper := subViper.Sub(key)
+		if notifierViper == nil {
+			return nil, fmt.Errorf("notifier %s is empty, type is required", key)
+		}
+		notifierConfigs[key] = SubConfig{
+			Name:  key,
+			Type:  notifierViper.GetString("type"),
+			Viper: notifierViper,
+		}
+	}
+
+	if legacy != nil && len(legacy.GetString("webhook.url")) > 0 {
+		if _, ok := notifierConfigs["webhook"]; !ok {
+			notifierConfigs["webhook"] = SubConfig{
+				Name:  "webhook",
+				Type:  "webhook",
+				Viper: legacy.Sub("webhook"),
+			}
+		}
+	}
+
+	return notifierConfigs, nil
 }
 
 // GetModelConfigByName get model config by name
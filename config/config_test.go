@@ -2,10 +2,12 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
 )
 
 var (
@@ -167,3 +169,182 @@ func updateFile(path string) error {
 
 	return nil
 }
+
+func TestLoadRetryConfig(t *testing.T) {
+	retry, err := LoadRetryConfig(nil, DefaultRetry)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultRetry, retry)
+
+	v := viper.New()
+	v.Set("attempts", 5)
+	v.Set("max_delay", "1m")
+	retry, err = LoadRetryConfig(v, DefaultRetry)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, retry.Attempts)
+	assert.Equal(t, time.Second, retry.InitialDelay)
+	assert.Equal(t, time.Minute, retry.MaxDelay)
+	assert.Equal(t, DefaultRetry.Errors, retry.Errors)
+
+	v.Set("errors", []string{"network", "disk"})
+	_, err = LoadRetryConfig(v, DefaultRetry)
+	assert.EqualError(t, err, "retry.errors disk is not supported")
+}
+
+func Test_loadNotifiersConfig(t *testing.T) {
+	v := viper.New()
+	v.Set("notifiers.ops.type", "slack")
+	v.Set("notifiers.ops.url", "https://hooks.slack.com/services/xxx")
+	v.Set("webhook.url", "http://localhost:3000/api/backup-notifiy.json")
+	v.Set("webhook.method", "POST")
+
+	notifiers, err := loadNotifiersConfig(v, v)
+	assert.NoError(t, err)
+	assert.Len(t, notifiers, 2)
+	assert.Equal(t, "slack", notifiers["ops"].Type)
+	assert.Equal(t, "https://hooks.slack.com/services/xxx", notifiers["ops"].Viper.GetString("url"))
+	// Backward compatible with `webhook`
+	assert.Equal(t, "webhook", notifiers["webhook"].Type)
+	assert.Equal(t, "POST", notifiers["webhook"].Viper.GetString("method"))
+
+	// The `webhook` notifier overrides the legacy webhook
+	v.Set("notifiers.webhook.type", "discord")
+	notifiers, err = loadNotifiersConfig(v, v)
+	assert.NoError(t, err)
+	assert.Equal(t, "discord", notifiers["webhook"].Type)
+
+	notifiers, err = loadNotifiersConfig(viper.New(), nil)
+	assert.NoError(t, err)
+	assert.Len(t, notifiers, 0)
+
+	// The empty entry `slack:`
+	v = viper.New()
+	v.SetConfigType("yaml")
+	assert.NoError(t, v.ReadConfig(strings.NewReader("notifiers:\n  slack:\n")))
+	_, err = loadNotifiersConfig(v, nil)
+	assert.EqualError(t, err, "notifier slack is empty, type is required")
+}
+
+func Test_loadSupervisorInstances(t *testing.T) {
+	assert.Equal(t, []SupervisorInstance{{Name: "default", Socket: "/var/run/supervisor.sock"}}, loadSupervisorInstances(viper.New()))
+
+	v := viper.New()
+	v.Set("supervisor.socket", "/tmp/supervisor.sock")
+	assert.Equal(t, []SupervisorInstance{{Name: "default", Socket: "/tmp/supervisor.sock"}}, loadSupervisorInstances(v))
+
+	v.Set("supervisor.instances.queue.url", "http://127.0.0.1:9001")
+	v.Set("supervisor.instances.queue.username", "user")
+	v.Set("supervisor.instances.queue.password", "123")
+	v.Set("supervisor.instances.app.socket", "/tmp/supervisor.sock")
+	assert.Equal(t, []SupervisorInstance{
+		{Name: "app", Socket: "/tmp/supervisor.sock"},
+		{Name: "queue", URL: "http://127.0.0.1:9001", Username: "user", Password: "123"},
+	}, loadSupervisorInstances(v))
+}
+
+func Test_loadProcessManagersConfig(t *testing.T) {
+	v := viper.New()
+	v.Set("process_managers.units.type", "systemd")
+	v.Set("process_managers.units.units", []string{"nginx"})
+	v.Set("process_managers.containers.type", "docker")
+
+	managers := loadProcessManagersConfig(v)
+	assert.Len(t, managers, 2)
+	assert.Equal(t, "systemd", managers["units"].Type)
+	assert.Equal(t, []string{"nginx"}, managers["units"].Viper.GetStringSlice("units"))
+	assert.Equal(t, "docker", managers["containers"].Type)
+
+	// No supervisor on the host with only the process managers
+	assert.Len(t, loadSupervisorInstances(v), 0)
+	v.Set("supervisor.socket", "/tmp/supervisor.sock")
+	assert.Equal(t, []SupervisorInstance{{Name: "default", Socket: "/tmp/supervisor.sock"}}, loadSupervisorInstances(v))
+}
+
+func TestParseAlertRule(t *testing.T) {
+	rule, err := ParseAlertRule("disk_used_percent  > 90 for 10m")
+	assert.NoError(t, err)
+	assert.Equal(t, AlertRule{Rule: "disk_used_percent > 90 for 10m", Metric: "disk_used_percent", Operator: ">", Threshold: 90, For: 10 * time.Minute}, rule)
+	assert.True(t, rule.Firing(90.5))
+	assert.False(t, rule.Firing(90))
+	// Resolved below 85.5 with the hysteresis 5
+	assert.False(t, rule.Resolved(86, 5))
+	assert.True(t, rule.Resolved(85, 5))
+
+	rule, err = ParseAlertRule("disk_free_bytes <= 1e9")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), rule.For)
+	assert.True(t, rule.Firing(1e9))
+	assert.True(t, rule.Resolved(1.1e9, 5))
+
+	_, err = ParseAlertRule("load > 8 for")
+	assert.EqualError(t, err, "alert \"load > 8 for\" must be `<metric> <operator> <threshold> [for <duration>]`")
+	_, err = ParseAlertRule("swap > 8")
+	assert.EqualError(t, err, "alert \"swap > 8\" metric swap is not supported, supported metrics: load, cpu_percent, disk_used_percent, disk_free_bytes, memory_used_percent")
+	_, err = ParseAlertRule("load == 8")
+	assert.EqualError(t, err, "alert \"load == 8\" operator == is not supported")
+	_, err = ParseAlertRule("load > high")
+	assert.EqualError(t, err, "alert \"load > high\" threshold high is not a number")
+	_, err = ParseAlertRule("load > 8 for 5")
+	assert.EqualError(t, err, "alert \"load > 8 for 5\" duration 5 is invalid")
+}
+
+func Test_loadStoragesConfig(t *testing.T) {
+	model := &ModelConfig{Name: "app", Viper: viper.New()}
+	model.Viper.Set("storages.local.type", "local")
+	model.Viper.Set("storages.local.rate_limit", "20MB/s")
+	assert.NoError(t, loadStoragesConfig(model))
+	assert.Equal(t, "local", model.DefaultStorage)
+
+	model.Viper.Set("storages.local.rate_limit", "fast")
+	err := loadStoragesConfig(model)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `storage local of model app: invalid rate_limit "fast"`)
+
+	model.Viper.Set("storages.local.rate_limit", "")
+	model.Viper.Set("storages.local.retention.max_age", "90d")
+	assert.NoError(t, loadStoragesConfig(model))
+
+	model.Viper.Set("storages.local.retention.max_age", "3 months")
+	err = loadStoragesConfig(model)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `storage local of model app: invalid retention max_age "3 months"`)
+
+	model.Viper.Set("storages.local.retention.max_age", "")
+	model.Viper.Set("storages.local.retry.errors", []string{"network", "disk"})
+	err = loadStoragesConfig(model)
+	assert.Error(t, err)
+	assert.Equal(t, "storage local of model app: retry.errors disk is not supported", err.Error())
+}
+
+func TestValidateOpenSSLCipher(t *testing.T) {
+	assert.NoError(t, ValidateOpenSSLCipher("aes-256-cbc"))
+	assert.NoError(t, ValidateOpenSSLCipher(""))
+	assert.EqualError(t, ValidateOpenSSLCipher("-AES-256-GCM"), "openssl cipher aes-256-gcm is not supported, `openssl enc` does not support the AEAD ciphers, use age instead")
+	assert.Error(t, ValidateOpenSSLCipher("chacha20-poly1305"))
+}
+
+func Test_validateNativeCompress(t *testing.T) {
+	v := viper.New()
+	assert.NoError(t, validateNativeCompress(SubConfig{Type: "lzo", Viper: v}, false))
+	assert.Error(t, validateNativeCompress(SubConfig{Type: "lzo"}, true))
+
+	v.Set("native", true)
+	assert.NoError(t, validateNativeCompress(SubConfig{Type: "lzma", Viper: v}, false))
+	assert.EqualError(t, validateNativeCompress(SubConfig{Type: "lzo", Viper: v}, false), "compress type lzo is not supported by the native compressor")
+
+	v.Set("threads", 4)
+	assert.NoError(t, validateNativeCompress(SubConfig{Type: "tgz", Viper: v}, false))
+	assert.NoError(t, validateNativeCompress(SubConfig{Type: "zst", Viper: v}, false))
+	assert.EqualError(t, validateNativeCompress(SubConfig{Type: "xz", Viper: v}, false), "threads is not supported by the native compressor with compress type xz, only gz and zst")
+
+	v = viper.New()
+	v.Set("native", true)
+	v.Set("level", 0)
+	assert.NoError(t, validateNativeCompress(SubConfig{Type: "tgz", Viper: v}, false))
+	v.Set("level", 19)
+	assert.NoError(t, validateNativeCompress(SubConfig{Type: "zst", Viper: v}, false))
+	assert.EqualError(t, validateNativeCompress(SubConfig{Type: "tgz", Viper: v}, false), "level 19 is not supported by the native compressor with compress type tgz, only 1-9")
+	v.Set("level", 23)
+	assert.EqualError(t, validateNativeCompress(SubConfig{Type: "zst", Viper: v}, false), "level 23 is not supported by the native compressor with compress type zst, only 1-22")
+	v.Set("level", -1)
+	assert.Error(t, validateNativeCompress(SubConfig{Type: "xz", Viper: v}, false))
+}
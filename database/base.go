@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/google/shlex"
 	"github.com/spf13/viper"
 
 	"github.com/gigcodes/launch-util/config"
 	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/history"
 	"github.com/gigcodes/launch-util/logger"
 )
 
@@ -28,6 +30,8 @@ type Database interface {
 	init() error
 	// Peform backup
 	perform() error
+	// Restore from the dump in dumpPath
+	restore() error
 }
 
 func newBase(model config.ModelConfig, dbConfig config.SubConfig) (base Base) {
@@ -75,32 +79,38 @@ func runHook(action, script string) error {
 	return nil
 }
 
-// New - initialize Database
-func runModel(model config.ModelConfig, dbConfig config.SubConfig) (err error) {
-	logger := logger.Tag("Database")
-
-	base := newBase(model, dbConfig)
-	var db Database
-	switch dbConfig.Type {
+func new(base Base) Database {
+	switch base.dbConfig.Type {
 	case "mysql":
-		db = &MySQL{Base: base}
+		return &MySQL{Base: base}
 	case "mariadb":
-		db = &MariaDB{Base: base}
+		return &MariaDB{Base: base}
 	case "redis":
-		db = &Redis{Base: base}
+		return &Redis{Base: base}
 	case "postgresql":
-		db = &PostgreSQL{Base: base}
+		return &PostgreSQL{Base: base}
 	case "mongodb":
-		db = &MongoDB{Base: base}
+		return &MongoDB{Base: base}
 	case "sqlite":
-		db = &SQLite{Base: base}
+		return &SQLite{Base: base}
 	case "mssql":
-		db = &MSSQL{Base: base}
+		return &MSSQL{Base: base}
 	case "influxdb2":
-		db = &InfluxDB2{Base: base}
+		return &InfluxDB2{Base: base}
 	case "etcd":
-		db = &Etcd{Base: base}
-	default:
+		return &Etcd{Base: base}
+	}
+
+	return nil
+}
+
+// New - initialize Database
+func runModel(model config.ModelConfig, dbConfig config.SubConfig) (err error) {
+	logger := logger.Tag(fmt.Sprintf("Database: %s", dbConfig.Name))
+
+	base := newBase(model, dbConfig)
+	db := new(base)
+	if db == nil {
 		logger.Warn(fmt.Errorf("model: %s databases.%s config `type: %s`, but is not implement", model.Name, dbConfig.Name, dbConfig.Type))
 		return
 	}
@@ -154,17 +164,84 @@ func runModel(model config.ModelConfig, dbConfig config.SubConfig) (err error) {
 	return
 }
 
-// Run databases
-func Run(model config.ModelConfig) error {
+// Run databases, at most `parallel_dumps` databases are dumped at the same time
+func Run(model config.ModelConfig) ([]history.Outcome, error) {
 	if len(model.Databases) == 0 {
-		return nil
+		return nil, nil
 	}
 
+	var dbConfigs []config.SubConfig
 	for _, dbCfg := range model.Databases {
-		err := runModel(model, dbCfg)
+		dbConfigs = append(dbConfigs, dbCfg)
+	}
+
+	// Stop dumping the rest after a failure, the backup fails anyway
+	outcomes := make([]history.Outcome, len(dbConfigs))
+	errs := helper.RunParallel(len(dbConfigs), model.ParallelDumps, true, func(i int) error {
+		startedAt := time.Now()
+		err := runModel(model, dbConfigs[i])
+		outcomes[i] = history.NewOutcome(dbConfigs[i].Name, dbConfigs[i].Type, startedAt, err)
+		return err
+	})
+
+	var errors []error
+	for i, err := range errs {
+		if len(outcomes[i].Status) == 0 {
+			outcomes[i] = history.Outcome{Name: dbConfigs[i].Name, Type: dbConfigs[i].Type, Status: history.StatusSkipped}
+		}
 		if err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	if len(errors) == 1 {
+		return outcomes, errors[0]
+	} else if len(errors) > 1 {
+		return outcomes, fmt.Errorf("Database errors: %v", errors)
+	}
+
+	return outcomes, nil
+}
+
+// Restore databases from the dumps in `model.DumpPath`, restore all databases when `names` is empty
+func Restore(model config.ModelConfig, names []string) error {
+	logger := logger.Tag("Database")
+
+	var dbConfigs []config.SubConfig
+	if len(names) == 0 {
+		for _, dbCfg := range model.Databases {
+			dbConfigs = append(dbConfigs, dbCfg)
+		}
+	} else {
+		for _, name := range names {
+			dbCfg := model.GetDatabaseByName(name)
+			if dbCfg == nil {
+				return fmt.Errorf("database %s not found in model %s", name, model.Name)
+			}
+			dbConfigs = append(dbConfigs, *dbCfg)
+		}
+	}
+
+	if len(dbConfigs) == 0 {
+		return fmt.Errorf("model %s has no databases to restore", model.Name)
+	}
+
+	for _, dbCfg := range dbConfigs {
+		db := new(newBase(model, dbCfg))
+		if db == nil {
+			return fmt.Errorf("model: %s databases.%s config `type: %s`, but is not implement", model.Name, dbCfg.Name, dbCfg.Type)
+		}
+
+		logger.Infof("=> restore | %v: %v", dbCfg.Type, dbCfg.Name)
+
+		if err := db.init(); err != nil {
 			return err
 		}
+
+		if err := db.restore(); err != nil {
+			return fmt.Errorf("restore %s failed: %v", dbCfg.Name, err)
+		}
+		logger.Info("Restore succeeded")
 	}
 
 	return nil
@@ -21,11 +21,15 @@ import (
 //     # depricated
 //   - endpoints: [localhost:2379, localhost:22379, localhost:32379]
 //   - args:
+//   - data_dir: data directory for `etcdctl snapshot restore`
+//   - restore_args:
 type Etcd struct {
 	Base
 	endpoint      string
 	endpoints     []string
 	args          string
+	dataDir       string
+	restoreArgs   string
 	_dumpFilePath string
 }
 
@@ -35,6 +39,8 @@ func (db *Etcd) init() (err error) {
 	db.endpoint = viper.GetString("endpoint")
 	db.endpoints = viper.GetStringSlice("endpoints")
 	db.args = viper.GetString("args")
+	db.dataDir = viper.GetString("data_dir")
+	db.restoreArgs = viper.GetString("restore_args")
 
 	if len(db.endpoint) == 0 && len(db.endpoints) == 0 {
 		return fmt.Errorf("etcd endpoint config is required")
@@ -73,6 +79,23 @@ func (db *Etcd) build() string {
 	return "etcdctl " + strings.Join(etcdctlArgs, " ")
 }
 
+func (db *Etcd) buildRestore() string {
+	var etcdctlArgs []string
+
+	etcdctlArgs = append(etcdctlArgs, "snapshot restore")
+	etcdctlArgs = append(etcdctlArgs, db._dumpFilePath)
+
+	if len(db.dataDir) > 0 {
+		etcdctlArgs = append(etcdctlArgs, "--data-dir "+db.dataDir)
+	}
+
+	if len(db.restoreArgs) > 0 {
+		etcdctlArgs = append(etcdctlArgs, db.restoreArgs)
+	}
+
+	return "etcdctl " + strings.Join(etcdctlArgs, " ")
+}
+
 func (db *Etcd) perform() error {
 	logger := logger.Tag("etcd")
 
@@ -85,3 +108,18 @@ func (db *Etcd) perform() error {
 	logger.Info("snapshot path: ", db._dumpFilePath)
 	return nil
 }
+
+func (db *Etcd) restore() error {
+	logger := logger.Tag("etcd")
+
+	if !helper.IsExistsPath(db._dumpFilePath) {
+		return fmt.Errorf("snapshot file %s not found", db._dumpFilePath)
+	}
+
+	logger.Info("-> Restoring snapshot to etcd data dir...")
+	_, err := helper.Exec(db.buildRestore())
+	if err != nil {
+		return err
+	}
+	return nil
+}
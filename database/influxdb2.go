@@ -46,8 +46,12 @@ func (db *InfluxDB2) init() (err error) {
 }
 
 func (db *InfluxDB2) influxCliArguments() []string {
+	return db.influxCliArgumentsFor("backup")
+}
+
+func (db *InfluxDB2) influxCliArgumentsFor(action string) []string {
 	args := make([]string, 0, 15)
-	args = append(args, "backup")
+	args = append(args, action)
 	args = append(args, "--host="+db.host+"")
 	args = append(args, "--token="+db.token+"")
 	if db.bucket != "" {
@@ -84,3 +88,15 @@ func (db *InfluxDB2) perform() error {
 	logger.Info("dump path:", db.dumpPath)
 	return nil
 }
+
+func (db *InfluxDB2) restore() error {
+	logger := logger.Tag("InfluxDB2")
+
+	args := db.influxCliArgumentsFor("restore")
+	out, err := helper.Exec("influx", args...)
+	if err != nil {
+		return fmt.Errorf("-> Restore error: %s", err)
+	}
+	logger.Info(out)
+	return nil
+}
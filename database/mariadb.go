@@ -96,3 +96,24 @@ func (db *MariaDB) perform() error {
 	logger.Info("dump path:", db.dumpPath)
 	return nil
 }
+
+// restore the physical backup made by `mariadb-backup`, the MariaDB server must
+// be stopped and its datadir must be empty before `--copy-back`.
+func (db *MariaDB) buildRestore() []string {
+	return []string{
+		"mariadb-backup --prepare --target-dir=" + db.dumpPath,
+		"mariadb-backup --copy-back --target-dir=" + db.dumpPath,
+	}
+}
+
+func (db *MariaDB) restore() error {
+	logger := logger.Tag("MariaDB")
+
+	logger.Info("-> Restoring MariaDB from", db.dumpPath)
+	for _, command := range db.buildRestore() {
+		if _, err := helper.Exec(command); err != nil {
+			return fmt.Errorf("-> Restore error: %s", err)
+		}
+	}
+	return nil
+}
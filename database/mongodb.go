@@ -22,6 +22,7 @@ import (
 // exclude_tables_prefix:
 // oplog: false
 // args:
+// restore_args:
 type MongoDB struct {
 	Base
 	uri                 string
@@ -35,10 +36,12 @@ type MongoDB struct {
 	excludeTablesPrefix []string
 	oplog               bool
 	args                string
+	restoreArgs         string
 }
 
 var (
-	mongodumpCli = "mongodump"
+	mongodumpCli    = "mongodump"
+	mongorestoreCli = "mongorestore"
 )
 
 func (db *MongoDB) init() (err error) {
@@ -58,6 +61,7 @@ func (db *MongoDB) init() (err error) {
 	db.excludeTables = viper.GetStringSlice("exclude_tables")
 	db.excludeTablesPrefix = viper.GetStringSlice("exclude_tables_prefix")
 	db.args = viper.GetString("args")
+	db.restoreArgs = viper.GetString("restore_args")
 
 	return nil
 }
@@ -77,6 +81,24 @@ func (db *MongoDB) build() string {
 		"--out=" + db.dumpPath
 }
 
+func (db *MongoDB) buildRestore() string {
+	opts := []string{mongorestoreCli}
+	if len(db.uri) > 0 {
+		opts = append(opts, "--uri="+db.uri)
+	} else {
+		opts = append(opts, db.credentialOptions(), db.connectivityOptions())
+	}
+	if db.oplog {
+		opts = append(opts, "--oplogReplay")
+	}
+	if len(db.restoreArgs) > 0 {
+		opts = append(opts, db.restoreArgs)
+	}
+	opts = append(opts, "--dir="+db.dumpPath)
+
+	return strings.Join(opts, " ")
+}
+
 func (db *MongoDB) nameOption() string {
 	return "--db=" + db.database
 }
@@ -139,3 +161,15 @@ func (db *MongoDB) perform() error {
 	logger.Info("dump path:", db.dumpPath)
 	return nil
 }
+
+func (db *MongoDB) restore() error {
+	logger := logger.Tag("MongoDB")
+
+	logger.Info("-> Restoring MongoDB from", db.dumpPath)
+	out, err := helper.Exec(db.buildRestore())
+	if err != nil {
+		return fmt.Errorf("-> Restore error: %s", err)
+	}
+	logger.Info(out)
+	return nil
+}
@@ -18,6 +18,7 @@ import (
 // password:
 // trustServerCertificate:
 // args:
+// restore_args:
 type MSSQL struct {
 	Base
 	host                   string
@@ -27,6 +28,7 @@ type MSSQL struct {
 	password               string
 	trustServerCertificate bool
 	args                   string
+	restoreArgs            string
 }
 
 var (
@@ -47,6 +49,7 @@ func (db *MSSQL) init() (err error) {
 	db.password = viper.GetString("password")
 	db.trustServerCertificate = viper.GetBool("trustServerCertificate")
 	db.args = viper.GetString("args")
+	db.restoreArgs = viper.GetString("restore_args")
 
 	return nil
 }
@@ -61,6 +64,30 @@ func (db *MSSQL) build() string {
 		"/TargetFile:" + db.dumpPath + "/" + db.database + ".bacpac"
 }
 
+func (db *MSSQL) buildRestore() string {
+	opts := []string{
+		sqlpackageCli,
+		"/Action:Import",
+		"/TargetDatabaseName:" + db.database,
+	}
+	if len(db.username) > 0 {
+		opts = append(opts, "/TargetUser:"+db.username)
+	}
+	if len(db.password) > 0 {
+		opts = append(opts, "/TargetPassword:"+db.password)
+	}
+	opts = append(opts, "/TargetServerName:"+db.serverName())
+	if db.trustServerCertificate {
+		opts = append(opts, "/TargetTrustServerCertificate:True")
+	}
+	if len(db.restoreArgs) > 0 {
+		opts = append(opts, db.restoreArgs)
+	}
+	opts = append(opts, "/SourceFile:"+db.dumpPath+"/"+db.database+".bacpac")
+
+	return strings.Join(opts, " ")
+}
+
 func (db *MSSQL) nameOption() string {
 	return "/SourceDatabaseName:" + db.database
 }
@@ -77,6 +104,10 @@ func (db *MSSQL) credentialOptions() string {
 }
 
 func (db *MSSQL) connectivityOptions() string {
+	return "/SourceServerName:" + db.serverName()
+}
+
+func (db *MSSQL) serverName() string {
 	var host = db.host
 	var port = db.port
 
@@ -87,7 +118,7 @@ func (db *MSSQL) connectivityOptions() string {
 		port = "1433"
 	}
 
-	return "/SourceServerName:" + host + "," + port
+	return host + "," + port
 }
 
 func (db *MSSQL) additionOption() string {
@@ -114,3 +145,14 @@ func (db *MSSQL) perform() error {
 	logger.Info("dump path:", db.dumpPath)
 	return nil
 }
+
+func (db *MSSQL) restore() error {
+	logger := logger.Tag("MSSQL")
+
+	out, err := helper.Exec(db.buildRestore())
+	if err != nil {
+		return fmt.Errorf("-> Restore error: %s", err)
+	}
+	logger.Info(out)
+	return nil
+}
@@ -94,3 +94,19 @@ func TestMSSQL_sqlpackage(t *testing.T) {
 	}
 	assert.Equal(t, db.build(), "sqlpackage /Action:Export /SourceDatabaseName:test /SourceUser:testUser /SourcePassword:xxxYYY2$ /SourceServerName:127.0.0.1,1433 /SourceTrustServerCertificate:True /TargetFile:/tmp/gobackup/test/test.bacpac")
 }
+
+func TestMSSQL_buildRestore(t *testing.T) {
+	base := Base{
+		dumpPath: "/tmp/gobackup/test",
+	}
+	db := &MSSQL{
+		Base:                   base,
+		host:                   "127.0.0.1",
+		port:                   "1433",
+		database:               "test",
+		username:               "testUser",
+		password:               "xxxYYY2$",
+		trustServerCertificate: true,
+	}
+	assert.Equal(t, db.buildRestore(), "sqlpackage /Action:Import /TargetDatabaseName:test /TargetUser:testUser /TargetPassword:xxxYYY2$ /TargetServerName:127.0.0.1,1433 /TargetTrustServerCertificate:True /SourceFile:/tmp/gobackup/test/test.bacpac")
+}
@@ -19,6 +19,7 @@ import (
 // username: root
 // password:
 // args:
+// restore_args:
 type MySQL struct {
 	Base
 	host          string
@@ -30,6 +31,7 @@ type MySQL struct {
 	tables        []string
 	excludeTables []string
 	args          string
+	restoreArgs   string
 }
 
 func (db *MySQL) init() (err error) {
@@ -51,6 +53,7 @@ func (db *MySQL) init() (err error) {
 	if len(viper.GetString("args")) > 0 {
 		db.args = viper.GetString("args")
 	}
+	db.restoreArgs = viper.GetString("restore_args")
 
 	// mysqldump command
 	if len(db.database) == 0 {
@@ -66,23 +69,32 @@ func (db *MySQL) init() (err error) {
 	return nil
 }
 
-func (db *MySQL) build() string {
-	dumpArgs := []string{}
+func (db *MySQL) connectionArgs() []string {
+	args := []string{}
 	if len(db.host) > 0 {
-		dumpArgs = append(dumpArgs, "--host", db.host)
+		args = append(args, "--host", db.host)
 	}
 	if len(db.port) > 0 {
-		dumpArgs = append(dumpArgs, "--port", db.port)
+		args = append(args, "--port", db.port)
 	}
 	if len(db.socket) > 0 {
-		dumpArgs = append(dumpArgs, "--socket", db.socket)
+		args = append(args, "--socket", db.socket)
 	}
 	if len(db.username) > 0 {
-		dumpArgs = append(dumpArgs, "-u", db.username)
+		args = append(args, "-u", db.username)
 	}
 	if len(db.password) > 0 {
-		dumpArgs = append(dumpArgs, `-p`+db.password)
+		args = append(args, `-p`+db.password)
 	}
+	return args
+}
+
+func (db *MySQL) dumpFilePath() string {
+	return path.Join(db.dumpPath, db.database+".sql")
+}
+
+func (db *MySQL) dumpArgs() []string {
+	dumpArgs := db.connectionArgs()
 
 	for _, table := range db.excludeTables {
 		dumpArgs = append(dumpArgs, "--ignore-table="+db.database+"."+table)
@@ -97,12 +109,33 @@ func (db *MySQL) build() string {
 		dumpArgs = append(dumpArgs, db.tables...)
 	}
 
-	dumpFilePath := path.Join(db.dumpPath, db.database+".sql")
-	dumpArgs = append(dumpArgs, "--result-file="+dumpFilePath)
+	return dumpArgs
+}
+
+func (db *MySQL) build() string {
+	dumpArgs := db.dumpArgs()
+	dumpArgs = append(dumpArgs, "--result-file="+db.dumpFilePath())
 
 	return "mysqldump" + " " + strings.Join(dumpArgs, " ")
 }
 
+// buildStream dump to stdout without `--result-file`
+func (db *MySQL) buildStream() (string, []string, string) {
+	return "mysqldump" + " " + strings.Join(db.dumpArgs(), " "), nil, db.database + ".sql"
+}
+
+func (db *MySQL) buildRestore() string {
+	restoreArgs := db.connectionArgs()
+
+	if len(db.restoreArgs) > 0 {
+		restoreArgs = append(restoreArgs, db.restoreArgs)
+	}
+
+	restoreArgs = append(restoreArgs, db.database)
+
+	return "mysql" + " " + strings.Join(restoreArgs, " ")
+}
+
 func (db *MySQL) perform() error {
 	logger := logger.Tag("MySQL")
 
@@ -114,3 +147,19 @@ func (db *MySQL) perform() error {
 	logger.Info("dump path:", db.dumpPath)
 	return nil
 }
+
+func (db *MySQL) restore() error {
+	logger := logger.Tag("MySQL")
+
+	dumpFilePath := db.dumpFilePath()
+	if !helper.IsExistsPath(dumpFilePath) {
+		return fmt.Errorf("dump file %s not found", dumpFilePath)
+	}
+
+	logger.Info("-> Restoring MySQL from", dumpFilePath)
+	_, err := helper.Exec(db.buildRestore(), "-e", "source "+dumpFilePath)
+	if err != nil {
+		return fmt.Errorf("-> Restore error: %s", err)
+	}
+	return nil
+}
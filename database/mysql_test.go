@@ -43,6 +43,37 @@ func TestMySQL_init(t *testing.T) {
 	assert.Equal(t, script, "mysqldump --host 1.2.3.4 --port 1234 -u user1 -ppass1 --ignore-table=my_db.aa --ignore-table=my_db.bb --a1 --a2 --a3 my_db foo bar --result-file=/data/backups/mysql/mysql1/my_db.sql")
 }
 
+func TestMySQL_buildRestore(t *testing.T) {
+	viper := viper.New()
+	viper.Set("host", "1.2.3.4")
+	viper.Set("port", "1234")
+	viper.Set("database", "my_db")
+	viper.Set("username", "user1")
+	viper.Set("password", "pass1")
+	viper.Set("args", "--single-transaction")
+	viper.Set("restore_args", "--force")
+
+	base := newBase(
+		config.ModelConfig{
+			DumpPath: "/data/backups",
+		},
+		config.SubConfig{
+			Type:  "mysql",
+			Name:  "mysql1",
+			Viper: viper,
+		},
+	)
+
+	db := &MySQL{
+		Base: base,
+	}
+
+	err := db.init()
+	assert.NoError(t, err)
+	assert.Equal(t, db.buildRestore(), "mysql --host 1.2.3.4 --port 1234 -u user1 -ppass1 --force my_db")
+	assert.Equal(t, db.dumpFilePath(), "/data/backups/mysql/mysql1/my_db.sql")
+}
+
 func TestMySQL_dumpArgsWithAdditionalOptions(t *testing.T) {
 	base := newBase(
 		config.ModelConfig{
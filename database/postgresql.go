@@ -28,6 +28,7 @@ import (
 //   - tables:
 //   - exclude_tables:
 //   - args:
+//   - restore_args:
 type PostgreSQL struct {
 	Base
 	host          string
@@ -39,6 +40,7 @@ type PostgreSQL struct {
 	excludeTables []string
 	password      string
 	args          string
+	restoreArgs   string
 	_dumpFilePath string
 }
 
@@ -56,6 +58,7 @@ func (db *PostgreSQL) init() (err error) {
 	db.tables = viper.GetStringSlice("tables")
 	db.excludeTables = viper.GetStringSlice("exclude_tables")
 	db.args = viper.GetString("args")
+	db.restoreArgs = viper.GetString("restore_args")
 
 	if len(db.database) == 0 {
 		return fmt.Errorf("PostgreSQL database config is required")
@@ -72,25 +75,30 @@ func (db *PostgreSQL) init() (err error) {
 	return nil
 }
 
-func (db *PostgreSQL) build() string {
-	// pg_dump command
-	var dumpArgs []string
+func (db *PostgreSQL) connectionArgs() []string {
+	var args []string
 
 	if len(db.host) > 0 {
-		dumpArgs = append(dumpArgs, "--host="+db.host)
+		args = append(args, "--host="+db.host)
 	}
 	if len(db.port) > 0 {
-		dumpArgs = append(dumpArgs, "--port="+db.port)
+		args = append(args, "--port="+db.port)
 	}
 	if len(db.socket) > 0 {
 		host := filepath.Dir(db.socket)
 		port := strings.TrimPrefix(filepath.Ext(db.socket), ".")
-		dumpArgs = append(dumpArgs, "--host="+host, "--port="+port)
+		args = append(args, "--host="+host, "--port="+port)
 	}
 	if len(db.username) > 0 {
-		dumpArgs = append(dumpArgs, "--username="+db.username)
+		args = append(args, "--username="+db.username)
 	}
 
+	return args
+}
+
+func (db *PostgreSQL) dumpArgs() []string {
+	dumpArgs := db.connectionArgs()
+
 	// include / exclude tables
 	if len(db.tables) > 0 {
 		dumpArgs = append(dumpArgs, "--table="+strings.Join(db.tables, " --table="))
@@ -105,23 +113,72 @@ func (db *PostgreSQL) build() string {
 	}
 
 	dumpArgs = append(dumpArgs, db.database)
+
+	return dumpArgs
+}
+
+// env pass the password by environment to the command only, databases may be dumped in parallel
+func (db *PostgreSQL) env() []string {
+	if len(db.password) > 0 {
+		return []string{"PGPASSWORD=" + db.password}
+	}
+	return nil
+}
+
+func (db *PostgreSQL) build() string {
+	// pg_dump command
+	dumpArgs := db.dumpArgs()
 	dumpArgs = append(dumpArgs, "-f", db._dumpFilePath)
 
 	return "pg_dump " + strings.Join(dumpArgs, " ")
 }
 
+// buildStream dump to stdout without `-f`
+func (db *PostgreSQL) buildStream() (string, []string, string) {
+	if len(db.password) > 0 {
+		os.Setenv("PGPASSWORD", db.password)
+	}
+
+	return "pg_dump " + strings.Join(db.dumpArgs(), " "), nil, filepath.Base(db._dumpFilePath)
+}
+
+func (db *PostgreSQL) buildRestore() string {
+	// psql command
+	restoreArgs := db.connectionArgs()
+
+	if len(db.restoreArgs) > 0 {
+		restoreArgs = append(restoreArgs, db.restoreArgs)
+	}
+
+	restoreArgs = append(restoreArgs, "--dbname="+db.database)
+	restoreArgs = append(restoreArgs, "-f", db._dumpFilePath)
+
+	return "psql " + strings.Join(restoreArgs, " ")
+}
+
 func (db *PostgreSQL) perform() error {
 	logger := logger.Tag("PostgreSQL")
 
 	logger.Info("-> Dumping PostgreSQL...")
-	if len(db.password) > 0 {
-		os.Setenv("PGPASSWORD", db.password)
+	_, err := helper.ExecWithEnv(db.build(), db.env())
+	if err != nil {
+		return err
 	}
+	logger.Info("dump path:", db._dumpFilePath)
+	return nil
+}
 
-	_, err := helper.Exec(db.build())
+func (db *PostgreSQL) restore() error {
+	logger := logger.Tag("PostgreSQL")
+
+	if !helper.IsExistsPath(db._dumpFilePath) {
+		return fmt.Errorf("dump file %s not found", db._dumpFilePath)
+	}
+
+	logger.Info("-> Restoring PostgreSQL from", db._dumpFilePath)
+	_, err := helper.ExecWithEnv(db.buildRestore(), db.env())
 	if err != nil {
 		return err
 	}
-	logger.Info("dump path:", db._dumpFilePath)
 	return nil
 }
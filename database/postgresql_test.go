@@ -41,6 +41,20 @@ func TestPostgreSQL_init(t *testing.T) {
 	assert.Equal(t, db.build(), "pg_dump --host=1.2.3.4 --port=1234 --username=user1 --table=foo --table=bar --exclude-table=aa --exclude-table=bb --foo --bar --dar my_db -f /data/backups/postgresql/postgresql1/my_db.sql")
 }
 
+func TestPostgreSQL_buildRestore(t *testing.T) {
+	db := &PostgreSQL{
+		database:      "foo",
+		host:          "1.2.3.4",
+		port:          "5432",
+		username:      "user1",
+		args:          "--foo",
+		restoreArgs:   "-v ON_ERROR_STOP=1",
+		_dumpFilePath: "/tmp/foo.sql",
+	}
+
+	assert.Equal(t, db.buildRestore(), "psql --host=1.2.3.4 --port=5432 --username=user1 -v ON_ERROR_STOP=1 --dbname=foo -f /tmp/foo.sql")
+}
+
 func Test_PostgreSQL_prepareForSocket(t *testing.T) {
 	db := &PostgreSQL{
 		database:      "foo",
@@ -181,3 +181,21 @@ func (db *Redis) copy() error {
 	}
 	return nil
 }
+
+// restore copy the RDB file back to `rdb_path`, Redis loads it on the next start,
+// so it should be stopped before restore.
+func (db *Redis) restore() error {
+	logger := logger.Tag("Redis")
+
+	if !helper.IsExistsPath(db._dumpFilePath) {
+		return fmt.Errorf("dump file %s not found", db._dumpFilePath)
+	}
+
+	logger.Info("Copying redis dump back to", db.rdbPath)
+	_, err := helper.Exec("cp", db._dumpFilePath, db.rdbPath)
+	if err != nil {
+		return fmt.Errorf("copy redis dump file error: %s", err)
+	}
+	logger.Warn("Restart Redis to load the restored RDB file")
+	return nil
+}
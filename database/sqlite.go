@@ -46,6 +46,18 @@ func (db *SQLite) buildArgs() []string {
 	return args
 }
 
+func (db *SQLite) buildRestoreArgs() []string {
+	args := []string{
+		db.path,
+		fmt.Sprintf(".read %s", db._dumpFilePath),
+	}
+	return args
+}
+
+func (db *SQLite) buildStream() (string, []string, string) {
+	return "sqlite3", []string{db.path, ".dump"}, filepath.Base(db._dumpFilePath)
+}
+
 func (db *SQLite) perform() error {
 	logger := logger.Tag("SQLite")
 
@@ -57,3 +69,17 @@ func (db *SQLite) perform() error {
 	logger.Info("dump path:", db._dumpFilePath)
 	return nil
 }
+
+func (db *SQLite) restore() error {
+	logger := logger.Tag("SQLite")
+
+	if !helper.IsExistsPath(db._dumpFilePath) {
+		return fmt.Errorf("dump file %s not found", db._dumpFilePath)
+	}
+
+	logger.Info("-> Restoring SQLite into", db.path)
+	if _, err := helper.Exec("sqlite3", db.buildRestoreArgs()...); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/history"
+	"github.com/gigcodes/launch-util/logger"
+)
+
+// Streamer is implemented by the databases which are able to dump to stdout for stream mode
+type Streamer interface {
+	// buildStream return the dump command and args which write the dump to stdout,
+	// and the file name of the dump in perform mode
+	buildStream() (command string, args []string, fileName string)
+}
+
+// Stream dump databases to stdout and pass each dump to `onDump` with the dump name,
+// the name has the same layout as perform mode, e.g. `mysql/mysql1/my_db.sql`
+func Stream(model config.ModelConfig, onDump func(name string, r io.Reader) error) ([]history.Outcome, error) {
+	var outcomes []history.Outcome
+	for _, dbCfg := range model.Databases {
+		startedAt := time.Now()
+		err := streamDatabase(model, dbCfg, onDump)
+		outcomes = append(outcomes, history.NewOutcome(dbCfg.Name, dbCfg.Type, startedAt, err))
+		if err != nil {
+			return outcomes, err
+		}
+	}
+
+	return outcomes, nil
+}
+
+// streamDatabase dump the database to `onDump`
+func streamDatabase(model config.ModelConfig, dbCfg config.SubConfig, onDump func(name string, r io.Reader) error) error {
+	logger := logger.Tag("Database")
+
+	base := newBase(model, dbCfg)
+	db := new(base)
+	if db == nil {
+		return fmt.Errorf("model: %s databases.%s config `type: %s`, but is not implement", model.Name, dbCfg.Name, dbCfg.Type)
+	}
+
+	streamer, ok := db.(Streamer)
+	if !ok {
+		return fmt.Errorf("database %s `type: %s` does not support stream mode", dbCfg.Name, dbCfg.Type)
+	}
+
+	if err := db.init(); err != nil {
+		return err
+	}
+
+	logger.Infof("=> database | %v: %v (stream)", dbCfg.Type, base.name)
+
+	beforeScript := dbCfg.Viper.GetString("before_script")
+	if err := runHook("dump before_script", beforeScript); err != nil {
+		return err
+	}
+
+	command, args, fileName := streamer.buildStream()
+	name := path.Join(dbCfg.Type, base.name, fileName)
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(helper.ExecWithWriter(command, pw, args...))
+	}()
+
+	if err := onDump(name, pr); err != nil {
+		pr.CloseWithError(err)
+		return fmt.Errorf("stream %s failed: %v", dbCfg.Name, err)
+	}
+	logger.Info("Dump succeeded")
+
+	afterScript := dbCfg.Viper.GetString("after_script")
+	if err := runHook("dump after_script", afterScript); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package encryptor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gigcodes/launch-util/helper"
+)
+
+// Age encryptor, encrypt to age recipients (https://age-encryption.org)
+//
+// type: age
+// recipients:
+//   - age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+//
+// recipients_file:
+// identity_file: only used for decrypt
+type Age struct {
+	Base
+	recipients     []string
+	recipientsFile string
+	identityFile   string
+}
+
+func (enc *Age) init() error {
+	viper := enc.viper
+
+	enc.recipients = viper.GetStringSlice("recipients")
+	enc.recipientsFile = helper.ExplandHome(viper.GetString("recipients_file"))
+	enc.identityFile = helper.ExplandHome(viper.GetString("identity_file"))
+
+	return nil
+}
+
+func (enc *Age) ext() string {
+	return ".age"
+}
+
+func (enc *Age) options() (opts []string) {
+	opts = append(opts, "--encrypt")
+	for _, recipient := range enc.recipients {
+		opts = append(opts, "--recipient", recipient)
+	}
+	if len(enc.recipientsFile) > 0 {
+		opts = append(opts, "--recipients-file", enc.recipientsFile)
+	}
+	return
+}
+
+func (enc *Age) perform() (encryptPath string, err error) {
+	if err = enc.init(); err != nil {
+		return
+	}
+
+	if len(enc.recipients) == 0 && len(enc.recipientsFile) == 0 {
+		return "", fmt.Errorf("age `recipients` or `recipients_file` is required")
+	}
+
+	encryptPath = enc.archivePath + enc.ext()
+
+	opts := enc.options()
+	opts = append(opts, "--output", encryptPath, enc.archivePath)
+
+	_, err = helper.Exec("age", opts...)
+	return
+}
+
+func (enc *Age) decrypt() (archivePath string, err error) {
+	if err = enc.init(); err != nil {
+		return
+	}
+
+	if len(enc.identityFile) == 0 {
+		return "", fmt.Errorf("age `identity_file` is required to decrypt")
+	}
+
+	archivePath = strings.TrimSuffix(enc.archivePath, enc.ext())
+
+	opts := []string{"--decrypt", "--identity", enc.identityFile, "--output", archivePath, enc.archivePath}
+
+	_, err = helper.Exec("age", opts...)
+	return
+}
@@ -0,0 +1,105 @@
+package encryptor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/logger"
+)
+
+// Base encryptor
+type Base struct {
+	model       config.ModelConfig
+	viper       *viper.Viper
+	archivePath string
+}
+
+// Encryptor interface
+type Encryptor interface {
+	// Encrypt the archive, return the encrypted file path
+	perform() (encryptPath string, err error)
+	// Decrypt the encrypted archive, return the decrypted file path
+	decrypt() (archivePath string, err error)
+	// File extension of the encrypted file
+	ext() string
+}
+
+func newBase(archivePath string, model config.ModelConfig) (base Base) {
+	base = Base{
+		archivePath: archivePath,
+		model:       model,
+		viper:       model.EncryptWith.Viper,
+	}
+	return
+}
+
+func new(base Base) (Encryptor, error) {
+	switch base.model.EncryptWith.Type {
+	case "openssl":
+		return &OpenSSL{Base: base}, nil
+	case "gpg":
+		return &GPG{Base: base}, nil
+	case "age":
+		return &Age{Base: base}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported encrypt type: %s", base.model.EncryptWith.Type)
+}
+
+// Run encryptor, return the encrypted file path, or the archive path when `encrypt_with` is not configured
+func Run(archivePath string, model config.ModelConfig) (string, error) {
+	logger := logger.Tag("Encryptor")
+
+	if len(model.EncryptWith.Type) == 0 {
+		return archivePath, nil
+	}
+
+	enc, err := new(newBase(archivePath, model))
+	if err != nil {
+		return "", err
+	}
+
+	logger.Info("=> Encrypt | " + model.EncryptWith.Type)
+	encryptPath, err := enc.perform()
+	if err != nil {
+		return "", err
+	}
+	logger.Info("->", encryptPath)
+
+	// save Extension
+	model.Viper.Set("Ext", model.Viper.GetString("Ext")+enc.ext())
+
+	return encryptPath, nil
+}
+
+// Decrypt the archive which was encrypted by `encrypt_with`, return the decrypted file path.
+// The archive will be returned as is when it doesn't have the extension of the encryptor.
+func Decrypt(archivePath string, model config.ModelConfig) (string, error) {
+	logger := logger.Tag("Encryptor")
+
+	if len(model.EncryptWith.Type) == 0 {
+		return archivePath, nil
+	}
+
+	enc, err := new(newBase(archivePath, model))
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasSuffix(archivePath, enc.ext()) {
+		logger.Warnf("%s is not encrypted by %s, skip decrypt", archivePath, model.EncryptWith.Type)
+		return archivePath, nil
+	}
+
+	logger.Info("=> Decrypt | " + model.EncryptWith.Type)
+	decryptPath, err := enc.decrypt()
+	if err != nil {
+		return "", err
+	}
+	logger.Info("->", decryptPath)
+
+	return decryptPath, nil
+}
@@ -0,0 +1,24 @@
+package encryptor
+
+import (
+	"testing"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/longbridgeapp/assert"
+)
+
+func TestRun(t *testing.T) {
+	// without encrypt_with
+	archivePath, err := Run("/tmp/foo.tar.gz", config.ModelConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, archivePath, "/tmp/foo.tar.gz")
+
+	_, err = Run("/tmp/foo.tar.gz", config.ModelConfig{EncryptWith: config.SubConfig{Type: "foo"}})
+	assert.EqualError(t, err, "unsupported encrypt type: foo")
+}
+
+func TestDecrypt(t *testing.T) {
+	archivePath, err := Decrypt("/tmp/foo.tar.gz", config.ModelConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, archivePath, "/tmp/foo.tar.gz")
+}
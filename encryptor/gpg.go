@@ -0,0 +1,94 @@
+package encryptor
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gigcodes/launch-util/helper"
+)
+
+// GPG encryptor, encrypt to OpenPGP public-key recipients
+//
+// type: gpg
+// recipients:
+//   - backup@example.com
+//
+// homedir: ~/.gnupg
+// passphrase: passphrase of the secret key, only used for decrypt
+// args:
+type GPG struct {
+	Base
+	recipients []string
+	homedir    string
+	passphrase string
+	args       string
+}
+
+func (enc *GPG) init() error {
+	viper := enc.viper
+
+	enc.recipients = viper.GetStringSlice("recipients")
+	enc.homedir = helper.ExplandHome(viper.GetString("homedir"))
+	enc.passphrase = viper.GetString("passphrase")
+	enc.args = viper.GetString("args")
+
+	return nil
+}
+
+func (enc *GPG) ext() string {
+	return ".gpg"
+}
+
+func (enc *GPG) options() (opts []string) {
+	opts = append(opts, "--batch", "--yes")
+	if len(enc.homedir) > 0 {
+		opts = append(opts, "--homedir", enc.homedir)
+	}
+	if len(enc.args) > 0 {
+		opts = append(opts, strings.Fields(enc.args)...)
+	}
+	return
+}
+
+func (enc *GPG) perform() (encryptPath string, err error) {
+	if err = enc.init(); err != nil {
+		return
+	}
+
+	if len(enc.recipients) == 0 {
+		return "", fmt.Errorf("gpg `recipients` is required")
+	}
+
+	encryptPath = enc.archivePath + enc.ext()
+
+	opts := enc.options()
+	opts = append(opts, "--trust-model", "always", "--encrypt")
+	for _, recipient := range enc.recipients {
+		opts = append(opts, "--recipient", recipient)
+	}
+	opts = append(opts, "--output", encryptPath, enc.archivePath)
+
+	_, err = helper.Exec("gpg", opts...)
+	return
+}
+
+func (enc *GPG) decrypt() (archivePath string, err error) {
+	if err = enc.init(); err != nil {
+		return
+	}
+
+	archivePath = strings.TrimSuffix(enc.archivePath, enc.ext())
+
+	// The passphrase is read from the standard input, so it is not in the arguments
+	var stdin io.Reader
+	opts := enc.options()
+	if len(enc.passphrase) > 0 {
+		opts = append(opts, "--pinentry-mode", "loopback", "--passphrase-fd", "0")
+		stdin = strings.NewReader(enc.passphrase + "\n")
+	}
+	opts = append(opts, "--decrypt", "--output", archivePath, enc.archivePath)
+
+	_, err = helper.ExecWithStdin("gpg", stdin, opts...)
+	return
+}
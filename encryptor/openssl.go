@@ -0,0 +1,136 @@
+package encryptor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+)
+
+// openSSLPasswordEnv passes the password to `openssl enc` by `-pass env:`, so it is not in the arguments
+const openSSLPasswordEnv = "LAUNCH_OPENSSL_PASSWORD"
+
+// OpenSSL encryptor, compatible with `openssl enc`
+//
+// type: openssl
+// cipher: aes-256-cbc (the AEAD ciphers like aes-256-gcm are not supported by `openssl enc`, use age for them)
+// password:
+// password_file: read the passphrase from the first line of the file
+// salt: true
+// pbkdf2: true
+// iter: 10000
+// base64: false
+// args:
+//
+// Decrypt with:
+//
+//	openssl enc -d -aes-256-cbc -salt -pbkdf2 -iter 10000 -in backup.tar.gz.enc -out backup.tar.gz
+type OpenSSL struct {
+	Base
+	cipher       string
+	password     string
+	passwordFile string
+	salt         bool
+	pbkdf2       bool
+	iter         int
+	base64       bool
+	args         string
+}
+
+func (enc *OpenSSL) init() error {
+	viper := enc.viper
+	viper.SetDefault("cipher", "aes-256-cbc")
+	viper.SetDefault("salt", true)
+	viper.SetDefault("pbkdf2", true)
+	viper.SetDefault("iter", 10000)
+	viper.SetDefault("base64", false)
+
+	enc.cipher = strings.TrimPrefix(viper.GetString("cipher"), "-")
+	enc.password = viper.GetString("password")
+	enc.passwordFile = helper.ExplandHome(viper.GetString("password_file"))
+	enc.salt = viper.GetBool("salt")
+	enc.pbkdf2 = viper.GetBool("pbkdf2")
+	enc.iter = viper.GetInt("iter")
+	enc.base64 = viper.GetBool("base64")
+	enc.args = viper.GetString("args")
+
+	if len(enc.password) == 0 && len(enc.passwordFile) == 0 {
+		return fmt.Errorf("openssl `password` or `password_file` is required")
+	}
+
+	if err := config.ValidateOpenSSLCipher(enc.cipher); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (enc *OpenSSL) ext() string {
+	return ".enc"
+}
+
+func (enc *OpenSSL) options() (opts []string) {
+	opts = append(opts, "enc")
+	if enc.base64 {
+		opts = append(opts, "-base64")
+	}
+	if enc.salt {
+		opts = append(opts, "-salt")
+	}
+	if enc.pbkdf2 {
+		opts = append(opts, "-pbkdf2")
+		if enc.iter > 0 {
+			opts = append(opts, "-iter", fmt.Sprintf("%d", enc.iter))
+		}
+	}
+	opts = append(opts, "-"+enc.cipher)
+
+	if len(enc.passwordFile) > 0 {
+		opts = append(opts, "-pass", "file:"+enc.passwordFile)
+	} else {
+		opts = append(opts, "-pass", "env:"+openSSLPasswordEnv)
+	}
+
+	if len(enc.args) > 0 {
+		opts = append(opts, strings.Fields(enc.args)...)
+	}
+
+	return
+}
+
+// env of `openssl enc` with the password, the password file is read by openssl itself
+func (enc *OpenSSL) env() []string {
+	if len(enc.passwordFile) > 0 {
+		return nil
+	}
+	return []string{openSSLPasswordEnv + "=" + enc.password}
+}
+
+func (enc *OpenSSL) perform() (encryptPath string, err error) {
+	if err = enc.init(); err != nil {
+		return
+	}
+
+	encryptPath = enc.archivePath + enc.ext()
+
+	opts := enc.options()
+	opts = append(opts, "-in", enc.archivePath, "-out", encryptPath)
+
+	_, err = helper.ExecWithEnv("openssl", enc.env(), opts...)
+	return
+}
+
+func (enc *OpenSSL) decrypt() (archivePath string, err error) {
+	if err = enc.init(); err != nil {
+		return
+	}
+
+	archivePath = strings.TrimSuffix(enc.archivePath, enc.ext())
+
+	opts := enc.options()
+	opts = append(opts, "-d", "-in", enc.archivePath, "-out", archivePath)
+
+	_, err = helper.ExecWithEnv("openssl", enc.env(), opts...)
+	return
+}
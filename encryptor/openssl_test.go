@@ -0,0 +1,72 @@
+package encryptor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
+)
+
+func TestOpenSSL_options(t *testing.T) {
+	viper := viper.New()
+	viper.Set("password", "secret")
+	viper.Set("args", "-md sha256")
+
+	enc := &OpenSSL{Base: Base{viper: viper}}
+	assert.NoError(t, enc.init())
+	assert.Equal(t, strings.Join(enc.options(), " "), "enc -salt -pbkdf2 -iter 10000 -aes-256-cbc -pass env:LAUNCH_OPENSSL_PASSWORD -md sha256")
+	assert.Equal(t, []string{"LAUNCH_OPENSSL_PASSWORD=secret"}, enc.env())
+
+	viper.Set("password_file", "/etc/launch-agent/secret")
+	viper.Set("base64", true)
+	viper.Set("args", "")
+	assert.NoError(t, enc.init())
+	assert.Equal(t, strings.Join(enc.options(), " "), "enc -base64 -salt -pbkdf2 -iter 10000 -aes-256-cbc -pass file:/etc/launch-agent/secret")
+	assert.Nil(t, enc.env())
+}
+
+func TestOpenSSL_init(t *testing.T) {
+	viper := viper.New()
+	enc := &OpenSSL{Base: Base{viper: viper}}
+	assert.EqualError(t, enc.init(), "openssl `password` or `password_file` is required")
+
+	viper.Set("password", "secret")
+	viper.Set("cipher", "aes-256-gcm")
+	assert.EqualError(t, enc.init(), "openssl cipher aes-256-gcm is not supported, `openssl enc` does not support the AEAD ciphers, use age instead")
+}
+
+func TestOpenSSL_encryptAndDecrypt(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "2022.12.04.07.09.47.tar.gz")
+	assert.NoError(t, os.WriteFile(archivePath, []byte("hello world"), 0660))
+
+	encryptViper := viper.New()
+	encryptViper.Set("password", "secret")
+	model := config.ModelConfig{
+		Viper: viper.New(),
+		EncryptWith: config.SubConfig{
+			Type:  "openssl",
+			Viper: encryptViper,
+		},
+	}
+
+	encryptPath, err := Run(archivePath, model)
+	assert.NoError(t, err)
+	assert.Equal(t, encryptPath, archivePath+".enc")
+	assert.NoError(t, os.Remove(archivePath))
+
+	data, err := os.ReadFile(encryptPath)
+	assert.NoError(t, err)
+	assert.NotEqual(t, string(data), "hello world")
+
+	decryptPath, err := Decrypt(encryptPath, model)
+	assert.NoError(t, err)
+	assert.Equal(t, decryptPath, archivePath)
+
+	data, err = os.ReadFile(decryptPath)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), "hello world")
+}
@@ -4,11 +4,13 @@ go 1.21
 
 require (
 	cloud.google.com/go/storage v1.28.0
+	github.com/Azure/azure-sdk-for-go/sdk/azcore v1.1.4
 	github.com/Azure/azure-sdk-for-go/sdk/azidentity v1.2.0
 	github.com/Azure/azure-sdk-for-go/sdk/storage/azblob v0.6.1
 	github.com/aws/aws-sdk-go v1.34.0
 	github.com/bramvdbogaerde/go-scp v1.2.0
 	github.com/cheggaaa/pb/v3 v3.1.2
+	github.com/dsnet/compress v0.0.1
 	github.com/dustin/go-humanize v1.0.0
 	github.com/fatih/color v1.14.1
 	github.com/go-co-op/gocron v1.18.0
@@ -16,6 +18,8 @@ require (
 	github.com/hako/durafmt v0.0.0-20210608085754-5c1018a4e16b
 	github.com/jlaffaye/ftp v0.1.0
 	github.com/joho/godotenv v1.4.0
+	github.com/klauspost/compress v1.17.4
+	github.com/klauspost/pgzip v1.2.6
 	github.com/kolo/xmlrpc v0.0.0-20220921171641-a4b6fa1dd06b
 	github.com/longbridgeapp/assert v1.1.0
 	github.com/pkg/sftp v1.13.5
@@ -23,7 +27,9 @@ require (
 	github.com/shirou/gopsutil/v4 v4.24.12
 	github.com/spf13/viper v1.14.0
 	github.com/studio-b12/gowebdav v0.0.0-20221109171924-60ec5ad56012
+	github.com/ulikunitz/xz v0.5.11
 	github.com/urfave/cli/v2 v2.23.6
+	go.etcd.io/bbolt v1.3.10
 	golang.org/x/crypto v0.31.0
 	google.golang.org/api v0.103.0
 )
@@ -48,7 +54,6 @@ require (
 	cloud.google.com/go/compute v1.15.1 // indirect
 	cloud.google.com/go/compute/metadata v0.2.3 // indirect
 	cloud.google.com/go/iam v0.8.0 // indirect
-	github.com/Azure/azure-sdk-for-go/sdk/azcore v1.1.4 // indirect
 	github.com/Azure/azure-sdk-for-go/sdk/internal v1.0.1 // indirect
 	github.com/AzureAD/microsoft-authentication-library-for-go v0.7.0 // indirect
 	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
@@ -86,7 +91,7 @@ require (
 	github.com/subosito/gotenv v1.4.1 // indirect
 	github.com/xrash/smetrics v0.0.0-20201216005158-039620a65673 // indirect
 	go.opencensus.io v0.24.0 // indirect
-	golang.org/x/net v0.33.0 // indirect
+	golang.org/x/net v0.33.0
 	golang.org/x/oauth2 v0.4.0
 	golang.org/x/sync v0.10.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
@@ -0,0 +1,26 @@
+package helper
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// FileChecksum return the hex encoded SHA-256 and MD5 of the file
+func FileChecksum(filePath string) (sha256sum string, md5sum string, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	sha256Hash := sha256.New()
+	md5Hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(sha256Hash, md5Hash), f); err != nil {
+		return "", "", err
+	}
+
+	return hex.EncodeToString(sha256Hash.Sum(nil)), hex.EncodeToString(md5Hash.Sum(nil)), nil
+}
@@ -0,0 +1,22 @@
+package helper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/longbridgeapp/assert"
+)
+
+func TestFileChecksum(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "foo.tar.gz")
+	assert.NoError(t, os.WriteFile(filePath, []byte("hello"), 0660))
+
+	sha256sum, md5sum, err := FileChecksum(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", sha256sum)
+	assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", md5sum)
+
+	_, _, err = FileChecksum(filepath.Join(t.TempDir(), "not-found"))
+	assert.Error(t, err)
+}
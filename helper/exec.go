@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"regexp"
@@ -22,6 +23,43 @@ func Exec(command string, args ...string) (output string, err error) {
 }
 
 func ExecWithStdio(command string, stdout bool, args ...string) (output string, err error) {
+	var stdOut bytes.Buffer
+	if stdout {
+		err = ExecWithWriter(command, os.Stdout, args...)
+	} else {
+		err = ExecWithWriter(command, &stdOut, args...)
+	}
+	output = strings.Trim(stdOut.String(), "\n")
+
+	return
+}
+
+// ExecWithEnv run cli commands with extra environment variables (KEY=value) for this command only,
+// which is safe to run in goroutines unlike os.Setenv
+func ExecWithEnv(command string, env []string, args ...string) (output string, err error) {
+	var stdOut bytes.Buffer
+	err = execCommand(command, env, nil, &stdOut, args...)
+	output = strings.Trim(stdOut.String(), "\n")
+
+	return
+}
+
+// ExecWithStdin run cli commands with `stdin` as the standard input, used to pass the secrets
+// which should not be in the arguments, e.g. `gpg --passphrase-fd 0`
+func ExecWithStdin(command string, stdin io.Reader, args ...string) (output string, err error) {
+	var stdOut bytes.Buffer
+	err = execCommand(command, nil, stdin, &stdOut, args...)
+	output = strings.Trim(stdOut.String(), "\n")
+
+	return
+}
+
+// ExecWithWriter run cli commands and write the stdout to `w`, used to stream the output
+func ExecWithWriter(command string, w io.Writer, args ...string) (err error) {
+	return execCommand(command, nil, nil, w, args...)
+}
+
+func execCommand(command string, env []string, stdin io.Reader, w io.Writer, args ...string) (err error) {
 	commands := spaceRegexp.Split(command, -1)
 	command = commands[0]
 	commandArgs := []string{}
@@ -34,28 +72,24 @@ func ExecWithStdio(command string, stdout bool, args ...string) (output string,
 
 	fullCommand, err := exec.LookPath(command)
 	if err != nil {
-		return "", fmt.Errorf("%s cannot be found", command)
+		return fmt.Errorf("%s cannot be found", command)
 	}
 
 	cmd := exec.Command(fullCommand, commandArgs...)
-	cmd.Env = os.Environ()
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = stdin
 
 	var stdErr bytes.Buffer
-	var stdOut bytes.Buffer
 	cmd.Stderr = &stdErr
-
-	if stdout {
-		cmd.Stdout = os.Stdout
-	} else {
-		cmd.Stdout = &stdOut
-	}
+	cmd.Stdout = w
 
 	err = cmd.Run()
 	if err != nil {
 		logger.Debug(fullCommand, " ", strings.Join(commandArgs, " "))
-		err = errors.New(stdErr.String())
+		if stdErr.Len() > 0 {
+			err = errors.New(stdErr.String())
+		}
 	}
-	output = strings.Trim(stdOut.String(), "\n")
 
 	return
 }
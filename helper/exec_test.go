@@ -1,6 +1,7 @@
 package helper
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/longbridgeapp/assert"
@@ -38,3 +39,19 @@ func TestExecWithStdio(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Empty(t, out)
 }
+
+func TestExecWithEnv(t *testing.T) {
+	out, err := ExecWithEnv("sh -c", []string{"LAUNCH_TEST_ENV=hello"}, "echo $LAUNCH_TEST_ENV")
+	assert.Nil(t, err)
+	assert.Equal(t, out, "hello")
+
+	out, err = Exec("sh -c", "echo $LAUNCH_TEST_ENV")
+	assert.Nil(t, err)
+	assert.Equal(t, out, "")
+}
+
+func TestExecWithStdin(t *testing.T) {
+	out, err := ExecWithStdin("head -n1", strings.NewReader("secret\nfoo\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, out, "secret")
+}
@@ -0,0 +1,37 @@
+package helper
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+const unixPrefix = "unix://"
+
+// Listen on a TCP address `127.0.0.1:2703`, or a unix socket `unix:///var/run/launch-agent.sock`,
+// the socket left by the last run is removed.
+func Listen(address string) (net.Listener, error) {
+	if !IsUnixSocket(address) {
+		return net.Listen("tcp", address)
+	}
+
+	socketPath := strings.TrimPrefix(address, unixPrefix)
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socketPath, 0660); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// IsUnixSocket return true when the address is a unix socket `unix:///path`
+func IsUnixSocket(address string) bool {
+	return strings.HasPrefix(address, unixPrefix)
+}
@@ -0,0 +1,46 @@
+package helper
+
+import "sync"
+
+// RunParallel run `fn` for each index in [0, count) with at most `limit` running at the same time,
+// `limit` <= 1 runs sequentially. Return the error of each index in order, with `failFast` the
+// indexes not started yet are skipped after an error, their errors are nil.
+func RunParallel(count, limit int, failFast bool, fn func(i int) error) []error {
+	if limit < 1 {
+		limit = 1
+	}
+
+	errs := make([]error, count)
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := false
+
+	for i := 0; i < count; i++ {
+		sem <- struct{}{}
+
+		mu.Lock()
+		stop := failFast && failed
+		mu.Unlock()
+		if stop {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fn(i); err != nil {
+				mu.Lock()
+				errs[i] = err
+				failed = true
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return errs
+}
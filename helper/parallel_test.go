@@ -0,0 +1,51 @@
+package helper
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+)
+
+func TestRunParallel(t *testing.T) {
+	var running, maxRunning int32
+	errs := RunParallel(6, 2, false, func(i int) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		if i%2 == 1 {
+			return fmt.Errorf("error %d", i)
+		}
+		return nil
+	})
+
+	assert.Equal(t, int32(2), maxRunning)
+	assert.Equal(t, 6, len(errs))
+	assert.Nil(t, errs[0])
+	assert.EqualError(t, errs[1], "error 1")
+	assert.EqualError(t, errs[5], "error 5")
+}
+
+func TestRunParallel_failFast(t *testing.T) {
+	var called []int
+	errs := RunParallel(4, 1, true, func(i int) error {
+		called = append(called, i)
+		if i == 1 {
+			return fmt.Errorf("error %d", i)
+		}
+		return nil
+	})
+
+	assert.Equal(t, []int{0, 1}, called)
+	assert.EqualError(t, errs[1], "error 1")
+	assert.Nil(t, errs[2])
+}
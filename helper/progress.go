@@ -24,7 +24,8 @@ type ProgressBar struct {
 	startTime  time.Time
 }
 
-func NewProgressBar(myLogger logger.Logger, reader *os.File) ProgressBar {
+// NewProgressBar wrap the reader of the upload with the progress bar, and limit the rate by `limiters`
+func NewProgressBar(myLogger logger.Logger, reader *os.File, limiters ...*RateLimiter) ProgressBar {
 	info, _ := reader.Stat()
 	fileLength := info.Size()
 
@@ -33,7 +34,7 @@ func NewProgressBar(myLogger logger.Logger, reader *os.File) ProgressBar {
 	bar.Set("time", time.Now().Format(logger.TimeFormat))
 	bar.Set("prefix", myLogger.Prefix())
 
-	multiReader := bar.NewProxyReader(reader)
+	multiReader := LimitReader(bar.NewProxyReader(reader), limiters...)
 
 	progressBar := ProgressBar{bar, fileLength, multiReader, myLogger, time.Now()}
 	progressBar.start()
@@ -62,3 +63,8 @@ func (p ProgressBar) Done(url string) {
 
 	logger.Info(fmt.Sprintf("Uploaded: %s (Duration %v)", url, durafmt.Parse(elapsed).LimitFirstN(2).String()))
 }
+
+// Skip count `n` bytes which have been uploaded before, e.g. when the upload is resumed
+func (p ProgressBar) Skip(n int64) {
+	p.bar.Add64(n)
+}
@@ -0,0 +1,98 @@
+package helper
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// RateLimiter is a token bucket of bytes per second, it can be shared by readers
+// in different goroutines to limit their total rate. The burst is one second of the rate.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter return the limiter of `rate` bytes per second, nil when `rate` <= 0
+func NewRateLimiter(rate int64) *RateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	return &RateLimiter{rate: float64(rate), tokens: float64(rate), last: time.Now()}
+}
+
+// wait until `n` bytes are allowed, the bytes are taken in advance so the waiting readers queue up
+func (l *RateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.rate) - float64(n)
+	l.last = now
+
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+type rateLimitReader struct {
+	r        io.Reader
+	limiters []*RateLimiter
+	size     int
+}
+
+// LimitReader return the reader limited by all `limiters`, nil limiters are ignored
+func LimitReader(r io.Reader, limiters ...*RateLimiter) io.Reader {
+	var active []*RateLimiter
+	size := 0
+	for _, l := range limiters {
+		if l == nil {
+			continue
+		}
+		active = append(active, l)
+		// Read at most 1/10 second of the slowest limiter to keep the rate smooth
+		if s := int(l.rate / 10); size == 0 || s < size {
+			size = s
+		}
+	}
+	if len(active) == 0 {
+		return r
+	}
+
+	return &rateLimitReader{r: r, limiters: active, size: max(size, 1)}
+}
+
+func (r *rateLimitReader) Read(p []byte) (int, error) {
+	if len(p) > r.size {
+		p = p[:r.size]
+	}
+
+	n, err := r.r.Read(p)
+	for _, l := range r.limiters {
+		l.wait(n)
+	}
+	return n, err
+}
+
+// ParseRate parse the rate like `20MB/s` or `512KiB` into bytes per second, 0 for empty
+func ParseRate(rate string) (int64, error) {
+	rate = strings.TrimSpace(rate)
+	if len(rate) == 0 {
+		return 0, nil
+	}
+
+	bytes, err := humanize.ParseBytes(strings.TrimSuffix(rate, "/s"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate_limit %q: %v", rate, err)
+	}
+	return int64(bytes), nil
+}
@@ -0,0 +1,61 @@
+package helper
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+)
+
+func TestParseRate(t *testing.T) {
+	cases := map[string]int64{
+		"":         0,
+		"20MB/s":   20000000,
+		"512KiB/s": 512 * 1024,
+		"1 MB":     1000000,
+	}
+	for rate, expected := range cases {
+		actual, err := ParseRate(rate)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	_, err := ParseRate("fast")
+	assert.Error(t, err)
+}
+
+func TestLimitReader(t *testing.T) {
+	r := bytes.NewReader([]byte("hello"))
+	assert.Equal(t, io.Reader(r), LimitReader(r, nil))
+
+	// The burst of 100KB/s is used up by the first 100KB, the next 100KB takes about 1 second
+	data := make([]byte, 200000)
+	start := time.Now()
+	n, err := io.Copy(io.Discard, LimitReader(bytes.NewReader(data), NewRateLimiter(100000)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	elapsed := time.Since(start)
+	assert.True(t, elapsed > 900*time.Millisecond)
+	assert.True(t, elapsed < 2*time.Second)
+}
+
+func TestLimitReader_shared(t *testing.T) {
+	limiter := NewRateLimiter(100000)
+
+	// Two readers share the limit, 200KB in total takes about 1 second
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := io.Copy(io.Discard, LimitReader(bytes.NewReader(make([]byte, 100000)), limiter))
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.True(t, time.Since(start) > 900*time.Millisecond)
+}
@@ -0,0 +1,138 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gigcodes/launch-util/logger"
+)
+
+const (
+	// RetryNetwork connection reset, refused, broken pipe, DNS errors...
+	RetryNetwork = "network"
+	// RetryTimeout timeout and deadline exceeded
+	RetryTimeout = "timeout"
+	// RetryServer HTTP 5xx and 429, FTP 4xx
+	RetryServer = "server"
+	// RetryAll retry any error
+	RetryAll = "all"
+)
+
+var (
+	// status code: 503, googleapi: Error 503, RESPONSE 503, status: 429
+	serverErrorRegexp = regexp.MustCompile(`(?i)(status(\s*code)?\s*[:=]?|error|response)\s*(5\d\d|429)\b`)
+)
+
+// RetryConfig of the `retry` block, the delay doubles from `InitialDelay` up to `MaxDelay`,
+// only the errors of the classes in `Errors` are retried. `Attempts` <= 1 runs once.
+type RetryConfig struct {
+	Attempts     int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Errors       []string
+}
+
+// Do run `fn` until it succeeds, the error is not retryable or the attempts are used up,
+// return the last error.
+func (c RetryConfig) Do(logger logger.Logger, name string, fn func() error) error {
+	delay := c.InitialDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= c.Attempts || !c.Retryable(err) {
+			return err
+		}
+
+		logger.Warnf("%s failed (attempt %d/%d), retry in %v: %v", name, attempt, c.Attempts, delay, err)
+		time.Sleep(delay)
+
+		delay *= 2
+		if c.MaxDelay > 0 && delay > c.MaxDelay {
+			delay = c.MaxDelay
+		}
+	}
+}
+
+// Retryable return true when the error is in one of the error classes
+func (c RetryConfig) Retryable(err error) bool {
+	for _, class := range c.Errors {
+		switch class {
+		case RetryAll:
+			return true
+		case RetryNetwork:
+			if isNetworkError(err) {
+				return true
+			}
+		case RetryTimeout:
+			if isTimeoutError(err) {
+				return true
+			}
+		case RetryServer:
+			if isServerError(err) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func isNetworkError(err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) {
+		return true
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	// The SDKs often flatten the errors into the message
+	message := strings.ToLower(err.Error())
+	for _, s := range []string{"connection reset", "connection refused", "broken pipe", "no such host", "unexpected eof"} {
+		if strings.Contains(message, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isTimeoutError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	if errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "timeout") || strings.Contains(message, "timed out")
+}
+
+func isServerError(err error) bool {
+	// FTP transient negative replies
+	var ftpErr *textproto.Error
+	if errors.As(err, &ftpErr) && ftpErr.Code >= 400 && ftpErr.Code < 500 {
+		return true
+	}
+
+	var statusErr interface{ StatusCode() int }
+	if errors.As(err, &statusErr) {
+		code := statusErr.StatusCode()
+		return code >= 500 || code == 429
+	}
+
+	return serverErrorRegexp.MatchString(err.Error())
+}
@@ -0,0 +1,92 @@
+package helper
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gigcodes/launch-util/logger"
+	"github.com/longbridgeapp/assert"
+)
+
+func TestRetryConfig_Do(t *testing.T) {
+	retry := RetryConfig{Attempts: 3, InitialDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond, Errors: []string{RetryNetwork}}
+	logger := logger.Tag("Test")
+
+	var calls int
+	err := retry.Do(logger, "upload", func() error {
+		calls++
+		if calls < 3 {
+			return syscall.ECONNRESET
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// Attempts are used up
+	calls = 0
+	err = retry.Do(logger, "upload", func() error {
+		calls++
+		return syscall.ECONNRESET
+	})
+	assert.Equal(t, syscall.ECONNRESET, err)
+	assert.Equal(t, 3, calls)
+
+	// Not retryable
+	calls = 0
+	err = retry.Do(logger, "upload", func() error {
+		calls++
+		return os.ErrNotExist
+	})
+	assert.Equal(t, os.ErrNotExist, err)
+	assert.Equal(t, 1, calls)
+
+	// Zero config runs once
+	calls = 0
+	_ = RetryConfig{}.Do(logger, "upload", func() error {
+		calls++
+		return syscall.ECONNRESET
+	})
+	assert.Equal(t, 1, calls)
+}
+
+type statusError int
+
+func (e statusError) Error() string   { return fmt.Sprintf("status %d", int(e)) }
+func (e statusError) StatusCode() int { return int(e) }
+
+func TestRetryConfig_Retryable(t *testing.T) {
+	cases := []struct {
+		class     string
+		err       error
+		retryable bool
+	}{
+		{RetryNetwork, &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{RetryNetwork, fmt.Errorf("write: %w", syscall.EPIPE), true},
+		{RetryNetwork, fmt.Errorf("read tcp: connection reset by peer"), true},
+		{RetryNetwork, os.ErrNotExist, false},
+		{RetryTimeout, os.ErrDeadlineExceeded, true},
+		{RetryTimeout, fmt.Errorf("i/o timeout"), true},
+		{RetryTimeout, fmt.Errorf("permission denied"), false},
+		{RetryServer, statusError(503), true},
+		{RetryServer, statusError(429), true},
+		{RetryServer, statusError(403), false},
+		{RetryServer, fmt.Errorf("SlowDown: please reduce your request rate\n\tstatus code: 503, request id: 1"), true},
+		{RetryServer, fmt.Errorf("googleapi: Error 502: Bad Gateway"), true},
+		{RetryServer, fmt.Errorf("status: 500, body: oops"), true},
+		{RetryServer, fmt.Errorf("status: 404, body: not found"), false},
+		{RetryServer, &textproto.Error{Code: 421, Msg: "Service not available"}, true},
+		{RetryServer, &textproto.Error{Code: 550, Msg: "No such file"}, false},
+		{RetryAll, os.ErrNotExist, true},
+	}
+
+	for _, c := range cases {
+		retry := RetryConfig{Errors: []string{c.class}}
+		assert.Equal(t, c.retryable, retry.Retryable(c.err), c.err.Error())
+	}
+}
@@ -1,7 +1,9 @@
 package helper
 
 import (
+	"strconv"
 	"strings"
+	"time"
 )
 
 var (
@@ -36,3 +38,16 @@ func FormatEndpoint(endpoint string) string {
 
 	return endpoint
 }
+
+// ParseDuration parse duration with `d` unit for days in addition to time.ParseDuration, e.g. 90d
+func ParseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(s)
+}
@@ -3,6 +3,7 @@ package helper
 import (
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/longbridgeapp/assert"
 )
@@ -27,3 +28,18 @@ func TestFormatEndpoint(t *testing.T) {
 	assert.Equal(t, "https://foo.bar.com", FormatEndpoint("https://foo.bar.com"))
 	assert.Equal(t, "https://foo.bar.com", FormatEndpoint("https://foo.bar.com"))
 }
+
+func TestParseDuration(t *testing.T) {
+	d, err := ParseDuration("90d")
+	assert.NoError(t, err)
+	assert.Equal(t, 90*24*time.Hour, d)
+
+	d, err = ParseDuration("36h")
+	assert.NoError(t, err)
+	assert.Equal(t, 36*time.Hour, d)
+
+	_, err = ParseDuration("1.5d")
+	assert.Error(t, err)
+	_, err = ParseDuration("foo")
+	assert.Error(t, err)
+}
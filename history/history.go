@@ -0,0 +1,344 @@
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+)
+
+const (
+	StatusFinished     = "finished"
+	StatusFailed       = "failed"
+	StatusVerifyFailed = "verify_failed"
+	StatusSkipped      = "skipped"
+
+	// The oldest runs of a model are removed beyond this count
+	maxRuns = 1000
+
+	runsBucket     = "runs"
+	countersBucket = "counters"
+	runsPrefix     = "runs/"
+	failuresPrefix = "failures/"
+)
+
+var (
+	dbPath = filepath.Join(config.LaunchAgentDir, "history.db")
+)
+
+// Run record of a perform of a model
+type Run struct {
+	ID         uint64    `json:"id"`
+	Model      string    `json:"model"`
+	Status     string    `json:"status"`
+	FileKey    string    `json:"file_key,omitempty"`
+	Size       int64     `json:"size"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// Duration in seconds
+	Duration  float64   `json:"duration"`
+	Stages    []Stage   `json:"stages"`
+	Databases []Outcome `json:"databases,omitempty"`
+	Storages  []Outcome `json:"storages,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	// The stage which failed the run
+	FailedStage string `json:"failed_stage,omitempty"`
+
+	stageStartedAt time.Time
+}
+
+// Stage of the run: disk, database, archive, compressor, encryptor, splitter, storage, verify
+type Stage struct {
+	Name     string  `json:"name"`
+	Duration float64 `json:"duration"`
+}
+
+// Outcome of a database or a storage in the run
+type Outcome struct {
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// NewRun start the record of the run of the model
+func NewRun(model string) *Run {
+	return &Run{
+		Model:     model,
+		StartedAt: time.Now(),
+		Stages:    []Stage{},
+	}
+}
+
+// Stage end the current stage and start the stage `name`
+func (r *Run) Stage(name string) {
+	r.endStage()
+	r.Stages = append(r.Stages, Stage{Name: name})
+	r.stageStartedAt = time.Now()
+}
+
+// Finish the run with the status and the error
+func (r *Run) Finish(status string, err error) {
+	r.endStage()
+	r.FinishedAt = time.Now()
+	r.Duration = r.FinishedAt.Sub(r.StartedAt).Seconds()
+	r.Status = status
+	if err != nil {
+		r.Error = err.Error()
+		if len(r.Stages) > 0 {
+			r.FailedStage = r.Stages[len(r.Stages)-1].Name
+		}
+	}
+}
+
+func (r *Run) endStage() {
+	if len(r.Stages) == 0 {
+		return
+	}
+
+	stage := &r.Stages[len(r.Stages)-1]
+	if stage.Duration == 0 {
+		stage.Duration = time.Since(r.stageStartedAt).Seconds()
+	}
+}
+
+// NewOutcome return the outcome of `name` which has run since `startedAt`
+func NewOutcome(name, kind string, startedAt time.Time, err error) Outcome {
+	outcome := Outcome{
+		Name:     name,
+		Type:     kind,
+		Status:   StatusFinished,
+		Duration: time.Since(startedAt).Seconds(),
+	}
+	if err != nil {
+		outcome.Status = StatusFailed
+		outcome.Error = err.Error()
+	}
+
+	return outcome
+}
+
+// Save the run into the history under `LaunchAgentDir`, the runs of a model are kept in a bucket by id,
+// the counters of the runs by status and the failures by stage are kept beyond the removed runs.
+func Save(run *Run) error {
+	if err := helper.MkdirP(filepath.Dir(dbPath)); err != nil {
+		return err
+	}
+
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := createBucket(tx, runsBucket, run.Model)
+		if err != nil {
+			return err
+		}
+
+		if run.ID, err = bucket.NextSequence(); err != nil {
+			return err
+		}
+		data, err := json.Marshal(run)
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(itob(run.ID), data); err != nil {
+			return err
+		}
+
+		counters, err := createBucket(tx, countersBucket, run.Model)
+		if err != nil {
+			return err
+		}
+		if err := increase(counters, runsPrefix+run.Status); err != nil {
+			return err
+		}
+		if len(run.FailedStage) > 0 {
+			if err := increase(counters, failuresPrefix+run.FailedStage); err != nil {
+				return err
+			}
+		}
+
+		// Remove the oldest runs, the ids are sequential
+		if run.ID <= maxRuns {
+			return nil
+		}
+		var expired [][]byte
+		cursor := bucket.Cursor()
+		for k, _ := cursor.First(); k != nil && binary.BigEndian.Uint64(k) <= run.ID-maxRuns; k, _ = cursor.Next() {
+			expired = append(expired, k)
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Query the latest runs of the model or all models when `model` is empty, the newest first.
+// Only the runs with the `status` are returned when it is not empty, `limit` <= 0 returns all.
+func Query(model, status string, limit int) ([]Run, error) {
+	runs := []Run{}
+	err := view(func(tx *bolt.Tx) error {
+		return forEachBucket(tx, runsBucket, func(name string, bucket *bolt.Bucket) error {
+			if len(model) > 0 && name != model {
+				return nil
+			}
+
+			count := 0
+			cursor := bucket.Cursor()
+			for k, v := cursor.Last(); k != nil && (limit <= 0 || count < limit); k, v = cursor.Prev() {
+				var run Run
+				if err := json.Unmarshal(v, &run); err != nil {
+					return err
+				}
+				if len(status) > 0 && run.Status != status {
+					continue
+				}
+				runs = append(runs, run)
+				count++
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+
+	return runs, nil
+}
+
+// Summary of the runs of a model
+type Summary struct {
+	Model string
+	// Runs by status
+	Runs map[string]uint64
+	// Failures by stage
+	Failures    map[string]uint64
+	LastRun     *Run
+	LastSuccess *Run
+}
+
+// Summarize the runs of all models in the history, by model name
+func Summarize() (map[string]*Summary, error) {
+	summaries := map[string]*Summary{}
+	summary := func(model string) *Summary {
+		if _, ok := summaries[model]; !ok {
+			summaries[model] = &Summary{Model: model, Runs: map[string]uint64{}, Failures: map[string]uint64{}}
+		}
+		return summaries[model]
+	}
+
+	err := view(func(tx *bolt.Tx) error {
+		err := forEachBucket(tx, countersBucket, func(name string, bucket *bolt.Bucket) error {
+			return bucket.ForEach(func(k, v []byte) error {
+				key := string(k)
+				if strings.HasPrefix(key, runsPrefix) {
+					summary(name).Runs[strings.TrimPrefix(key, runsPrefix)] = binary.BigEndian.Uint64(v)
+				} else if strings.HasPrefix(key, failuresPrefix) {
+					summary(name).Failures[strings.TrimPrefix(key, failuresPrefix)] = binary.BigEndian.Uint64(v)
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		return forEachBucket(tx, runsBucket, func(name string, bucket *bolt.Bucket) error {
+			s := summary(name)
+			cursor := bucket.Cursor()
+			for k, v := cursor.Last(); k != nil && s.LastSuccess == nil; k, v = cursor.Prev() {
+				var run Run
+				if err := json.Unmarshal(v, &run); err != nil {
+					return err
+				}
+				if s.LastRun == nil {
+					s.LastRun = &run
+				}
+				if run.Status == StatusFinished {
+					s.LastSuccess = &run
+				}
+			}
+			return nil
+		})
+	})
+
+	return summaries, err
+}
+
+// view the history in a read only transaction, nothing is viewed before the first run is saved
+func view(fn func(tx *bolt.Tx) error) error {
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(fn)
+}
+
+func createBucket(tx *bolt.Tx, parent, name string) (*bolt.Bucket, error) {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(parent))
+	if err != nil {
+		return nil, err
+	}
+
+	return bucket.CreateBucketIfNotExists([]byte(name))
+}
+
+// forEachBucket call `fn` with the nested buckets of the bucket `parent`
+func forEachBucket(tx *bolt.Tx, parent string, fn func(name string, bucket *bolt.Bucket) error) error {
+	bucket := tx.Bucket([]byte(parent))
+	if bucket == nil {
+		return nil
+	}
+
+	return bucket.ForEach(func(k, v []byte) error {
+		// Nested buckets have no value
+		if v != nil {
+			return nil
+		}
+		return fn(string(k), bucket.Bucket(k))
+	})
+}
+
+func increase(bucket *bolt.Bucket, key string) error {
+	var value uint64
+	if v := bucket.Get([]byte(key)); v != nil {
+		value = binary.BigEndian.Uint64(v)
+	}
+
+	return bucket.Put([]byte(key), itob(value+1))
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,105 @@
+package history
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+)
+
+func TestRun_Stage(t *testing.T) {
+	run := NewRun("foo")
+	run.Stage("database")
+	time.Sleep(10 * time.Millisecond)
+	run.Stage("storage")
+	run.Finish(StatusFailed, errors.New("upload failed"))
+
+	assert.Len(t, run.Stages, 2)
+	assert.Equal(t, "database", run.Stages[0].Name)
+	assert.True(t, run.Stages[0].Duration >= 0.01)
+	assert.Equal(t, "storage", run.Stages[1].Name)
+	assert.Equal(t, StatusFailed, run.Status)
+	assert.Equal(t, "upload failed", run.Error)
+	assert.True(t, run.Duration >= run.Stages[0].Duration)
+}
+
+func TestNewOutcome(t *testing.T) {
+	outcome := NewOutcome("local", "local", time.Now(), nil)
+	assert.Equal(t, StatusFinished, outcome.Status)
+	assert.Equal(t, "", outcome.Error)
+
+	outcome = NewOutcome("s3", "s3", time.Now(), errors.New("denied"))
+	assert.Equal(t, StatusFailed, outcome.Status)
+	assert.Equal(t, "denied", outcome.Error)
+}
+
+func TestSaveQuery(t *testing.T) {
+	dbPath = filepath.Join(t.TempDir(), "history.db")
+
+	runs, err := Query("", "", 0)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 0)
+
+	startedAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, status := range []string{StatusFinished, StatusFailed, StatusFinished} {
+		for _, model := range []string{"foo", "bar"} {
+			run := &Run{Model: model, Status: status, StartedAt: startedAt.Add(time.Duration(i) * time.Hour)}
+			assert.NoError(t, Save(run))
+			assert.Equal(t, uint64(i+1), run.ID)
+		}
+	}
+
+	runs, err = Query("", "", 0)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 6)
+	assert.Equal(t, startedAt.Add(2*time.Hour), runs[0].StartedAt)
+
+	runs, err = Query("foo", "", 2)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 2)
+	assert.Equal(t, "foo", runs[0].Model)
+	assert.Equal(t, uint64(3), runs[0].ID)
+	assert.Equal(t, uint64(2), runs[1].ID)
+
+	// When did it last succeed
+	runs, err = Query("foo", StatusFinished, 1)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, uint64(3), runs[0].ID)
+
+	runs, err = Query("foo", StatusFailed, 0)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, uint64(2), runs[0].ID)
+}
+
+func TestSummarize(t *testing.T) {
+	dbPath = filepath.Join(t.TempDir(), "history.db")
+
+	summaries, err := Summarize()
+	assert.NoError(t, err)
+	assert.Len(t, summaries, 0)
+
+	for _, status := range []string{StatusFinished, StatusFailed, StatusFailed} {
+		run := NewRun("foo")
+		run.Stage("database")
+		run.Stage("storage")
+		if status == StatusFailed {
+			run.Finish(status, errors.New("upload failed"))
+		} else {
+			run.Finish(status, nil)
+		}
+		assert.NoError(t, Save(run))
+	}
+
+	summaries, err = Summarize()
+	assert.NoError(t, err)
+	summary := summaries["foo"]
+	assert.Equal(t, map[string]uint64{StatusFinished: 1, StatusFailed: 2}, summary.Runs)
+	assert.Equal(t, map[string]uint64{"storage": 2}, summary.Failures)
+	assert.Equal(t, uint64(3), summary.LastRun.ID)
+	assert.Equal(t, "storage", summary.LastRun.FailedStage)
+	assert.Equal(t, uint64(1), summary.LastSuccess.ID)
+}
@@ -68,8 +68,9 @@ func (logger Logger) Writer() io.Writer {
 	return logger.myLog.Writer()
 }
 
+// Tag return a copy of the logger with the tag as prefix, the copy is safe to use in goroutines
 func (logger Logger) Tag(tag string) Logger {
-	logger.myLog.SetPrefix(tag)
+	logger.myLog = log.New(logger.myLog.Writer(), tag, logger.myLog.Flags())
 	return logger
 }
 
@@ -1,21 +1,30 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/sevlyar/go-daemon"
 	"github.com/spf13/viper"
 	"github.com/urfave/cli/v2"
 
+	"github.com/gigcodes/launch-util/api"
 	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/history"
 	"github.com/gigcodes/launch-util/logger"
+	"github.com/gigcodes/launch-util/metrics"
 	"github.com/gigcodes/launch-util/model"
 	"github.com/gigcodes/launch-util/psutil"
 	"github.com/gigcodes/launch-util/rpc"
 	"github.com/gigcodes/launch-util/scheduler"
+	"github.com/gigcodes/launch-util/storage"
 )
 
 const (
@@ -40,8 +49,84 @@ func buildFlags(flags []cli.Flag) []cli.Flag {
 	})
 }
 
+// buildSupervisorFlags add the flag of the supervisor instance
+func buildSupervisorFlags(flags []cli.Flag) []cli.Flag {
+	return buildFlags(append(flags, &cli.StringFlag{
+		Name:    "instance",
+		Aliases: []string{"I"},
+		Usage:   "Supervisor instance in the config, required with multiple instances",
+	}, deprecatedSupervisorFlag()))
+}
+
+// deprecatedSupervisorFlag of the XML-RPC endpoint before the supervisor instances, it is always
+// requested over the socket `/var/run/supervisor.sock`, which is the default instance now
+func deprecatedSupervisorFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "supervisor",
+		Usage: "DEPRECATED: use --instance, the default supervisor instance is used",
+	}
+}
+
+// defaultSupervisorInstance of the deprecated `--supervisor` flag, the instance `default` or the only instance
+func defaultSupervisorInstance() (config.SupervisorInstance, error) {
+	logger.Warn("DEPRECATED: `--supervisor` is deprecated, use `--instance` instead.")
+
+	if instances, err := config.GetSupervisorInstances([]string{"default"}); err == nil {
+		return instances[0], nil
+	}
+	if len(config.Supervisor.Instances) == 0 {
+		return config.SupervisorInstance{}, fmt.Errorf("no supervisor instance is configured")
+	}
+	if len(config.Supervisor.Instances) != 1 {
+		return config.SupervisorInstance{}, fmt.Errorf("--instance is required, found %d supervisor instances", len(config.Supervisor.Instances))
+	}
+	return config.Supervisor.Instances[0], nil
+}
+
+// supervisorInstance get the instance of the `--instance` flag, or the only instance
+func supervisorInstance(ctx *cli.Context) (config.SupervisorInstance, error) {
+	name := ctx.String("instance")
+	if len(name) == 0 && ctx.IsSet("supervisor") {
+		return defaultSupervisorInstance()
+	}
+	if len(name) == 0 {
+		if len(config.Supervisor.Instances) == 0 {
+			return config.SupervisorInstance{}, fmt.Errorf("no supervisor instance is configured")
+		}
+		if len(config.Supervisor.Instances) != 1 {
+			return config.SupervisorInstance{}, fmt.Errorf("--instance is required, found %d supervisor instances", len(config.Supervisor.Instances))
+		}
+		return config.Supervisor.Instances[0], nil
+	}
+
+	instances, err := config.GetSupervisorInstances([]string{name})
+	if err != nil {
+		return config.SupervisorInstance{}, err
+	}
+	return instances[0], nil
+}
+
+// supervisorControlCommand of supervisorctl start, stop and restart
+func supervisorControlCommand(action, usage string) *cli.Command {
+	return &cli.Command{
+		Name:      action,
+		Usage:     usage,
+		ArgsUsage: "<name|group:name|group:*|all>...",
+		Flags:     buildSupervisorFlags([]cli.Flag{}),
+		Action: func(ctx *cli.Context) error {
+			err := initApplication()
+			if err != nil {
+				return err
+			}
+			return controlSupervisor(ctx, action, ctx.Args().Slice(), "")
+		},
+	}
+}
+
 func termHandler(sig os.Signal) error {
 	logger.Info("Received QUIT signal, exiting...")
+	api.Stop()
+	metrics.Stop()
 	scheduler.Stop()
 	os.Exit(0)
 	return nil
@@ -88,6 +173,144 @@ func main() {
 				return perform(modelNames)
 			},
 		},
+		{
+			Name:  "restore",
+			Usage: "Restore databases of a model from a backup",
+			Flags: buildFlags([]cli.Flag{
+				&cli.StringFlag{
+					Name:     "model",
+					Aliases:  []string{"m"},
+					Usage:    "Model name that you want restore",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:    "backup",
+					Aliases: []string{"b"},
+					Usage:   "File key of the backup, the latest backup will be used if not provided",
+				},
+				&cli.StringSliceFlag{
+					Name:    "database",
+					Aliases: []string{"d"},
+					Usage:   "Database name that you want restore (if not provided, all databases will be restored)",
+				},
+			}),
+			Action: func(ctx *cli.Context) error {
+				err := initApplication()
+				if err != nil {
+					return err
+				}
+				return restore(ctx.String("model"), ctx.String("backup"), ctx.StringSlice("database"))
+			},
+		},
+		{
+			Name:  "verify",
+			Usage: "Verify the integrity of a backup in the storages of a model",
+			Flags: buildFlags([]cli.Flag{
+				&cli.StringFlag{
+					Name:     "model",
+					Aliases:  []string{"m"},
+					Usage:    "Model name that you want verify",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:    "backup",
+					Aliases: []string{"b"},
+					Usage:   "File key of the backup, the latest backup will be used if not provided",
+				},
+			}),
+			Action: func(ctx *cli.Context) error {
+				err := initApplication()
+				if err != nil {
+					return err
+				}
+				return verify(ctx.String("model"), ctx.String("backup"))
+			},
+		},
+		{
+			Name:  "list",
+			Usage: "List backups of a model across all storages",
+			Flags: buildFlags([]cli.Flag{
+				&cli.StringFlag{
+					Name:     "model",
+					Aliases:  []string{"m"},
+					Usage:    "Model name that you want list",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:    "storage",
+					Aliases: []string{"s"},
+					Usage:   "Storage name that you want list (if not provided, all storages will be listed)",
+				},
+				&cli.BoolFlag{
+					Name:  "json",
+					Usage: "Print as JSON",
+				},
+			}),
+			Action: func(ctx *cli.Context) error {
+				err := initApplication()
+				if err != nil {
+					return err
+				}
+				return list(ctx.String("model"), ctx.String("storage"), ctx.Bool("json"))
+			},
+		},
+		{
+			Name:  "history",
+			Usage: "Show the history of the performed backups",
+			Flags: buildFlags([]cli.Flag{
+				&cli.StringFlag{
+					Name:    "model",
+					Aliases: []string{"m"},
+					Usage:   "Model name that you want show (if not provided, all models will be shown)",
+				},
+				&cli.StringFlag{
+					Name:  "status",
+					Usage: "Only show the runs with the status: finished, failed, verify_failed",
+				},
+				&cli.IntFlag{
+					Name:    "limit",
+					Aliases: []string{"n"},
+					Usage:   "Number of the latest runs to show, 0 shows all",
+					Value:   20,
+				},
+				&cli.BoolFlag{
+					Name:  "json",
+					Usage: "Print as JSON",
+				},
+			}),
+			Action: func(ctx *cli.Context) error {
+				return showHistory(ctx.String("model"), ctx.String("status"), ctx.Int("limit"), ctx.Bool("json"))
+			},
+		},
+		{
+			Name:  "cycler",
+			Usage: "Manage the cycler state of the storages",
+			Subcommands: []*cli.Command{
+				{
+					Name:  "sync",
+					Usage: "Reconcile the cycler state with the remote files, report orphaned and missing backups",
+					Flags: buildFlags([]cli.Flag{
+						&cli.StringFlag{
+							Name:     "model",
+							Aliases:  []string{"m"},
+							Usage:    "Model name that you want sync",
+							Required: true,
+						},
+						&cli.BoolFlag{
+							Name:  "dry-run",
+							Usage: "Only report the difference, do not update the cycler state",
+						},
+					}),
+					Action: func(ctx *cli.Context) error {
+						err := initApplication()
+						if err != nil {
+							return err
+						}
+						return syncCycler(ctx.String("model"), ctx.Bool("dry-run"))
+					},
+				},
+			},
+		},
 		{
 			Name:  "pulse",
 			Usage: "Show resources usages",
@@ -102,13 +325,14 @@ func main() {
 					return nil
 				}
 				fmt.Printf("System Stats:\n")
-				fmt.Printf("Load Average (1 min): %.2f\n", psutilData.Load)
+				fmt.Printf("Load Average (1 min): %.2f\n", psutilData.LoadAverage)
+				fmt.Printf("CPU: %.2f%%\n", psutilData.Load)
 				fmt.Printf("Disk Total: %s bytes\n", psutilData.DiskTotal)
 				fmt.Printf("Disk Free: %s bytes\n", psutilData.DiskFree)
-				fmt.Printf("Disk Used: %s bytes\n", psutilData.DiskUsed)
+				fmt.Printf("Disk Used: %s bytes (%.1f%%)\n", psutilData.DiskUsed, psutilData.DiskUsedPercent)
 				fmt.Printf("Memory Total: %s bytes\n", psutilData.MemoryTotal)
 				fmt.Printf("Memory Free: %s bytes\n", psutilData.MemoryFree)
-				fmt.Printf("Memory Used: %s bytes\n", psutilData.MemoryUsed)
+				fmt.Printf("Memory Used: %s bytes (%.1f%%)\n", psutilData.MemoryUsed, psutilData.MemoryUsedPercent)
 
 				psutil.Pulse(psutilData)
 				return nil
@@ -161,41 +385,119 @@ func main() {
 					return fmt.Errorf("failed to start scheduler: %w", err)
 				}
 
+				if err := api.Start(); err != nil {
+					return fmt.Errorf("failed to start api: %w", err)
+				}
+
+				if err := metrics.Start(); err != nil {
+					return fmt.Errorf("failed to start metrics: %w", err)
+				}
+
 				select {}
 			},
 		},
 		{
 			Name:  "status",
-			Usage: "Check Supervisor daemon statuses and send webhook response",
+			Usage: "Check the daemon statuses of Supervisor, systemd and Docker and send webhook response",
 			Flags: buildFlags([]cli.Flag{
 				&cli.StringSliceFlag{
 					Name:    "id",
 					Aliases: []string{"i"},
-					Usage:   "Supervisor daemon IDs to check (if not provided, status for all daemons will be retrieved)",
+					Usage:   "Supervisor daemon IDs to check, instance/id for a single instance (if not provided, status for all daemons will be retrieved)",
 				},
-				&cli.StringFlag{
-					Name:  "supervisor",
-					Usage: "Supervisor XML-RPC endpoint",
-					Value: "http://localhost/RPC2",
+				&cli.StringSliceFlag{
+					Name:    "instance",
+					Aliases: []string{"I"},
+					Usage:   "Supervisor instances or process managers to check (if not provided, all of them will be checked)",
 				},
+				deprecatedSupervisorFlag(),
 			}),
 			Action: func(ctx *cli.Context) error {
 				daemonIDs := ctx.StringSlice("id")
-				socketPath := "/var/run/supervisor.sock"
-				rpcEndpoint := ctx.String("supervisor")
 
 				err := initApplication()
 				if err != nil {
 					return err
 				}
 
+				names := ctx.StringSlice("instance")
+				if len(names) == 0 && ctx.IsSet("supervisor") {
+					instance, err := defaultSupervisorInstance()
+					if err != nil {
+						return err
+					}
+					names = []string{instance.Name}
+				}
+
+				instances, managers, err := config.GetDaemonSources(names)
+				if err != nil {
+					return err
+				}
+
 				// Call the function from the rpc package to check statuses and send webhook.
-				if err := rpc.SendDaemonStatus(daemonIDs, socketPath, rpcEndpoint); err != nil {
+				if err := rpc.SendDaemonStatus(daemonIDs, instances, managers); err != nil {
 					return fmt.Errorf("failed to send daemon status: %w", err)
 				}
 				return nil
 			},
 		},
+		{
+			Name:  "supervisor",
+			Usage: "Control Supervisor processes, the results are sent to the notifiers",
+			Subcommands: []*cli.Command{
+				supervisorControlCommand(rpc.ActionStart, "Start the processes"),
+				supervisorControlCommand(rpc.ActionStop, "Stop the processes"),
+				supervisorControlCommand(rpc.ActionRestart, "Stop and start the processes"),
+				{
+					Name:      rpc.ActionSignal,
+					Usage:     "Send a signal to the processes",
+					ArgsUsage: "<signal> <name|group:name|group:*|all>...",
+					Flags:     buildSupervisorFlags([]cli.Flag{}),
+					Action: func(ctx *cli.Context) error {
+						if ctx.NArg() < 2 {
+							return fmt.Errorf("usage: launch-agent supervisor signal <signal> <name>...")
+						}
+						err := initApplication()
+						if err != nil {
+							return err
+						}
+						return controlSupervisor(ctx, rpc.ActionSignal, ctx.Args().Tail(), ctx.Args().First())
+					},
+				},
+				{
+					Name:      "tail",
+					Usage:     "Show the stdout log of a process",
+					ArgsUsage: "<name|group:name>",
+					Flags: buildSupervisorFlags([]cli.Flag{
+						&cli.BoolFlag{
+							Name:    "follow",
+							Aliases: []string{"f"},
+							Usage:   "Keep printing the new output",
+						},
+						&cli.BoolFlag{
+							Name:  "stderr",
+							Usage: "Show the stderr log",
+						},
+						&cli.Int64Flag{
+							Name:    "bytes",
+							Aliases: []string{"n"},
+							Usage:   "Bytes from the end of the log",
+							Value:   1600,
+						},
+					}),
+					Action: func(ctx *cli.Context) error {
+						if ctx.NArg() != 1 {
+							return fmt.Errorf("usage: launch-agent supervisor tail [-f] [--stderr] <name>")
+						}
+						err := initApplication()
+						if err != nil {
+							return err
+						}
+						return tailSupervisor(ctx, ctx.Args().First(), ctx.Bool("stderr"), ctx.Int64("bytes"), ctx.Bool("follow"))
+					},
+				},
+			},
+		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -230,3 +532,152 @@ func perform(modelNames []string) error {
 
 	return nil
 }
+
+func restore(modelName, fileKey string, databases []string) error {
+	m := model.GetModelByName(modelName)
+	if m == nil {
+		return fmt.Errorf("model %s not found in %s", modelName, viper.ConfigFileUsed())
+	}
+
+	return m.Restore(fileKey, databases)
+}
+
+func verify(modelName, fileKey string) error {
+	m := model.GetModelByName(modelName)
+	if m == nil {
+		return fmt.Errorf("model %s not found in %s", modelName, viper.ConfigFileUsed())
+	}
+
+	return m.Verify(fileKey)
+}
+
+func syncCycler(modelName string, dryRun bool) error {
+	m := model.GetModelByName(modelName)
+	if m == nil {
+		return fmt.Errorf("model %s not found in %s", modelName, viper.ConfigFileUsed())
+	}
+
+	results, err := storage.Sync(m.Config, dryRun)
+	for _, result := range results {
+		fmt.Printf("Storage %s: %d orphaned, %d missing\n", result.Storage, len(result.Orphaned), len(result.Missing))
+		for _, pkg := range result.Orphaned {
+			fmt.Printf("  orphaned: %s\n", pkg.FileKey)
+		}
+		for _, pkg := range result.Missing {
+			fmt.Printf("  missing:  %s\n", pkg.FileKey)
+		}
+	}
+
+	return err
+}
+
+func list(modelName, storageName string, asJSON bool) error {
+	m := model.GetModelByName(modelName)
+	if m == nil {
+		return fmt.Errorf("model %s not found in %s", modelName, viper.ConfigFileUsed())
+	}
+
+	backups, err := storage.List(m.Config, storageName)
+
+	if asJSON {
+		if backups == nil {
+			backups = []storage.Backup{}
+		}
+		data, jsonErr := json.MarshalIndent(backups, "", "  ")
+		if jsonErr != nil {
+			return jsonErr
+		}
+		fmt.Println(string(data))
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BACKUP\tSIZE\tAGE\tPARTS\tSTORAGES")
+	for _, backup := range backups {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+			backup.FileKey,
+			humanize.IBytes(uint64(backup.Size)),
+			humanize.Time(backup.CreatedAt),
+			len(backup.FileKeys),
+			strings.Join(backup.Storages, ","),
+		)
+	}
+	w.Flush()
+
+	return err
+}
+
+func showHistory(modelName, status string, limit int, asJSON bool) error {
+	runs, err := history.Query(modelName, status, limit)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(runs, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tMODEL\tSTARTED\tDURATION\tSTATUS\tSIZE\tBACKUP\tERROR")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			run.ID,
+			run.Model,
+			run.StartedAt.Format(logger.TimeFormat),
+			time.Duration(run.Duration*float64(time.Second)).Round(time.Millisecond),
+			run.Status,
+			humanize.IBytes(uint64(run.Size)),
+			run.FileKey,
+			run.Error,
+		)
+	}
+	w.Flush()
+
+	return nil
+}
+
+func controlSupervisor(ctx *cli.Context, action string, names []string, signal string) error {
+	instance, err := supervisorInstance(ctx)
+	if err != nil {
+		return err
+	}
+
+	results, err := rpc.Control(action, names, signal, instance)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tACTION\tSTATUS\tERROR")
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Name, result.Action, result.Status, result.Error)
+	}
+	w.Flush()
+
+	return err
+}
+
+// tailSupervisor print the last bytes of the log, and the new bytes every second with `follow`
+func tailSupervisor(ctx *cli.Context, name string, stderr bool, length int64, follow bool) error {
+	instance, err := supervisorInstance(ctx)
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	for {
+		data, next, err := rpc.Tail(name, stderr, offset, length, instance)
+		if err != nil {
+			return err
+		}
+		fmt.Print(data)
+		if !follow {
+			return nil
+		}
+
+		offset = next
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,229 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/history"
+	"github.com/gigcodes/launch-util/logger"
+	"github.com/gigcodes/launch-util/psutil"
+	"github.com/gigcodes/launch-util/storage"
+)
+
+const (
+	namespace = "launch_agent"
+	gauge     = "gauge"
+	counter   = "counter"
+)
+
+var (
+	fetchPsutil = psutil.Fetch
+	summarize   = history.Summarize
+
+	// Label values escape the backslash, the double quote and the line feed
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// family of the samples with the same metric name
+type family struct {
+	name    string
+	help    string
+	kind    string
+	samples []sample
+}
+
+type sample struct {
+	// label names and values in pairs
+	labels []string
+	value  float64
+}
+
+func (f *family) add(value float64, labels ...string) {
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+// collect the metrics of the models in the config from the history and the cyclers, and the system stats
+func collect() []*family {
+	logger := logger.Tag("Metrics")
+
+	lastSuccess := &family{name: "last_success_timestamp_seconds", help: "Unix time of the last successful backup.", kind: gauge}
+	lastRun := &family{name: "last_run_timestamp_seconds", help: "Unix time of the end of the last backup.", kind: gauge}
+	lastRunSuccess := &family{name: "last_run_success", help: "1 when the last backup succeeded, 0 when it failed.", kind: gauge}
+	lastRunDuration := &family{name: "last_run_duration_seconds", help: "Duration of the last backup.", kind: gauge}
+	stageDuration := &family{name: "stage_duration_seconds", help: "Duration of each stage of the last backup.", kind: gauge}
+	archiveBytes := &family{name: "archive_bytes", help: "Size of the archive of the last successful backup.", kind: gauge}
+	uploadThroughput := &family{name: "upload_bytes_per_second", help: "Upload throughput of each storage in the last successful backup.", kind: gauge}
+	runs := &family{name: "runs_total", help: "Backups by status.", kind: counter}
+	failures := &family{name: "failures_total", help: "Failed backups by the stage which failed.", kind: counter}
+	packages := &family{name: "cycler_packages", help: "Packages kept in the cycler of each storage.", kind: gauge}
+
+	summaries, err := summarize()
+	if err != nil {
+		logger.Errorf("Load history failed: %v", err)
+	}
+
+	for _, model := range sortedModels() {
+		if counts, err := storage.PackageCounts(model); err != nil {
+			logger.Errorf("Load cycler of %s failed: %v", model.Name, err)
+		} else {
+			for _, name := range sortedKeys(counts) {
+				packages.add(float64(counts[name]), "model", model.Name, "storage", name)
+			}
+		}
+
+		summary, ok := summaries[model.Name]
+		if !ok {
+			continue
+		}
+
+		for _, status := range sortedKeys(summary.Runs) {
+			runs.add(float64(summary.Runs[status]), "model", model.Name, "status", status)
+		}
+		for _, stage := range sortedKeys(summary.Failures) {
+			failures.add(float64(summary.Failures[stage]), "model", model.Name, "stage", stage)
+		}
+
+		if run := summary.LastRun; run != nil {
+			lastRun.add(float64(run.FinishedAt.Unix()), "model", model.Name)
+			lastRunDuration.add(run.Duration, "model", model.Name)
+			success := 0.0
+			if run.Status == history.StatusFinished {
+				success = 1
+			}
+			lastRunSuccess.add(success, "model", model.Name)
+			for _, stage := range run.Stages {
+				stageDuration.add(stage.Duration, "model", model.Name, "stage", stage.Name)
+			}
+		}
+
+		if run := summary.LastSuccess; run != nil {
+			lastSuccess.add(float64(run.FinishedAt.Unix()), "model", model.Name)
+			archiveBytes.add(float64(run.Size), "model", model.Name)
+			for _, outcome := range run.Storages {
+				if outcome.Duration > 0 {
+					uploadThroughput.add(float64(run.Size)/outcome.Duration, "model", model.Name, "storage", outcome.Name)
+				}
+			}
+		}
+	}
+
+	families := []*family{lastSuccess, lastRun, lastRunSuccess, lastRunDuration, stageDuration, archiveBytes,
+		uploadThroughput, runs, failures, packages}
+
+	return append(families, collectSystem()...)
+}
+
+// collectSystem the figures of `launch-agent pulse`
+func collectSystem() []*family {
+	data, err := fetchPsutil()
+	if err != nil {
+		logger.Tag("Metrics").Errorf("Fetch system stats failed: %v", err)
+		return nil
+	}
+
+	cpu := &family{name: "system_cpu_percent", help: "CPU usage percent.", kind: gauge}
+	cpu.add(data.Load)
+	disk := &family{name: "system_disk_bytes", help: "Disk space of the first partition.", kind: gauge}
+	memory := &family{name: "system_memory_bytes", help: "Memory.", kind: gauge}
+	for _, item := range []struct {
+		family *family
+		kind   string
+		value  string
+	}{
+		{disk, "total", data.DiskTotal},
+		{disk, "free", data.DiskFree},
+		{disk, "used", data.DiskUsed},
+		{memory, "total", data.MemoryTotal},
+		{memory, "free", data.MemoryFree},
+		{memory, "used", data.MemoryUsed},
+	} {
+		value, err := strconv.ParseFloat(item.value, 64)
+		if err != nil {
+			continue
+		}
+		item.family.add(value, "type", item.kind)
+	}
+
+	return []*family{cpu, disk, memory}
+}
+
+// Write the metrics in the Prometheus text format
+func Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range collect() {
+		if len(f.samples) == 0 {
+			continue
+		}
+
+		name := namespace + "_" + f.name
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, f.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, f.kind)
+		for _, s := range f.samples {
+			bw.WriteString(name)
+			if len(s.labels) > 0 {
+				var pairs []string
+				for i := 0; i+1 < len(s.labels); i += 2 {
+					pairs = append(pairs, fmt.Sprintf(`%s="%s"`, s.labels[i], labelEscaper.Replace(s.labels[i+1])))
+				}
+				bw.WriteString("{" + strings.Join(pairs, ",") + "}")
+			}
+			bw.WriteString(" " + strconv.FormatFloat(s.value, 'f', -1, 64) + "\n")
+		}
+	}
+
+	return bw.Flush()
+}
+
+// WriteTextfile write the metrics into `metrics.textfile` for the node_exporter textfile collector,
+// the file is replaced at once so the collector never reads a partial file.
+func WriteTextfile() error {
+	textfile := config.Metrics.Textfile
+	if len(textfile) == 0 {
+		return nil
+	}
+
+	if err := helper.MkdirP(filepath.Dir(textfile)); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(textfile), filepath.Base(textfile)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), textfile)
+}
+
+func sortedModels() []config.ModelConfig {
+	models := append([]config.ModelConfig{}, config.Models...)
+	sort.Slice(models, func(i, j int) bool { return models[i].Name < models[j].Name })
+	return models
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
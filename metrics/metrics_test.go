@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/history"
+	"github.com/gigcodes/launch-util/psutil"
+)
+
+func stubSources(t *testing.T) {
+	finishedAt := time.Unix(1700000000, 0)
+	config.Models = []config.ModelConfig{{Name: "foo"}, {Name: "removed"}}
+
+	summarize = func() (map[string]*history.Summary, error) {
+		success := &history.Run{
+			Model:      "foo",
+			Status:     history.StatusFinished,
+			FinishedAt: finishedAt,
+			Size:       1000,
+			Storages:   []history.Outcome{{Name: "s3", Duration: 2}},
+		}
+		return map[string]*history.Summary{
+			"foo": {
+				Model:    "foo",
+				Runs:     map[string]uint64{history.StatusFinished: 3, history.StatusFailed: 1},
+				Failures: map[string]uint64{"storage": 1},
+				LastRun: &history.Run{
+					Model:      "foo",
+					Status:     history.StatusFailed,
+					FinishedAt: finishedAt.Add(time.Hour),
+					Duration:   1.5,
+					Stages:     []history.Stage{{Name: "database", Duration: 0.5}, {Name: "storage", Duration: 1}},
+				},
+				LastSuccess: success,
+			},
+			"bar": {Model: "bar", LastRun: success, LastSuccess: success},
+		}, nil
+	}
+	fetchPsutil = func() (*psutil.Psutil, error) {
+		return &psutil.Psutil{Load: 12.5, DiskTotal: "100", DiskFree: "40", DiskUsed: "60", MemoryTotal: "8", MemoryFree: "2", MemoryUsed: "6"}, nil
+	}
+	t.Cleanup(func() {
+		summarize = history.Summarize
+		fetchPsutil = psutil.Fetch
+	})
+}
+
+func TestWrite(t *testing.T) {
+	stubSources(t)
+
+	var buf bytes.Buffer
+	assert.NoError(t, Write(&buf))
+	out := buf.String()
+
+	for _, line := range []string{
+		"# TYPE launch_agent_last_success_timestamp_seconds gauge",
+		`launch_agent_last_success_timestamp_seconds{model="foo"} 1700000000`,
+		`launch_agent_last_run_timestamp_seconds{model="foo"} 1700003600`,
+		`launch_agent_last_run_success{model="foo"} 0`,
+		`launch_agent_last_run_duration_seconds{model="foo"} 1.5`,
+		`launch_agent_stage_duration_seconds{model="foo",stage="database"} 0.5`,
+		`launch_agent_archive_bytes{model="foo"} 1000`,
+		`launch_agent_upload_bytes_per_second{model="foo",storage="s3"} 500`,
+		"# TYPE launch_agent_runs_total counter",
+		`launch_agent_runs_total{model="foo",status="failed"} 1`,
+		`launch_agent_runs_total{model="foo",status="finished"} 3`,
+		`launch_agent_failures_total{model="foo",stage="storage"} 1`,
+		`launch_agent_system_cpu_percent 12.5`,
+		`launch_agent_system_disk_bytes{type="free"} 40`,
+		`launch_agent_system_memory_bytes{type="used"} 6`,
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+
+	// Only the models in the config
+	assert.NotContains(t, out, `model="bar"`)
+}
+
+func TestWriteTextfile(t *testing.T) {
+	stubSources(t)
+
+	config.Metrics.Textfile = filepath.Join(t.TempDir(), "textfile", "launch_agent.prom")
+	defer func() { config.Metrics.Textfile = "" }()
+
+	assert.NoError(t, WriteTextfile())
+	data, err := os.ReadFile(config.Metrics.Textfile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `launch_agent_last_success_timestamp_seconds{model="foo"} 1700000000`)
+
+	entries, err := os.ReadDir(filepath.Dir(config.Metrics.Textfile))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestLabelEscaper(t *testing.T) {
+	assert.Equal(t, `a\\b\"c\nd`, labelEscaper.Replace("a\\b\"c\nd"))
+}
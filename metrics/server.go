@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/logger"
+)
+
+var (
+	server     *http.Server
+	listen     string
+	serverLock = sync.Mutex{}
+)
+
+func init() {
+	config.OnConfigChange(func(in fsnotify.Event) {
+		serverLock.Lock()
+		changed := config.Metrics.Enabled != (server != nil) || config.Metrics.Listen != listen
+		serverLock.Unlock()
+
+		if changed {
+			if err := Restart(); err != nil {
+				logger.Tag("Metrics").Error(err)
+			}
+		}
+	})
+}
+
+// Start serving `/metrics` when `metrics.enabled` is true
+func Start() error {
+	logger := logger.Tag("Metrics")
+
+	serverLock.Lock()
+	defer serverLock.Unlock()
+
+	if !config.Metrics.Enabled {
+		return nil
+	}
+
+	listener, err := helper.Listen(config.Metrics.Listen)
+	if err != nil {
+		return fmt.Errorf("metrics listen on %s failed: %w", config.Metrics.Listen, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", handleMetrics)
+	server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	listen = config.Metrics.Listen
+
+	go func(server *http.Server) {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("Serve failed: %v", err)
+		}
+	}(server)
+	logger.Info("Listening on", listen)
+
+	return nil
+}
+
+// Stop the server, wait the scrapes in progress for 5 seconds
+func Stop() {
+	serverLock.Lock()
+	defer serverLock.Unlock()
+
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Tag("Metrics").Errorf("Shutdown failed: %v", err)
+	}
+	server = nil
+	listen = ""
+}
+
+func Restart() error {
+	logger.Tag("Metrics").Info("Reloading...")
+	Stop()
+	return Start()
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := Write(w); err != nil {
+		logger.Tag("Metrics").Errorf("Write metrics failed: %v", err)
+	}
+}
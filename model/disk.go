@@ -0,0 +1,64 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/gigcodes/launch-util/history"
+	"github.com/gigcodes/launch-util/logger"
+	"github.com/gigcodes/launch-util/psutil"
+)
+
+// checkDisk refuse to start when the free disk of the temp path is below the estimated archive size,
+// the size of the last finished backup times `disk_check_factor`. The dumps and the archive are both
+// in the temp path before the upload. The check is skipped without a finished backup, and in stream
+// mode which only keeps the chunks being uploaded.
+func (m Model) checkDisk() error {
+	if m.Config.DiskCheckFactor <= 0 || m.Config.Stream {
+		return nil
+	}
+
+	tag := logger.Tag(fmt.Sprintf("Model: %s", m.Config.Name))
+
+	runs, err := history.Query(m.Config.Name, history.StatusFinished, 1)
+	if err != nil {
+		tag.Warnf("Skip the disk check, load history failed: %v", err)
+		return nil
+	}
+	if len(runs) == 0 || runs[0].Size <= 0 {
+		return nil
+	}
+
+	path := existingDir(m.Config.TempPath)
+	free, err := psutil.DiskFree(path)
+	if err != nil {
+		tag.Warnf("Skip the disk check, fetch disk usage of %s failed: %v", path, err)
+		return nil
+	}
+
+	estimated := uint64(float64(runs[0].Size) * m.Config.DiskCheckFactor)
+	if free < estimated {
+		return fmt.Errorf("free disk %s of %s is below the estimated archive size %s (%s of the last backup × %g)",
+			humanize.IBytes(free), path, humanize.IBytes(estimated), humanize.IBytes(uint64(runs[0].Size)), m.Config.DiskCheckFactor)
+	}
+
+	tag.Infof("Free disk %s of %s, the estimated archive size is %s", humanize.IBytes(free), path, humanize.IBytes(estimated))
+	return nil
+}
+
+// existingDir of the path, the nearest parent which exists
+func existingDir(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
@@ -1,60 +1,58 @@
 package model
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
 
 	"github.com/gigcodes/launch-util/archive"
 	"github.com/gigcodes/launch-util/compressor"
 	"github.com/gigcodes/launch-util/config"
 	"github.com/gigcodes/launch-util/database"
+	"github.com/gigcodes/launch-util/encryptor"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/history"
 	"github.com/gigcodes/launch-util/logger"
+	"github.com/gigcodes/launch-util/metrics"
 	"github.com/gigcodes/launch-util/notifier"
+	"github.com/gigcodes/launch-util/splitter"
 	"github.com/gigcodes/launch-util/storage"
 	"github.com/spf13/viper"
 )
 
+var (
+	runStorages = storage.Run
+)
+
 type Model struct {
 	Config config.ModelConfig
 }
 
-// Perform model
+// Perform model, the run is recorded into the history
 func (m Model) Perform() (err error) {
 	tag := logger.Tag(fmt.Sprintf("Model: %s", m.Config.Name))
 
-	webhook := notifier.NewWebhook(m.Config.Webhook)
+	run := history.NewRun(m.Config.Name)
 
 	var fileSize int64
 
 	defer func() {
+		status := history.StatusFinished
 		if err != nil {
 			tag.Error(err)
-			payload := map[string]interface{}{
-				"error":  err.Error(),
-				"model":  m.Config.Name,
-				"status": "failed",
-			}
-
-			fmt.Println(payload)
-
-			err := webhook.Notify(payload)
-			if err != nil {
-				fmt.Println("Error sending notification:", err)
-			}
-		} else {
-			payload := map[string]interface{}{
-				"error":  nil,
-				"status": "finished",
-				"model":  m.Config.Name,
-				"size":   fileSize,
-			}
-
-			fmt.Println(payload)
-			err := webhook.Notify(payload)
-			if err != nil {
-				fmt.Println("Error sending notification:", err)
+			status = history.StatusFailed
+			var verifyErr *verifyError
+			if errors.As(err, &verifyErr) {
+				status = history.StatusVerifyFailed
 			}
 		}
+		m.saveRun(run, status, fileSize, err)
+		m.notify(notifier.EventBackup, status, run, err)
 	}()
 
 	tag.Info("WorkDir:", m.Config.DumpPath)
@@ -67,12 +65,44 @@ func (m Model) Perform() (err error) {
 		m.after()
 	}()
 
-	err = database.Run(m.Config)
+	fileSize, err = m.perform(run)
+	return
+}
+
+// perform the stages of the model, return the size of the archive
+func (m Model) perform(run *history.Run) (fileSize int64, err error) {
+	tag := logger.Tag(fmt.Sprintf("Model: %s", m.Config.Name))
+
+	run.Stage("disk")
+	if err = m.checkDisk(); err != nil {
+		return
+	}
+
+	if m.Config.Stream {
+		var fileKey string
+		run.Stage("stream")
+		fileSize, fileKey, err = m.performStream(run)
+		if err != nil {
+			return
+		}
+		run.FileKey = fileKey
+		run.Stage("verify")
+		err = m.verifyAfterUpload(fileKey)
+		return
+	}
+
+	// The pending upload which fails again is kept for the next perform, it does not block the new backup
+	run.Stage("resume")
+	m.resumePending()
+
+	run.Stage("database")
+	run.Databases, err = database.Run(m.Config)
 	if err != nil {
 		return
 	}
 
 	if m.Config.Archive != nil {
+		run.Stage("archive")
 		err = archive.Run(m.Config)
 		if err != nil {
 			return
@@ -80,7 +110,22 @@ func (m Model) Perform() (err error) {
 	}
 
 	// It always to use compressor, default use tar, even not enable compress.
+	run.Stage("compressor")
 	archivePath, err := compressor.Run(m.Config)
+	if err != nil {
+		return
+	}
+
+	run.Stage("encryptor")
+	archivePath, err = encryptor.Run(archivePath, m.Config)
+	if err != nil {
+		return
+	}
+
+	if err = m.checksum(archivePath); err != nil {
+		return
+	}
+
 	fileInfo, err := os.Stat(archivePath)
 	if err != nil {
 		tag.Errorf("Error fetching file info: %v", err)
@@ -91,12 +136,123 @@ func (m Model) Perform() (err error) {
 		return
 	}
 
-	err = storage.Run(m.Config, archivePath)
+	run.Stage("splitter")
+	archivePath, err = splitter.Run(archivePath, m.Config)
+	if err != nil {
+		return
+	}
+	run.FileKey = filepath.Base(archivePath)
+
+	run.Stage("storage")
+	run.Storages, err = runStorages(m.Config, archivePath)
+	if err != nil {
+		m.keepPending(archivePath, run.Storages)
+		return
+	}
+
+	// The next incremental archive is based on this one only when it is stored
+	if err = archive.Commit(m.Config); err != nil {
+		return
+	}
+
+	run.Stage("verify")
+	err = m.verifyAfterUpload(filepath.Base(archivePath))
+	return
+}
+
+// saveRun finish the run and save it into the history then update the metrics textfile,
+// a failure to save does not fail the backup
+func (m Model) saveRun(run *history.Run, status string, size int64, err error) {
+	run.Size = size
+	run.Finish(status, err)
+	if err := history.Save(run); err != nil {
+		logger.Tag(fmt.Sprintf("Model: %s", m.Config.Name)).Errorf("Save history failed: %v", err)
+	}
+
+	// The metrics are based on the history
+	if err := metrics.WriteTextfile(); err != nil {
+		logger.Tag(fmt.Sprintf("Model: %s", m.Config.Name)).Errorf("Write metrics textfile failed: %v", err)
+	}
+}
+
+// notify the notifiers of the model with the result, the payload of the webhook without `body`
+// is {"model", "status", "error", "size"}.
+func (m Model) notify(event, status string, run *history.Run, err error) {
+	message := notifier.Message{
+		Event:   event,
+		Model:   m.Config.Name,
+		Status:  status,
+		Success: err == nil,
+	}
+
+	payload := map[string]interface{}{
+		"error":  nil,
+		"model":  m.Config.Name,
+		"status": status,
+	}
+
+	var text []string
+	if event == notifier.EventVerify {
+		message.Title = fmt.Sprintf("Verify %s %s", m.Config.Name, strings.ReplaceAll(status, "_", " "))
+	} else {
+		message.Title = fmt.Sprintf("Backup %s %s", m.Config.Name, strings.ReplaceAll(status, "_", " "))
+		payload["size"] = run.Size
+		if len(run.FileKey) > 0 {
+			text = append(text, "File: "+run.FileKey)
+		}
+		text = append(text, fmt.Sprintf("Size: %s", humanize.Bytes(uint64(run.Size))))
+		text = append(text, fmt.Sprintf("Duration: %s", time.Duration(run.Duration*float64(time.Second)).Round(time.Second)))
+	}
+	if err != nil {
+		message.Error = err.Error()
+		payload["error"] = err.Error()
+		if len(run.FailedStage) > 0 {
+			text = append(text, "Stage: "+run.FailedStage)
+		}
+		text = append(text, "Error: "+err.Error())
+	}
+	message.Text = strings.Join(text, "\n")
+	message.Payload = payload
+
+	if err := notifier.Send(m.Config.Notifiers, m.Config.Retry, message); err != nil {
+		logger.Tag(fmt.Sprintf("Model: %s", m.Config.Name)).Errorf("Send notification failed: %v", err)
+	}
+}
+
+// Restore model databases from the backup `fileKey` in the default storage,
+// the latest backup is used when `fileKey` is empty.
+func (m Model) Restore(fileKey string, databases []string) (err error) {
+	tag := logger.Tag(fmt.Sprintf("Model: %s", m.Config.Name))
+
+	tag.Info("WorkDir:", m.Config.DumpPath)
+	defer m.after()
+
+	if err = helper.MkdirP(m.Config.TempPath); err != nil {
+		return
+	}
+
+	archivePath, err := storage.Download(m.Config, fileKey, m.Config.TempPath)
+	if err != nil {
+		return
+	}
+
+	archivePath, err = encryptor.Decrypt(archivePath, m.Config)
 	if err != nil {
 		return
 	}
 
-	return nil
+	// The archive contains the `DumpPath` directory, see compressor.Tar
+	tag.Info("Extracting", archivePath)
+	if _, err = helper.Exec("tar", "-xf", archivePath, "-C", m.Config.TempPath); err != nil {
+		return fmt.Errorf("extract %s failed: %v", archivePath, err)
+	}
+
+	// Join the dumps which were split into parts in stream mode
+	if err = compressor.JoinParts(m.Config.TempPath); err != nil {
+		return
+	}
+
+	return database.Restore(m.Config, databases)
 }
 
 // Cleanup model temp files
@@ -0,0 +1,233 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/gigcodes/launch-util/archive"
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/history"
+	"github.com/gigcodes/launch-util/logger"
+	"github.com/gigcodes/launch-util/storage"
+)
+
+var (
+	pendingPath = filepath.Join(config.LaunchAgentDir, "pending")
+)
+
+// pendingUpload of the archive whose upload failed, the archive is kept under `LaunchAgentDir/pending/<model>/`
+// with the same file key, so the next perform resumes its upload with the upload states of the storages.
+type pendingUpload struct {
+	FileKey string `json:"file_key"`
+	// Storages failed to upload the archive
+	Storages  []string  `json:"storages"`
+	Checksum  string    `json:"checksum"`
+	MD5       string    `json:"md5"`
+	Chain     string    `json:"chain,omitempty"`
+	Level     int       `json:"level,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (m Model) pendingDir() string {
+	return filepath.Join(pendingPath, m.Config.Name)
+}
+
+func (m Model) pendingFileName() string {
+	return filepath.Join(pendingPath, m.Config.Name+".json")
+}
+
+// keepPending move the archive into the pending dir of the model after the upload failed in `outcomes`,
+// instead of removing it with the temp path. A failure to keep it does not change the result of the perform.
+func (m Model) keepPending(archivePath string, outcomes []history.Outcome) {
+	tag := logger.Tag(fmt.Sprintf("Model: %s", m.Config.Name))
+
+	pending := pendingUpload{
+		FileKey:   filepath.Base(archivePath),
+		CreatedAt: time.Now(),
+	}
+	for _, outcome := range outcomes {
+		if outcome.Status == history.StatusFailed {
+			pending.Storages = append(pending.Storages, outcome.Name)
+		}
+	}
+	if m.Config.Viper != nil {
+		pending.Checksum = m.Config.Viper.GetString("Checksum")
+		pending.MD5 = m.Config.Viper.GetString("MD5")
+		pending.Chain = m.Config.Viper.GetString("ArchiveChain")
+		pending.Level = m.Config.Viper.GetInt("ArchiveLevel")
+	}
+	if len(pending.Storages) == 0 {
+		return
+	}
+
+	m.removePending()
+	dir := m.pendingDir()
+	if err := helper.MkdirP(dir); err != nil {
+		tag.Errorf("Keep %s for the next perform failed: %v", pending.FileKey, err)
+		return
+	}
+	if err := movePath(archivePath, filepath.Join(dir, pending.FileKey)); err != nil {
+		tag.Errorf("Keep %s for the next perform failed: %v", pending.FileKey, err)
+		m.removePending()
+		return
+	}
+
+	data, err := json.Marshal(pending)
+	if err == nil {
+		err = os.WriteFile(m.pendingFileName(), data, 0660)
+	}
+	if err != nil {
+		tag.Errorf("Keep %s for the next perform failed: %v", pending.FileKey, err)
+		m.removePending()
+		return
+	}
+
+	tag.Infof("Keep %s in %s, the next perform within %s resumes the upload", pending.FileKey, dir, storage.ResumeMaxAge)
+}
+
+// resumePending upload the pending archive of the model to the storages which failed to upload it,
+// before the new backup is made. The archive older than storage.ResumeMaxAge is removed instead.
+// A failure is logged and the pending upload is kept, the new backup is made anyway.
+func (m Model) resumePending() {
+	tag := logger.Tag(fmt.Sprintf("Model: %s", m.Config.Name))
+
+	data, err := os.ReadFile(m.pendingFileName())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		tag.Errorf("Read the pending upload failed: %v", err)
+		return
+	}
+
+	var pending pendingUpload
+	if err := json.Unmarshal(data, &pending); err != nil {
+		tag.Warnf("Remove the invalid pending upload: %v", err)
+		m.removePending()
+		return
+	}
+
+	if time.Since(pending.CreatedAt) > storage.ResumeMaxAge {
+		tag.Warnf("Remove the pending upload of %s, it is older than %s", pending.FileKey, storage.ResumeMaxAge)
+		m.removePending()
+		return
+	}
+
+	model := m.Config
+	model.Storages = map[string]config.SubConfig{}
+	for _, name := range pending.Storages {
+		if storageConfig, ok := m.Config.Storages[name]; ok {
+			model.Storages[name] = storageConfig
+		}
+	}
+	if len(model.Storages) == 0 {
+		m.removePending()
+		return
+	}
+
+	if model.Viper != nil {
+		model.Viper.Set("Checksum", pending.Checksum)
+		model.Viper.Set("MD5", pending.MD5)
+		model.Viper.Set("ArchiveChain", pending.Chain)
+		model.Viper.Set("ArchiveLevel", pending.Level)
+	}
+
+	tag.Infof("Resume the upload of %s from %s", pending.FileKey, pending.CreatedAt.Format(time.RFC3339))
+	outcomes, err := runStorages(model, filepath.Join(m.pendingDir(), pending.FileKey))
+	if err != nil {
+		// Only retry the storages which failed again
+		pending.Storages = nil
+		for _, outcome := range outcomes {
+			if outcome.Status == history.StatusFailed {
+				pending.Storages = append(pending.Storages, outcome.Name)
+			}
+		}
+		if data, err := json.Marshal(pending); err == nil {
+			_ = os.WriteFile(m.pendingFileName(), data, 0660)
+		}
+		tag.Errorf("Resume the upload of %s failed, retry on the next perform: %v", pending.FileKey, err)
+		return
+	}
+
+	// The pending snapshot of the incremental archive is the one of this archive
+	if err := archive.Commit(m.Config); err != nil {
+		tag.Errorf("Commit the archive of %s failed: %v", pending.FileKey, err)
+	}
+	m.removePending()
+
+	defer os.RemoveAll(filepath.Join(m.Config.TempPath, "verify"))
+	if err := m.verifyAfterUpload(pending.FileKey); err != nil {
+		tag.Errorf("Verify the resumed upload of %s failed: %v", pending.FileKey, err)
+	}
+}
+
+func (m Model) removePending() {
+	if err := os.RemoveAll(m.pendingDir()); err != nil {
+		logger.Tag(fmt.Sprintf("Model: %s", m.Config.Name)).Errorf("Remove pending upload failed: %v", err)
+	}
+	_ = os.Remove(m.pendingFileName())
+}
+
+// movePath rename the file or the directory of the split parts, it is copied with the modification time
+// when the pending dir is on another device, the upload states are kept by the size and the modification time.
+func movePath(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return copyFile(src, dst, info)
+	}
+
+	if err := helper.MkdirP(dst); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if err := copyFile(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name()), info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
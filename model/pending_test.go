@@ -0,0 +1,75 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/history"
+	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
+)
+
+func TestPerform_pendingFails(t *testing.T) {
+	pendingPath = t.TempDir()
+	dir, err := os.Getwd()
+	assert.NoError(t, err)
+	defer os.Chdir(dir) //nolint:errcheck
+
+	tempPath := t.TempDir()
+	dumpPath := filepath.Join(tempPath, "test")
+	assert.NoError(t, os.MkdirAll(dumpPath, 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(dumpPath, "dump.sql"), []byte("hello"), 0660))
+
+	m := Model{
+		Config: config.ModelConfig{
+			Name:     "test",
+			TempPath: tempPath,
+			DumpPath: dumpPath,
+			Viper:    viper.New(),
+			Storages: map[string]config.SubConfig{
+				"local": {Name: "local", Type: "local", Viper: viper.New()},
+			},
+		},
+	}
+
+	// The pending upload to the storage which stays down
+	assert.NoError(t, os.MkdirAll(m.pendingDir(), 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(m.pendingDir(), "2022.12.04.07.09.47.tar"), []byte("hello"), 0660))
+	data, err := json.Marshal(pendingUpload{FileKey: "2022.12.04.07.09.47.tar", Storages: []string{"local"}, CreatedAt: time.Now()})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(m.pendingFileName(), data, 0660))
+
+	var uploaded []string
+	stub := runStorages
+	runStorages = func(model config.ModelConfig, archivePath string) ([]history.Outcome, error) {
+		if strings.HasPrefix(archivePath, pendingPath) {
+			err := fmt.Errorf("storage is down")
+			return []history.Outcome{history.NewOutcome("local", "local", time.Now(), err)}, err
+		}
+		uploaded = append(uploaded, filepath.Base(archivePath))
+		return []history.Outcome{history.NewOutcome("local", "local", time.Now(), nil)}, nil
+	}
+	defer func() { runStorages = stub }()
+
+	// The new backup is uploaded, the pending upload is kept for the next perform
+	run := history.NewRun("test")
+	_, err = m.perform(run)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(uploaded))
+	assert.Equal(t, run.FileKey, uploaded[0])
+
+	data, err = os.ReadFile(m.pendingFileName())
+	assert.NoError(t, err)
+	var pending pendingUpload
+	assert.NoError(t, json.Unmarshal(data, &pending))
+	assert.Equal(t, "2022.12.04.07.09.47.tar", pending.FileKey)
+	assert.Equal(t, []string{"local"}, pending.Storages)
+	_, err = os.Stat(filepath.Join(m.pendingDir(), "2022.12.04.07.09.47.tar"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/gigcodes/launch-util/archive"
+	"github.com/gigcodes/launch-util/compressor"
+	"github.com/gigcodes/launch-util/database"
+	"github.com/gigcodes/launch-util/history"
+	"github.com/gigcodes/launch-util/storage"
+)
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+// performStream dump, compress and upload the backup on the fly, without staging it on disk.
+// Return the size and the file key of the uploaded archive, the outcomes are recorded into the run.
+func (m Model) performStream(run *history.Run) (int64, string, error) {
+	if len(m.Config.EncryptWith.Type) > 0 {
+		return 0, "", fmt.Errorf("encrypt_with is not supported in stream mode")
+	}
+	if m.Config.SplitIntoChunksOf > 0 {
+		return 0, "", fmt.Errorf("split_into_chunks_of is not supported in stream mode")
+	}
+
+	pr, pw := io.Pipe()
+	stream, fileKey, err := compressor.NewStream(m.Config, pw)
+	if err != nil {
+		return 0, "", err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var err error
+		run.Databases, err = database.Stream(m.Config, stream.AddStream)
+		if err == nil {
+			err = archive.Stream(m.Config, stream)
+		}
+		if err == nil {
+			err = stream.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	reader := &countingReader{r: pr}
+	run.Storages, err = storage.RunStream(m.Config, fileKey, reader)
+	// Unblock the producer when the storages failed
+	pr.CloseWithError(fmt.Errorf("storage aborted"))
+	<-done
+	if err != nil {
+		return 0, "", err
+	}
+
+	return atomic.LoadInt64(&reader.n), fileKey, nil
+}
@@ -0,0 +1,133 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gigcodes/launch-util/compressor"
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/encryptor"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/history"
+	"github.com/gigcodes/launch-util/logger"
+	"github.com/gigcodes/launch-util/notifier"
+	"github.com/gigcodes/launch-util/storage"
+)
+
+// verifyError is notified with the `verify_failed` status
+type verifyError struct {
+	err error
+}
+
+func (e *verifyError) Error() string {
+	return fmt.Sprintf("verify failed: %v", e.err)
+}
+
+func (e *verifyError) Unwrap() error {
+	return e.err
+}
+
+// Verify the backup `fileKey` of the model in all storages, the latest backup is used when `fileKey` is empty.
+// The failure is sent to the notifiers with `status: "verify_failed"`.
+func (m Model) Verify(fileKey string) (err error) {
+	tag := logger.Tag(fmt.Sprintf("Model: %s", m.Config.Name))
+
+	defer m.after()
+
+	err = m.verify(fileKey)
+	if err != nil {
+		err = &verifyError{err: err}
+		tag.Error(err)
+
+		m.notify(notifier.EventVerify, history.StatusVerifyFailed, history.NewRun(m.Config.Name), err)
+		return err
+	}
+
+	tag.Info("Verify succeeded")
+	return nil
+}
+
+// verifyAfterUpload verify the uploaded backup when `verify: true`
+func (m Model) verifyAfterUpload(fileKey string) error {
+	if !m.Config.Verify {
+		return nil
+	}
+
+	if err := m.verify(fileKey); err != nil {
+		return &verifyError{err: err}
+	}
+	return nil
+}
+
+// checksum save the SHA-256 and MD5 of the archive for storages and verification
+func (m Model) checksum(archivePath string) error {
+	sha256sum, md5sum, err := helper.FileChecksum(archivePath)
+	if err != nil {
+		return fmt.Errorf("checksum %s failed: %v", archivePath, err)
+	}
+
+	if m.Config.Viper != nil {
+		m.Config.Viper.Set("Checksum", sha256sum)
+		m.Config.Viper.Set("MD5", md5sum)
+	}
+	return nil
+}
+
+func (m Model) verify(fileKey string) error {
+	tag := logger.Tag("Verify")
+
+	targetDir := filepath.Join(m.Config.TempPath, "verify")
+	archivePath, err := storage.Verify(m.Config, fileKey, targetDir)
+	if err != nil {
+		return err
+	}
+
+	archivePath, err = encryptor.Decrypt(archivePath, m.Config)
+	if err != nil {
+		return err
+	}
+
+	extractDir := filepath.Join(targetDir, "extract")
+	if err := helper.MkdirP(extractDir); err != nil {
+		return err
+	}
+
+	tag.Info("Extracting", archivePath)
+	if _, err := helper.Exec("tar", "-xf", archivePath, "-C", extractDir); err != nil {
+		return fmt.Errorf("extract %s failed: %v", archivePath, err)
+	}
+	if err := compressor.JoinParts(extractDir); err != nil {
+		return err
+	}
+
+	return checkDumps(m.Config, extractDir)
+}
+
+// checkDumps check each database of the model has a non-empty dump in the extracted archive
+func checkDumps(model config.ModelConfig, dir string) error {
+	modelDir := filepath.Join(dir, filepath.Base(model.DumpPath))
+
+	for _, dbConfig := range model.Databases {
+		dumpPath := filepath.Join(modelDir, dbConfig.Type, dbConfig.Name)
+
+		var size int64
+		err := filepath.Walk(dumpPath, func(_ string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				size += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("dump of database %s not found in archive: %v", dbConfig.Name, err)
+		}
+		if size == 0 {
+			return fmt.Errorf("dump of database %s is empty", dbConfig.Name)
+		}
+	}
+
+	return nil
+}
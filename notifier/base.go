@@ -0,0 +1,226 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/logger"
+)
+
+const (
+	EventBackup     = "backup"
+	EventVerify     = "verify"
+	EventPulse      = "pulse"
+	EventSupervisor = "supervisor"
+)
+
+var (
+	httpClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+// Message of an event sent to the notifiers
+type Message struct {
+	// Event backup, verify, pulse or supervisor
+	Event string
+	Model string
+	// Status of the event, `finished`, `failed`, `verify_failed` for the models
+	Status string
+	// Success false is sent to the notifiers with `on_failure`, true to the ones with `on_success`
+	Success bool
+	Title   string
+	Text    string
+	Error   string
+	// Payload is posted as JSON by the webhook without `body`
+	Payload  interface{}
+	Hostname string
+	Time     time.Time
+}
+
+// Notifier interface
+type Notifier interface {
+	Notify(message Message) error
+}
+
+// Base notifier
+type Base struct {
+	name      string
+	viper     *viper.Viper
+	onSuccess bool
+	onFailure bool
+	retry     helper.RetryConfig
+}
+
+func newBase(notifierConfig config.SubConfig, retry helper.RetryConfig) (base Base, err error) {
+	base = Base{
+		name:      notifierConfig.Name,
+		viper:     notifierConfig.Viper,
+		onSuccess: true,
+		onFailure: true,
+		retry:     retry,
+	}
+	if base.viper == nil {
+		base.viper = viper.New()
+	}
+
+	base.viper.SetDefault("on_success", true)
+	base.viper.SetDefault("on_failure", true)
+	base.onSuccess = base.viper.GetBool("on_success")
+	base.onFailure = base.viper.GetBool("on_failure")
+
+	base.retry, err = config.LoadRetryConfig(base.viper.Sub("retry"), retry)
+	return
+}
+
+func new(notifierConfig config.SubConfig, retry helper.RetryConfig) (Base, Notifier, error) {
+	base, err := newBase(notifierConfig, retry)
+	if err != nil {
+		return base, nil, err
+	}
+
+	var n Notifier
+	switch notifierConfig.Type {
+	case "webhook":
+		n = &Webhook{Base: base}
+	case "slack":
+		n = &Slack{Base: base}
+	case "discord":
+		n = &Discord{Base: base}
+	case "teams":
+		n = &Teams{Base: base}
+	case "smtp":
+		n = &SMTP{Base: base}
+	case "telegram":
+		n = &Telegram{Base: base}
+	default:
+		return base, nil, fmt.Errorf("[%s] notifier type has not implement", notifierConfig.Type)
+	}
+
+	return base, n, nil
+}
+
+// Send the message to the notifiers by `on_success` and `on_failure`, a failed notifier is retried with `retry`
+// which defaults to `defaultRetry`, the errors of all notifiers are returned together.
+func Send(notifiers map[string]config.SubConfig, defaultRetry helper.RetryConfig, message Message) error {
+	if message.Time.IsZero() {
+		message.Time = time.Now()
+	}
+	if len(message.Hostname) == 0 {
+		message.Hostname, _ = os.Hostname()
+	}
+
+	names := make([]string, 0, len(notifiers))
+	for name := range notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		logger := logger.Tag(fmt.Sprintf("Notifier: %s", name))
+
+		base, n, err := new(notifiers[name], defaultRetry)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		if !base.accept(message) {
+			continue
+		}
+
+		logger.Infof("Sending %s notification...", message.Event)
+		err = base.retry.Do(logger, "Notification", func() error {
+			return n.Notify(message)
+		})
+		if err != nil {
+			logger.Errorf("Notification failed: %v", err)
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		logger.Info("Notification sent successfully.")
+	}
+
+	return errors.Join(errs...)
+}
+
+// accept return true when the notifier is subscribed to the result of the message
+func (b Base) accept(message Message) bool {
+	if message.Success {
+		return b.onSuccess
+	}
+	return b.onFailure
+}
+
+// postJSON post the payload as JSON to the url
+func (b Base) postJSON(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return send(http.MethodPost, url, "application/json", nil, body)
+}
+
+// send the request once, the url is left out of the errors as the webhook urls and the bot tokens are secrets
+func send(method, rawURL, contentType string, headers map[string]string, body []byte) error {
+	if len(rawURL) == 0 {
+		return errors.New("url is required")
+	}
+
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("invalid url: %w", urlErr.Err)
+		}
+		return err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("%s: %w", urlErr.Op, urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status: %d, body: %s", resp.StatusCode, string(responseBody))
+	}
+
+	return nil
+}
+
+// truncate the text to `limit` characters for the services with a message size limit
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+
+	runes := []rune(text)
+	return string(runes[:limit-1]) + "…"
+}
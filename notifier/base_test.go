@@ -0,0 +1,140 @@
+package notifier
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/longbridgeapp/assert"
+)
+
+// recorder of the JSON requests by path
+type recorder struct {
+	*httptest.Server
+	requests map[string]map[string]interface{}
+}
+
+func newRecorder(t *testing.T) *recorder {
+	r := &recorder{requests: map[string]map[string]interface{}{}}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		r.requests[req.URL.Path] = body
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestSend(t *testing.T) {
+	server := newRecorder(t)
+
+	notifiers := map[string]config.SubConfig{
+		"slack":    newConfig("slack", "slack", map[string]interface{}{"url": server.URL + "/slack"}),
+		"discord":  newConfig("discord", "discord", map[string]interface{}{"url": server.URL + "/discord", "on_success": false}),
+		"teams":    newConfig("teams", "teams", map[string]interface{}{"url": server.URL + "/teams", "on_failure": false}),
+		"telegram": newConfig("telegram", "telegram", map[string]interface{}{"endpoint": server.URL, "token": "123:abc", "chat_id": "-100"}),
+	}
+
+	message := Message{Event: EventBackup, Model: "db", Status: "finished", Success: true, Title: "Backup db finished", Text: "Size: 1 kB"}
+	assert.NoError(t, Send(notifiers, helper.RetryConfig{}, message))
+
+	assert.Equal(t, "*Backup db finished*", server.requests["/slack"]["text"])
+	attachment := server.requests["/slack"]["attachments"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, colorSuccess, attachment["color"])
+	assert.Equal(t, "Size: 1 kB", attachment["text"])
+
+	assert.Equal(t, "Backup db finished", server.requests["/teams"]["title"])
+	assert.Equal(t, "2EB67D", server.requests["/teams"]["themeColor"])
+
+	assert.Equal(t, "-100", server.requests["/bot123:abc/sendMessage"]["chat_id"])
+	assert.Equal(t, "Backup db finished\n\nSize: 1 kB", server.requests["/bot123:abc/sendMessage"]["text"])
+
+	// on_success: false
+	_, ok := server.requests["/discord"]
+	assert.False(t, ok)
+
+	server.requests = map[string]map[string]interface{}{}
+	message = Message{Event: EventBackup, Model: "db", Status: "failed", Title: "Backup db failed", Text: "Error: boom", Time: time.Unix(0, 0)}
+	assert.NoError(t, Send(notifiers, helper.RetryConfig{}, message))
+
+	embed := server.requests["/discord"]["embeds"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Backup db failed", embed["title"])
+	assert.Equal(t, "Error: boom", embed["description"])
+	assert.Equal(t, float64(0xE01E5A), embed["color"])
+
+	// on_failure: false
+	_, ok = server.requests["/teams"]
+	assert.False(t, ok)
+	assert.Len(t, server.requests, 3)
+}
+
+func TestSend_errors(t *testing.T) {
+	server := newRecorder(t)
+
+	notifiers := map[string]config.SubConfig{
+		"slack":   newConfig("slack", "slack", map[string]interface{}{"url": server.URL + "/slack"}),
+		"unknown": newConfig("unknown", "pager", nil),
+		"teams":   newConfig("teams", "teams", nil),
+	}
+
+	err := Send(notifiers, helper.RetryConfig{}, Message{Success: true, Title: "Pulse"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown: [pager] notifier type has not implement")
+	assert.Contains(t, err.Error(), "teams: url is required")
+
+	// The other notifiers are still sent
+	assert.Equal(t, "*Pulse*", server.requests["/slack"]["text"])
+}
+
+func Test_send_hideURL(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	err := send(http.MethodPost, server.URL+"/bot123:secret/sendMessage", "application/json", nil, nil)
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+}
+
+func TestSMTP_Notify(t *testing.T) {
+	var addr, from, msg string
+	var to []string
+	var useTLS bool
+	defer func(original func(string, *tls.Config, smtp.Auth, string, []string, []byte) error) {
+		sendMail = original
+	}(sendMail)
+	sendMail = func(a string, tlsConfig *tls.Config, auth smtp.Auth, f string, t []string, m []byte) error {
+		addr, useTLS, from, to, msg = a, tlsConfig != nil, f, t, string(m)
+		return nil
+	}
+
+	s := &SMTP{Base: Base{viper: newConfig("mail", "smtp", map[string]interface{}{
+		"host": "smtp.example.com",
+		"from": "backup@example.com",
+		"to":   []string{"ops@example.com", "dev@example.com"},
+	}).Viper}}
+
+	message := Message{Title: "Backup db failed", Text: "Error: boom\nStage: storage", Time: time.Unix(0, 0).UTC()}
+	assert.NoError(t, s.Notify(message))
+	assert.Equal(t, "smtp.example.com:587", addr)
+	assert.False(t, useTLS)
+	assert.Equal(t, "backup@example.com", from)
+	assert.Equal(t, []string{"ops@example.com", "dev@example.com"}, to)
+	assert.Contains(t, msg, "To: ops@example.com, dev@example.com\r\n")
+	assert.Contains(t, msg, "Subject: Backup db failed\r\n")
+	assert.True(t, strings.HasSuffix(msg, "\r\n\r\nError: boom\r\nStage: storage\r\n"))
+
+	s.viper.Set("port", 465)
+	assert.NoError(t, s.Notify(message))
+	assert.Equal(t, "smtp.example.com:465", addr)
+	assert.True(t, useTLS)
+
+	s.viper.Set("to", nil)
+	assert.EqualError(t, s.Notify(message), "host, from and to are required")
+}
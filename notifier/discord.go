@@ -0,0 +1,34 @@
+package notifier
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Discord webhook
+//
+//	type: discord
+//	url: https://discord.com/api/webhooks/000/XXXX
+type Discord struct {
+	Base
+}
+
+func (s *Discord) Notify(message Message) error {
+	// The embed color is a decimal RGB
+	rgb, _ := strconv.ParseInt(strings.TrimPrefix(color(message), "#"), 16, 64)
+
+	payload := map[string]interface{}{
+		"embeds": []map[string]interface{}{
+			{
+				"title":       truncate(message.Title, 256),
+				"description": truncate(message.Text, 4096),
+				"color":       rgb,
+				"footer":      map[string]string{"text": message.Hostname},
+				"timestamp":   message.Time.Format(time.RFC3339),
+			},
+		},
+	}
+
+	return s.postJSON(s.viper.GetString("url"), payload)
+}
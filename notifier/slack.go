@@ -0,0 +1,40 @@
+package notifier
+
+import "fmt"
+
+const (
+	colorSuccess = "#2EB67D"
+	colorFailure = "#E01E5A"
+)
+
+// Slack incoming webhook
+//
+//	type: slack
+//	url: https://hooks.slack.com/services/T000/B000/XXXX
+type Slack struct {
+	Base
+}
+
+func (s *Slack) Notify(message Message) error {
+	payload := map[string]interface{}{
+		"text": fmt.Sprintf("*%s*", message.Title),
+		"attachments": []map[string]interface{}{
+			{
+				"color":  color(message),
+				"text":   message.Text,
+				"footer": message.Hostname,
+				"ts":     message.Time.Unix(),
+			},
+		},
+	}
+
+	return s.postJSON(s.viper.GetString("url"), payload)
+}
+
+// color of the message in the chats
+func color(message Message) string {
+	if message.Success {
+		return colorSuccess
+	}
+	return colorFailure
+}
@@ -0,0 +1,113 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+var (
+	// sendMail with STARTTLS when the server supports it, or over TLS when `tlsConfig` is set
+	sendMail = func(addr string, tlsConfig *tls.Config, auth smtp.Auth, from string, to []string, msg []byte) error {
+		if tlsConfig == nil {
+			return smtp.SendMail(addr, auth, from, to, msg)
+		}
+
+		conn, err := tls.Dial("tcp", addr, tlsConfig)
+		if err != nil {
+			return err
+		}
+		client, err := smtp.NewClient(conn, tlsConfig.ServerName)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		defer client.Close()
+
+		if auth != nil {
+			if err := client.Auth(auth); err != nil {
+				return err
+			}
+		}
+		if err := client.Mail(from); err != nil {
+			return err
+		}
+		for _, addr := range to {
+			if err := client.Rcpt(addr); err != nil {
+				return err
+			}
+		}
+		w, err := client.Data()
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(msg); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		return client.Quit()
+	}
+)
+
+// SMTP email
+//
+//	type: smtp
+//	host: smtp.example.com
+//	port: 587
+//	username: backup@example.com
+//	password: secret
+//	from: backup@example.com
+//	to: [ops@example.com]
+//
+// The port 465 uses TLS, the other ports upgrade with STARTTLS when the server supports it.
+type SMTP struct {
+	Base
+}
+
+func (s *SMTP) Notify(message Message) error {
+	s.viper.SetDefault("port", 587)
+
+	host := s.viper.GetString("host")
+	from := s.viper.GetString("from")
+	to := s.viper.GetStringSlice("to")
+	if len(host) == 0 || len(from) == 0 || len(to) == 0 {
+		return fmt.Errorf("host, from and to are required")
+	}
+
+	var auth smtp.Auth
+	if username := s.viper.GetString("username"); len(username) > 0 {
+		auth = smtp.PlainAuth("", username, s.viper.GetString("password"), host)
+	}
+
+	var tlsConfig *tls.Config
+	port := s.viper.GetInt("port")
+	if port == 465 {
+		tlsConfig = &tls.Config{ServerName: host}
+	}
+
+	addr := net.JoinHostPort(host, fmt.Sprintf("%d", port))
+	return sendMail(addr, tlsConfig, auth, from, to, buildMail(from, to, message))
+}
+
+// buildMail the plain text email of the message
+func buildMail(from string, to []string, message Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Title))
+	fmt.Fprintf(&buf, "Date: %s\r\n", message.Time.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(message.Text, "\n", "\r\n"))
+	buf.WriteString("\r\n")
+
+	return buf.Bytes()
+}
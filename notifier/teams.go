@@ -0,0 +1,27 @@
+package notifier
+
+import (
+	"strings"
+)
+
+// Teams Microsoft Teams incoming webhook
+//
+//	type: teams
+//	url: https://example.webhook.office.com/webhookb2/XXXX
+type Teams struct {
+	Base
+}
+
+func (s *Teams) Notify(message Message) error {
+	payload := map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "http://schema.org/extensions",
+		"themeColor": strings.TrimPrefix(color(message), "#"),
+		"summary":    message.Title,
+		"title":      message.Title,
+		// Teams renders the text as markdown, the line breaks need two spaces
+		"text": strings.ReplaceAll(message.Text, "\n", "  \n"),
+	}
+
+	return s.postJSON(s.viper.GetString("url"), payload)
+}
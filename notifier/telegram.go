@@ -0,0 +1,32 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Telegram bot message
+//
+//	type: telegram
+//	token: 123456:ABC-DEF
+//	chat_id: -1001234567890
+type Telegram struct {
+	Base
+}
+
+func (s *Telegram) Notify(message Message) error {
+	s.viper.SetDefault("endpoint", "https://api.telegram.org")
+
+	token := s.viper.GetString("token")
+	if len(token) == 0 {
+		return fmt.Errorf("token is required")
+	}
+
+	url := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimSuffix(s.viper.GetString("endpoint"), "/"), token)
+	payload := map[string]interface{}{
+		"chat_id": s.viper.GetString("chat_id"),
+		"text":    truncate(message.Title+"\n\n"+message.Text, 4096),
+	}
+
+	return s.postJSON(url, payload)
+}
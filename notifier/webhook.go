@@ -1,104 +1,75 @@
 package notifier
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
-	"io"
 	"net/http"
-	"strings"
-
-	"github.com/gigcodes/launch-util/config"
-
-	"github.com/gigcodes/launch-util/logger"
+	"text/template"
 )
 
+// Webhook send the message to any HTTP endpoint
+//
+//	type: webhook
+//	url: http://localhost:3000/api/backup-notify.json
+//	method: POST
+//	headers:
+//	  Authorization: 'Bearer this-is-token'
+//	content_type: application/json
+//	body: '{"text": {{ json .Title }}}'
+//
+// The `body` is a Go template of the Message, `json` quotes a value as JSON.
+// Without `body` the payload of the event is posted as JSON.
 type Webhook struct {
-	Service string
-
-	method      string
-	contentType string
-	payload     interface{} // Now accepts arbitrary JSON payload
-	webhookURL  string      // The URL from config
-	headers     map[string]string
-}
-
-func NewWebhook(webhook config.WebhookConfig) *Webhook {
-
-	return &Webhook{
-		Service:     "Webhook",
-		method:      webhook.Method,
-		contentType: "application/json",
-		payload:     nil, // Initially no payload
-		webhookURL:  webhook.Url,
-		headers:     webhook.Headers,
-	}
-}
-
-// Get the logger for this service
-func (s *Webhook) getLogger() logger.Logger {
-	return logger.Tag(fmt.Sprintf("Notifier: %s", s.Service))
+	Base
 }
 
-// Build the payload, now accepts any JSON payload
-func (s *Webhook) buildBody(payload interface{}) ([]byte, error) {
-	// Marshal the payload into JSON format
-	return json.Marshal(payload)
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
 }
 
-// Notify sends a notification using the webhook URL with arbitrary payload
-func (s *Webhook) Notify(payload interface{}) error {
-	loggerT := s.getLogger()
+func (s *Webhook) Notify(message Message) error {
+	s.viper.SetDefault("method", http.MethodPost)
+	s.viper.SetDefault("content_type", "application/json")
 
-	// Use the webhook URL from config
-	url := s.webhookURL
-
-	// Build the body with the provided payload
-	body, err := s.buildBody(payload)
+	body, err := s.buildBody(message)
 	if err != nil {
 		return err
 	}
 
-	loggerT.Infof("Sending notification to %s...", url)
-	req, err := http.NewRequest(s.method, url, strings.NewReader(string(body)))
-	if err != nil {
-		loggerT.Error(err)
-		return nil
-	}
-
-	// Set the Content-Type for the request
-	req.Header.Set("Content-Type", s.contentType)
+	return send(s.viper.GetString("method"), s.viper.GetString("url"), s.viper.GetString("content_type"),
+		s.viper.GetStringMapString("headers"), body)
+}
 
-	// Add custom headers from config
-	if s.headers != nil {
-		for key, value := range s.headers {
-			req.Header.Set(key, value)
+// buildBody render the `body` template, or marshal the payload
+func (s *Webhook) buildBody(message Message) ([]byte, error) {
+	text := s.viper.GetString("body")
+	if len(text) == 0 {
+		payload := message.Payload
+		if payload == nil {
+			payload = map[string]interface{}{
+				"event":  message.Event,
+				"model":  message.Model,
+				"status": message.Status,
+				"title":  message.Title,
+				"text":   message.Text,
+				"error":  message.Error,
+			}
 		}
+		return json.Marshal(payload)
 	}
 
-	// Create an HTTP client and send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	tmpl, err := template.New(s.name).Funcs(templateFuncs).Parse(text)
 	if err != nil {
-		loggerT.Error(err)
-		return nil
-	}
-	defer resp.Body.Close()
-
-	// Read response body
-	var responseBody []byte
-	if resp.Body != nil {
-		responseBody, err = io.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
+		return nil, err
 	}
 
-	// Check the result of the request
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		loggerT.Errorf("Notification failed. Status: %d, Response: %s", resp.StatusCode, string(responseBody))
-		return fmt.Errorf("status: %d, body: %s", resp.StatusCode, string(responseBody))
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, message); err != nil {
+		return nil, err
 	}
 
-	loggerT.Info("Notification sent successfully.")
-	return nil
+	return buf.Bytes(), nil
 }
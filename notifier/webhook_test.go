@@ -0,0 +1,90 @@
+package notifier
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
+)
+
+func newConfig(name, kind string, values map[string]interface{}) config.SubConfig {
+	v := viper.New()
+	v.Set("type", kind)
+	for key, value := range values {
+		v.Set(key, value)
+	}
+	return config.SubConfig{Name: name, Type: kind, Viper: v}
+}
+
+func TestWebhook_Notify_retry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	retry := helper.RetryConfig{Attempts: 3, InitialDelay: time.Millisecond, Errors: []string{helper.RetryServer}}
+	notifiers := map[string]config.SubConfig{
+		"webhook": newConfig("webhook", "webhook", map[string]interface{}{"url": server.URL}),
+	}
+	message := Message{Success: true, Payload: map[string]string{"status": "finished"}}
+	assert.NoError(t, Send(notifiers, retry, message))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// The client errors are not retried
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+
+	notifiers["webhook"] = newConfig("webhook", "webhook", map[string]interface{}{"url": notFound.URL})
+	assert.Error(t, Send(notifiers, retry, message))
+}
+
+func TestWebhook_Notify(t *testing.T) {
+	var method, contentType, authorization, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, contentType, authorization, body = r.Method, r.Header.Get("Content-Type"), r.Header.Get("Authorization"), string(data)
+	}))
+	defer server.Close()
+
+	message := Message{Event: EventBackup, Model: "db", Status: "failed", Title: `Backup "db" failed`, Payload: map[string]string{"status": "failed"}}
+
+	// The payload is posted without `body`
+	webhook := &Webhook{Base: Base{viper: newConfig("webhook", "webhook", map[string]interface{}{
+		"url":     server.URL,
+		"headers": map[string]string{"Authorization": "Bearer token"},
+	}).Viper}}
+	assert.NoError(t, webhook.Notify(message))
+	assert.Equal(t, http.MethodPost, method)
+	assert.Equal(t, "application/json", contentType)
+	assert.Equal(t, "Bearer token", authorization)
+	assert.Equal(t, `{"status":"failed"}`, body)
+
+	webhook = &Webhook{Base: Base{viper: newConfig("webhook", "webhook", map[string]interface{}{
+		"url":          server.URL,
+		"method":       http.MethodPut,
+		"content_type": "text/plain",
+		"body":         `{"text": {{ json .Title }}, "model": "{{ .Model }}"}`,
+	}).Viper}}
+	assert.NoError(t, webhook.Notify(message))
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "text/plain", contentType)
+	assert.Equal(t, `{"text": "Backup \"db\" failed", "model": "db"}`, body)
+
+	webhook = &Webhook{Base: Base{viper: newConfig("webhook", "webhook", map[string]interface{}{
+		"url":  server.URL,
+		"body": `{{ .Missing }}`,
+	}).Viper}}
+	assert.Error(t, webhook.Notify(message))
+}
@@ -0,0 +1,147 @@
+package psutil
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/notifier"
+)
+
+const (
+	EventAlert    = "pulse_alert"
+	EventResolved = "pulse_resolved"
+)
+
+// Alert of a rule, the data of the events "pulse_alert" and "pulse_resolved"
+type Alert struct {
+	Rule      string  `json:"rule"`
+	Metric    string  `json:"metric"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	// Since the rule matches
+	Since time.Time `json:"since"`
+	Time  time.Time `json:"time"`
+}
+
+// alertState of a rule since the previous evaluations
+type alertState struct {
+	// pendingSince the rule matches, zero when it does not match
+	pendingSince time.Time
+	firing       bool
+}
+
+var (
+	alertLock = sync.Mutex{}
+	// alertStates by rule
+	alertStates = map[string]*alertState{}
+
+	now = time.Now
+
+	sendAlerts = func(event string, alerts []Alert) error {
+		message := notifier.Message{
+			Event:   notifier.EventPulse,
+			Status:  "alert",
+			Success: false,
+			Title:   "Pulse alert",
+			Payload: map[string]interface{}{
+				"event": event,
+				"data":  alerts,
+			},
+		}
+		if event == EventResolved {
+			message.Status = "resolved"
+			message.Success = true
+			message.Title = "Pulse resolved"
+		}
+
+		var text []string
+		for _, alert := range alerts {
+			text = append(text, fmt.Sprintf("%s: %s", alert.Rule, formatValue(alert.Metric, alert.Value)))
+		}
+		message.Text = strings.Join(text, "\n")
+
+		return notifier.Send(config.Notifiers, config.DefaultRetry, message)
+	}
+)
+
+// Evaluate the alert rules of `pulse` with the system stats. The rule matching for its `for` duration is
+// sent to the notifiers with event "pulse_alert", then with "pulse_resolved" once the value is back beyond
+// the threshold by `hysteresis` percent, the values in between keep the alert firing.
+func Evaluate(data *Psutil) error {
+	alertLock.Lock()
+	defer alertLock.Unlock()
+
+	t := now()
+	var fired, resolved []Alert
+	rules := map[string]bool{}
+	for _, rule := range config.Pulse.Alerts {
+		rules[rule.Rule] = true
+		value, ok := data.Value(rule.Metric)
+		if !ok {
+			continue
+		}
+
+		state, ok := alertStates[rule.Rule]
+		if !ok {
+			state = &alertState{}
+			alertStates[rule.Rule] = state
+		}
+
+		alert := Alert{Rule: rule.Rule, Metric: rule.Metric, Value: value, Threshold: rule.Threshold, Since: state.pendingSince, Time: t}
+		switch {
+		case rule.Firing(value):
+			if state.pendingSince.IsZero() {
+				state.pendingSince = t
+				alert.Since = t
+			}
+			if !state.firing && t.Sub(state.pendingSince) >= rule.For {
+				state.firing = true
+				fired = append(fired, alert)
+			}
+		case state.firing:
+			if rule.Resolved(value, config.Pulse.Hysteresis) {
+				state.firing = false
+				state.pendingSince = time.Time{}
+				resolved = append(resolved, alert)
+			}
+		default:
+			state.pendingSince = time.Time{}
+		}
+	}
+
+	// The rules removed from the config
+	for rule := range alertStates {
+		if !rules[rule] {
+			delete(alertStates, rule)
+		}
+	}
+
+	var errs []error
+	if len(fired) > 0 {
+		if err := sendAlerts(EventAlert, fired); err != nil {
+			errs = append(errs, fmt.Errorf("error sending %s: %w", EventAlert, err))
+		}
+	}
+	if len(resolved) > 0 {
+		if err := sendAlerts(EventResolved, resolved); err != nil {
+			errs = append(errs, fmt.Errorf("error sending %s: %w", EventResolved, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// formatValue of the metric, the bytes are humanized
+func formatValue(metric string, value float64) string {
+	switch {
+	case strings.HasSuffix(metric, "_bytes"):
+		return humanizeBytes(fmt.Sprintf("%.0f", value))
+	case strings.HasSuffix(metric, "_percent"):
+		return fmt.Sprintf("%.1f%%", value)
+	}
+	return fmt.Sprintf("%.2f", value)
+}
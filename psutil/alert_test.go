@@ -0,0 +1,91 @@
+package psutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+
+	"github.com/gigcodes/launch-util/config"
+)
+
+func stubAlerts(t *testing.T, rules ...string) (*time.Time, *[][]Alert, *[]string) {
+	clock := time.Unix(1700000000, 0)
+	var sent [][]Alert
+	var events []string
+
+	originalPulse, originalNow, originalSend := config.Pulse, now, sendAlerts
+	t.Cleanup(func() {
+		config.Pulse, now, sendAlerts = originalPulse, originalNow, originalSend
+		alertStates = map[string]*alertState{}
+	})
+
+	config.Pulse = config.PulseConfig{Hysteresis: 5}
+	for _, rule := range rules {
+		alert, err := config.ParseAlertRule(rule)
+		assert.NoError(t, err)
+		config.Pulse.Alerts = append(config.Pulse.Alerts, alert)
+	}
+	now = func() time.Time { return clock }
+	sendAlerts = func(event string, alerts []Alert) error {
+		events = append(events, event)
+		sent = append(sent, alerts)
+		return nil
+	}
+
+	return &clock, &sent, &events
+}
+
+func TestEvaluate(t *testing.T) {
+	clock, sent, events := stubAlerts(t, "disk_used_percent > 90 for 10m", "load > 8")
+	evaluate := func(disk, load float64) {
+		*clock = clock.Add(5 * time.Minute)
+		assert.NoError(t, Evaluate(&Psutil{DiskUsedPercent: disk, LoadAverage: load, DiskFree: "0"}))
+	}
+
+	// The load fires at once, the disk after 10 minutes
+	evaluate(91, 9)
+	assert.Equal(t, []string{EventAlert}, *events)
+	assert.Equal(t, "load > 8", (*sent)[0][0].Rule)
+
+	evaluate(92, 9)
+	assert.Len(t, *events, 1)
+	evaluate(93, 9)
+	assert.Equal(t, []string{EventAlert, EventAlert}, *events)
+	alert := (*sent)[1][0]
+	assert.Equal(t, "disk_used_percent > 90 for 10m", alert.Rule)
+	assert.Equal(t, float64(93), alert.Value)
+	assert.Equal(t, time.Unix(1700000300, 0), alert.Since)
+
+	// Below the threshold but not below 85.5, still firing
+	evaluate(88, 9)
+	assert.Len(t, *events, 2)
+
+	evaluate(85, 7)
+	assert.Equal(t, []string{EventAlert, EventAlert, EventResolved}, *events)
+	assert.Len(t, (*sent)[2], 2)
+
+	// The pending disk alert is reset under the threshold
+	evaluate(91, 0)
+	evaluate(89, 0)
+	evaluate(91, 0)
+	evaluate(91, 0)
+	assert.Len(t, *events, 3)
+}
+
+func TestPsutil_Value(t *testing.T) {
+	data := &Psutil{Load: 12.5, LoadAverage: 1.5, DiskFree: "1024", DiskUsedPercent: 60, MemoryUsedPercent: 75}
+	for metric, expected := range map[string]float64{
+		"load": 1.5, "cpu_percent": 12.5, "disk_free_bytes": 1024, "disk_used_percent": 60, "memory_used_percent": 75,
+	} {
+		value, ok := data.Value(metric)
+		assert.True(t, ok)
+		assert.Equal(t, expected, value)
+	}
+
+	// All the metrics of the rules are supported
+	for _, metric := range config.AlertMetrics {
+		_, ok := data.Value(metric)
+		assert.True(t, ok)
+	}
+}
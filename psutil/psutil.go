@@ -2,10 +2,12 @@ package psutil
 
 import (
 	"fmt"
+	"github.com/dustin/go-humanize"
 	"github.com/gigcodes/launch-util/config"
 	"github.com/gigcodes/launch-util/notifier"
 	"github.com/shirou/gopsutil/v4/cpu"
 	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/load"
 	"github.com/shirou/gopsutil/v4/mem"
 	"log"
 	"strconv"
@@ -13,23 +15,35 @@ import (
 )
 
 type Psutil struct {
-	Load        float64
-	DiskTotal   string
-	DiskFree    string
-	DiskUsed    string
-	MemoryTotal string
-	MemoryFree  string
-	MemoryUsed  string
+	// Load is the CPU usage percent
+	Load float64
+	// LoadAverage of 1 minute
+	LoadAverage       float64
+	DiskTotal         string
+	DiskFree          string
+	DiskUsed          string
+	DiskUsedPercent   float64
+	MemoryTotal       string
+	MemoryFree        string
+	MemoryUsed        string
+	MemoryUsedPercent float64
 }
 
 func Fetch() (*Psutil, error) {
-	// Fetch system load average
+	// Fetch CPU usage percent
 	loads, err := cpu.Percent(time.Second, false)
 	if err != nil {
 		log.Println("Error fetching CPU load average:", err)
 		return nil, err
 	}
 
+	// Fetch system load average
+	avg, err := load.Avg()
+	if err != nil {
+		log.Println("Error fetching load average:", err)
+		return nil, err
+	}
+
 	// Fetch memory usage
 	vmStat, err := mem.VirtualMemory()
 	if err != nil {
@@ -53,27 +67,73 @@ func Fetch() (*Psutil, error) {
 
 	// Construct the Psutil struct with the fetched data
 	psutil := &Psutil{
-		Load:        loads[0],
-		DiskTotal:   strconv.FormatUint(usageStat.Total, 10),
-		DiskFree:    strconv.FormatUint(usageStat.Free, 10),
-		DiskUsed:    strconv.FormatUint(usageStat.Used, 10),
-		MemoryTotal: strconv.FormatUint(vmStat.Total, 10),
-		MemoryFree:  strconv.FormatUint(vmStat.Free, 10),
-		MemoryUsed:  strconv.FormatUint(vmStat.Used, 10),
+		Load:              loads[0],
+		LoadAverage:       avg.Load1,
+		DiskTotal:         strconv.FormatUint(usageStat.Total, 10),
+		DiskFree:          strconv.FormatUint(usageStat.Free, 10),
+		DiskUsed:          strconv.FormatUint(usageStat.Used, 10),
+		DiskUsedPercent:   usageStat.UsedPercent,
+		MemoryTotal:       strconv.FormatUint(vmStat.Total, 10),
+		MemoryFree:        strconv.FormatUint(vmStat.Free, 10),
+		MemoryUsed:        strconv.FormatUint(vmStat.Used, 10),
+		MemoryUsedPercent: vmStat.UsedPercent,
 	}
 
 	return psutil, nil
 }
 
+// Value of the metric of the alert rules, see config.AlertMetrics
+func (p *Psutil) Value(metric string) (float64, bool) {
+	switch metric {
+	case "load":
+		return p.LoadAverage, true
+	case "cpu_percent":
+		return p.Load, true
+	case "disk_used_percent":
+		return p.DiskUsedPercent, true
+	case "disk_free_bytes":
+		free, err := strconv.ParseFloat(p.DiskFree, 64)
+		return free, err == nil
+	case "memory_used_percent":
+		return p.MemoryUsedPercent, true
+	}
+	return 0, false
+}
+
+// DiskFree of the filesystem of the path in bytes
+var DiskFree = func(path string) (uint64, error) {
+	usageStat, err := disk.Usage(path)
+	if err != nil {
+		return 0, err
+	}
+	return usageStat.Free, nil
+}
+
+// Pulse send the system stats to the notifiers, the payload of the webhook without `body` is {"event": "pulse", "data"}
 func Pulse(data *Psutil) {
-	webhook := notifier.NewWebhook(config.Pulse.Webhook)
+	message := notifier.Message{
+		Event:   notifier.EventPulse,
+		Status:  "ok",
+		Success: true,
+		Title:   "Pulse",
+		Text: fmt.Sprintf("Load: %.2f, CPU: %.2f%%\nDisk used: %s of %s\nMemory used: %s of %s", data.LoadAverage, data.Load,
+			humanizeBytes(data.DiskUsed), humanizeBytes(data.DiskTotal), humanizeBytes(data.MemoryUsed), humanizeBytes(data.MemoryTotal)),
+		Payload: map[string]interface{}{
+			"event": "pulse",
+			"data":  data,
+		},
+	}
 
-	payload := map[string]interface{}{
-		"event": "pulse",
-		"data":  data,
+	if err := notifier.Send(config.Notifiers, config.DefaultRetry, message); err != nil {
+		fmt.Println("Error sending pulse notification:", err)
 	}
-	err := webhook.Notify(payload)
+}
+
+// humanizeBytes format the bytes in the string fields
+func humanizeBytes(value string) string {
+	n, err := strconv.ParseUint(value, 10, 64)
 	if err != nil {
-		fmt.Println("Error sending pulse notification:", err)
+		return value
 	}
+	return humanize.Bytes(n)
 }
@@ -0,0 +1,284 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kolo/xmlrpc"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/logger"
+	"github.com/gigcodes/launch-util/notifier"
+)
+
+const (
+	ActionStart   = "start"
+	ActionStop    = "stop"
+	ActionRestart = "restart"
+	ActionSignal  = "signal"
+
+	// Supervisor fault codes
+	faultBadName        = 10
+	faultAlreadyStarted = 60
+	faultNotRunning     = 70
+	faultSuccess        = 80
+
+	resultOK     = "ok"
+	resultFailed = "failed"
+)
+
+var (
+	faultRegexp = regexp.MustCompile(`(?s)^Fault\((-?\d+)\): (.*)$`)
+)
+
+// ActionResult of an action on a supervisor process
+type ActionResult struct {
+	Instance string `json:"instance,omitempty"`
+	Action   string `json:"action"`
+	// Name of the process, `group:name` for the processes in a group
+	Name   string `json:"name"`
+	Signal string `json:"signal,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	fault int
+}
+
+// processResult of the group actions `supervisor.startProcessGroup`...
+type processResult struct {
+	Name        string `xmlrpc:"name"`
+	Group       string `xmlrpc:"group"`
+	Status      int    `xmlrpc:"status"`
+	Description string `xmlrpc:"description"`
+}
+
+// Control run the action on the targets like supervisorctl, a target is a process `name`, `group:name`,
+// all processes of a group `group:*`, or `all`, on the supervisor instance. The results are sent to the notifiers with event "d_action",
+// an error is returned when any action failed.
+func Control(action string, targets []string, signal string, instance config.SupervisorInstance) ([]ActionResult, error) {
+	switch action {
+	case ActionStart, ActionStop, ActionRestart:
+	case ActionSignal:
+		if len(signal) == 0 {
+			return nil, errors.New("signal is required")
+		}
+	default:
+		return nil, fmt.Errorf("action %s is not supported", action)
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("process name is required")
+	}
+
+	client, err := newXMLRPCClient(instance)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	var results []ActionResult
+	for _, target := range targets {
+		if action == ActionRestart {
+			results = append(results, restart(client, target)...)
+		} else {
+			results = append(results, control(client, action, target, signal)...)
+		}
+	}
+
+	failed := 0
+	for i := range results {
+		results[i].Instance = instance.Name
+		results[i].Action = action
+		results[i].Signal = signal
+		if results[i].Status != resultOK {
+			failed++
+		}
+	}
+
+	if err := sendActionWebhook(results); err != nil {
+		logger.Errorf("Error sending webhook: %v", err)
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d processes failed to %s", failed, len(results), action)
+	}
+	return results, nil
+}
+
+// restart stop and start the target, the processes not running are started
+func restart(client *xmlrpc.Client, target string) []ActionResult {
+	var failures []ActionResult
+	for _, result := range control(client, ActionStop, target, "") {
+		if result.Status != resultOK && result.fault != faultNotRunning {
+			failures = append(failures, result)
+		}
+	}
+	if len(failures) > 0 {
+		return failures
+	}
+
+	return control(client, ActionStart, target, "")
+}
+
+// control call `supervisor.startProcess`, `supervisor.startProcessGroup`, `supervisor.startAllProcesses`
+// and the ones of stop and signal by the target.
+func control(client *xmlrpc.Client, action, target, signal string) []ActionResult {
+	method := "supervisor." + action
+	// Wait for the processes to be started or stopped
+	var arg interface{} = true
+	if action == ActionSignal {
+		arg = signal
+	}
+
+	var args []interface{}
+	switch {
+	case target == "all":
+		method += "AllProcesses"
+		args = []interface{}{arg}
+	case strings.HasSuffix(target, ":*"):
+		method += "ProcessGroup"
+		args = []interface{}{strings.TrimSuffix(target, ":*"), arg}
+	default:
+		var ok bool
+		err := client.Call(method+"Process", []interface{}{target, arg}, &ok)
+		if err != nil {
+			return []ActionResult{failure(target, err)}
+		}
+		return []ActionResult{{Name: target, Status: resultOK}}
+	}
+
+	var replies []processResult
+	if err := client.Call(method, args, &replies); err != nil {
+		return []ActionResult{failure(target, err)}
+	}
+
+	results := make([]ActionResult, 0, len(replies))
+	for _, reply := range replies {
+		result := ActionResult{Name: reply.Group + ":" + reply.Name, Status: resultOK}
+		if reply.Status != faultSuccess {
+			result.Status = resultFailed
+			result.Error = fmt.Sprintf("%s: %s", faultName(reply.Status), reply.Description)
+			result.fault = reply.Status
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func failure(name string, err error) ActionResult {
+	result := ActionResult{Name: name, Status: resultFailed, Error: faultString(err)}
+	if fault, ok := parseFault(err); ok {
+		result.fault = fault.Code
+	}
+	return result
+}
+
+// parseFault of the error, net/rpc flattens the xmlrpc.FaultError into `Fault(10): BAD_NAME: worker`
+func parseFault(err error) (xmlrpc.FaultError, bool) {
+	var fault xmlrpc.FaultError
+	if errors.As(err, &fault) {
+		return fault, true
+	}
+
+	m := faultRegexp.FindStringSubmatch(err.Error())
+	if m == nil {
+		return fault, false
+	}
+	fault.Code, _ = strconv.Atoi(m[1])
+	fault.String = m[2]
+	return fault, true
+}
+
+// faultString of the supervisor fault, `BAD_NAME: worker`
+func faultString(err error) string {
+	fault, ok := parseFault(err)
+	if !ok {
+		return err.Error()
+	}
+
+	// The fault string already starts with the name
+	if strings.HasPrefix(fault.String, faultName(fault.Code)) {
+		return fault.String
+	}
+	return fmt.Sprintf("%s: %s", faultName(fault.Code), fault.String)
+}
+
+func faultName(code int) string {
+	switch code {
+	case faultBadName:
+		return "BAD_NAME"
+	case faultAlreadyStarted:
+		return "ALREADY_STARTED"
+	case faultNotRunning:
+		return "NOT_RUNNING"
+	case faultSuccess:
+		return "SUCCESS"
+	default:
+		return fmt.Sprintf("FAULT_%d", code)
+	}
+}
+
+func sendActionWebhook(results []ActionResult) error {
+	message := notifier.Message{
+		Event:   notifier.EventSupervisor,
+		Status:  resultOK,
+		Success: true,
+		Payload: map[string]interface{}{
+			"event": "d_action",
+			"data":  results,
+		},
+	}
+
+	var text []string
+	for _, result := range results {
+		if len(message.Title) == 0 {
+			message.Title = fmt.Sprintf("Supervisor %s", result.Action)
+		}
+		line := fmt.Sprintf("%s/%s: %s", result.Instance, result.Name, result.Status)
+		if result.Status != resultOK {
+			message.Status = resultFailed
+			message.Success = false
+			line += " (" + result.Error + ")"
+		}
+		text = append(text, line)
+	}
+	message.Text = strings.Join(text, "\n")
+
+	return notifier.Send(config.Notifiers, config.DefaultRetry, message)
+}
+
+// Tail read the last `length` bytes of the stdout log of the process, or the stderr log with `stderr`,
+// from `offset` on the supervisor instance. The offset to read from next is returned, the log is truncated to `length` bytes
+// when it has more bytes since `offset`.
+func Tail(name string, stderr bool, offset, length int64, instance config.SupervisorInstance) (string, int64, error) {
+	client, err := newXMLRPCClient(instance)
+	if err != nil {
+		return "", 0, err
+	}
+	defer client.Close()
+
+	method := "supervisor.tailProcessStdoutLog"
+	if stderr {
+		method = "supervisor.tailProcessStderrLog"
+	}
+
+	// [bytes, offset, overflow]
+	var reply []interface{}
+	if err := client.Call(method, []interface{}{name, offset, length}, &reply); err != nil {
+		return "", 0, fmt.Errorf("error calling %s: %s", strings.TrimPrefix(method, "supervisor."), faultString(err))
+	}
+	if len(reply) < 2 {
+		return "", 0, fmt.Errorf("unexpected reply of %s: %v", method, reply)
+	}
+
+	data, _ := reply[0].(string)
+	next, ok := reply[1].(int64)
+	if !ok {
+		return "", 0, fmt.Errorf("unexpected offset of %s: %v", method, reply[1])
+	}
+
+	return data, next, nil
+}
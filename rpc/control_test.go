@@ -0,0 +1,208 @@
+package rpc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/longbridgeapp/assert"
+
+	"github.com/gigcodes/launch-util/config"
+)
+
+// fakeSupervisor is an in-process XML-RPC server on a unix socket, `handlers` return the XML of the
+// response params by the method name.
+type fakeSupervisor struct {
+	socketPath string
+	handlers   map[string]func(params []string) string
+
+	mu    sync.Mutex
+	calls []string
+}
+
+var valueRegexp = regexp.MustCompile(`<value>(?:<\w+>)?(.*?)(?:</\w+>)?</value>`)
+
+func newFakeSupervisor(t *testing.T, handlers map[string]func(params []string) string) *fakeSupervisor {
+	s := &fakeSupervisor{
+		socketPath: filepath.Join(t.TempDir(), "supervisor.sock"),
+		handlers:   handlers,
+	}
+
+	listener, err := net.Listen("unix", s.socketPath)
+	assert.NoError(t, err)
+	server := &http.Server{Handler: http.HandlerFunc(s.serve)}
+	go server.Serve(listener) //nolint:errcheck
+	t.Cleanup(func() { server.Close() })
+
+	return s
+}
+
+func (s *fakeSupervisor) serve(w http.ResponseWriter, r *http.Request) {
+	var call struct {
+		Method string `xml:"methodName"`
+		Params []struct {
+			Value string `xml:",innerxml"`
+		} `xml:"params>param"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&call); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var params []string
+	for _, p := range call.Params {
+		if m := valueRegexp.FindStringSubmatch(p.Value); m != nil {
+			params = append(params, m[1])
+		}
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, call.Method+"("+strings.Join(params, ",")+")")
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/xml")
+	handler, ok := s.handlers[call.Method]
+	if !ok {
+		fmt.Fprint(w, faultResponse(1, "UNKNOWN_METHOD"))
+		return
+	}
+	fmt.Fprint(w, handler(params))
+}
+
+func (s *fakeSupervisor) control(action string, targets []string, signal string) ([]ActionResult, error) {
+	return Control(action, targets, signal, s.instance())
+}
+
+func (s *fakeSupervisor) instance() config.SupervisorInstance {
+	return config.SupervisorInstance{Name: "default", Socket: s.socketPath}
+}
+
+func response(value string) string {
+	return `<?xml version="1.0"?><methodResponse><params><param><value>` + value + `</value></param></params></methodResponse>`
+}
+
+func faultResponse(code int, message string) string {
+	return fmt.Sprintf(`<?xml version="1.0"?><methodResponse><fault><value><struct>`+
+		`<member><name>faultCode</name><value><int>%d</int></value></member>`+
+		`<member><name>faultString</name><value><string>%s</string></value></member>`+
+		`</struct></value></fault></methodResponse>`, code, message)
+}
+
+func groupResponse(group string, statuses map[string]int) string {
+	var b strings.Builder
+	b.WriteString("<array><data>")
+	for _, name := range []string{"a", "b"} {
+		status, ok := statuses[name]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, `<value><struct>`+
+			`<member><name>name</name><value><string>%s</string></value></member>`+
+			`<member><name>group</name><value><string>%s</string></value></member>`+
+			`<member><name>status</name><value><int>%d</int></value></member>`+
+			`<member><name>description</name><value><string>%s</string></value></member>`+
+			`</struct></value>`, name, group, status, faultName(status))
+	}
+	b.WriteString("</data></array>")
+	return response(b.String())
+}
+
+func TestControl(t *testing.T) {
+	s := newFakeSupervisor(t, map[string]func(params []string) string{
+		"supervisor.startProcess": func(params []string) string {
+			if params[0] == "missing" {
+				return faultResponse(faultBadName, "BAD_NAME: missing")
+			}
+			return response("<boolean>1</boolean>")
+		},
+		"supervisor.stopProcessGroup": func(params []string) string {
+			return groupResponse(params[0], map[string]int{"a": faultSuccess, "b": faultNotRunning})
+		},
+		"supervisor.signalAllProcesses": func(params []string) string {
+			return groupResponse("worker", map[string]int{"a": faultSuccess, "b": faultSuccess})
+		},
+	})
+
+	results, err := s.control(ActionStart, []string{"web", "missing"}, "")
+	assert.EqualError(t, err, "1 of 2 processes failed to start")
+	assert.Equal(t, []ActionResult{
+		{Instance: "default", Action: ActionStart, Name: "web", Status: resultOK},
+		{Instance: "default", Action: ActionStart, Name: "missing", Status: resultFailed, Error: "BAD_NAME: missing", fault: faultBadName},
+	}, results)
+	assert.Equal(t, []string{"supervisor.startProcess(web,1)", "supervisor.startProcess(missing,1)"}, s.calls)
+
+	results, err = s.control(ActionStop, []string{"worker:*"}, "")
+	assert.Error(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "worker:a", results[0].Name)
+	assert.Equal(t, resultOK, results[0].Status)
+	assert.Equal(t, "worker:b", results[1].Name)
+	assert.Equal(t, "NOT_RUNNING: NOT_RUNNING", results[1].Error)
+
+	s.calls = nil
+	results, err = s.control(ActionSignal, []string{"all"}, "HUP")
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "HUP", results[0].Signal)
+	assert.Equal(t, []string{"supervisor.signalAllProcesses(HUP)"}, s.calls)
+
+	_, err = s.control(ActionSignal, []string{"all"}, "")
+	assert.EqualError(t, err, "signal is required")
+	_, err = s.control("reload", []string{"all"}, "")
+	assert.EqualError(t, err, "action reload is not supported")
+	_, err = s.control(ActionStart, nil, "")
+	assert.EqualError(t, err, "process name is required")
+}
+
+func TestControl_restart(t *testing.T) {
+	stopFault := faultNotRunning
+	s := newFakeSupervisor(t, map[string]func(params []string) string{
+		"supervisor.stopProcess": func(params []string) string {
+			return faultResponse(stopFault, "stop failed")
+		},
+		"supervisor.startProcess": func(params []string) string {
+			return response("<boolean>1</boolean>")
+		},
+	})
+
+	// The process not running is started
+	results, err := s.control(ActionRestart, []string{"web"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []ActionResult{{Instance: "default", Action: ActionRestart, Name: "web", Status: resultOK}}, results)
+	assert.Equal(t, []string{"supervisor.stopProcess(web,1)", "supervisor.startProcess(web,1)"}, s.calls)
+
+	// The process failed to stop is not started
+	s.calls = nil
+	stopFault = 50
+	results, err = s.control(ActionRestart, []string{"web"}, "")
+	assert.Error(t, err)
+	assert.Equal(t, "FAULT_50: stop failed", results[0].Error)
+	assert.Equal(t, []string{"supervisor.stopProcess(web,1)"}, s.calls)
+}
+
+func TestTail(t *testing.T) {
+	s := newFakeSupervisor(t, map[string]func(params []string) string{
+		"supervisor.tailProcessStdoutLog": func(params []string) string {
+			return response(`<array><data><value><string>hello
+</string></value><value><int>` + params[1] + `6</int></value><value><boolean>0</boolean></value></data></array>`)
+		},
+		"supervisor.tailProcessStderrLog": func(params []string) string {
+			return faultResponse(faultBadName, "BAD_NAME: web")
+		},
+	})
+
+	data, offset, err := Tail("web", false, 1, 1024, s.instance())
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", data)
+	assert.Equal(t, int64(16), offset)
+	assert.Equal(t, []string{"supervisor.tailProcessStdoutLog(web,1,1024)"}, s.calls)
+
+	_, _, err = Tail("web", true, 0, 1024, s.instance())
+	assert.EqualError(t, err, "error calling tailProcessStderrLog: BAD_NAME: web")
+}
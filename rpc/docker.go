@@ -0,0 +1,217 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Docker provider of the containers by the Docker Engine API
+//
+// type: docker
+// socket: /var/run/docker.sock
+// containers: redis, app (all the containers when empty)
+type Docker struct {
+	Base
+	containers []string
+	client     *http.Client
+}
+
+// dockerContainer of `GET /containers/{id}/json`
+type dockerContainer struct {
+	Name  string `json:"Name"`
+	State struct {
+		Status     string `json:"Status"`
+		ExitCode   int    `json:"ExitCode"`
+		Error      string `json:"Error"`
+		StartedAt  string `json:"StartedAt"`
+		FinishedAt string `json:"FinishedAt"`
+		Health     *struct {
+			Status string `json:"Status"`
+			Log    []struct {
+				ExitCode int    `json:"ExitCode"`
+				Output   string `json:"Output"`
+			} `json:"Log"`
+		} `json:"Health"`
+	} `json:"State"`
+}
+
+// errDockerNotFound of the response with status 404
+type errDockerNotFound struct {
+	message string
+}
+
+func (e errDockerNotFound) Error() string {
+	return e.message
+}
+
+func newDocker(base Base) *Docker {
+	base.viper.SetDefault("socket", "/var/run/docker.sock")
+	socketPath := base.viper.GetString("socket")
+
+	return &Docker{
+		Base:       base,
+		containers: base.viper.GetStringSlice("containers"),
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+func (d *Docker) ProcessInfo(id string) (*ProcessInfo, error) {
+	container, err := d.inspect(id)
+	if err != nil {
+		return nil, err
+	}
+
+	info := d.processInfo(container)
+	return &info, nil
+}
+
+// AllProcessInfo of the containers, the containers not found are UNKNOWN. All the containers when `containers` is empty.
+func (d *Docker) AllProcessInfo() ([]ProcessInfo, error) {
+	ids := d.containers
+	if len(ids) == 0 {
+		var containers []struct {
+			ID string `json:"Id"`
+		}
+		if err := d.get("/containers/json?all=1", &containers); err != nil {
+			return nil, fmt.Errorf("error listing containers: %w", err)
+		}
+		for _, container := range containers {
+			ids = append(ids, container.ID)
+		}
+	}
+
+	infos := make([]ProcessInfo, 0, len(ids))
+	for _, id := range ids {
+		container, err := d.inspect(id)
+		if _, ok := err.(errDockerNotFound); ok {
+			infos = append(infos, ProcessInfo{
+				Name:      id,
+				Group:     id,
+				State:     stateUnknown,
+				Statename: stateNames[stateUnknown],
+				Spawnerr:  "container not found",
+				Now:       now().Unix(),
+			})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, d.processInfo(container))
+	}
+	return infos, nil
+}
+
+func (d *Docker) inspect(id string) (*dockerContainer, error) {
+	var container dockerContainer
+	if err := d.get("/containers/"+url.PathEscape(id)+"/json", &container); err != nil {
+		if _, ok := err.(errDockerNotFound); ok {
+			return nil, errDockerNotFound{message: fmt.Sprintf("container %s not found", id)}
+		}
+		return nil, fmt.Errorf("error inspecting container %s: %w", id, err)
+	}
+	return &container, nil
+}
+
+// get the path of the Docker Engine API, the unversioned path is the latest version of the engine
+func (d *Docker) get(path string, v interface{}) error {
+	resp, err := d.client.Get("http://docker" + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		var reply struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(body, &reply)
+		if resp.StatusCode == http.StatusNotFound {
+			return errDockerNotFound{message: reply.Message}
+		}
+		return fmt.Errorf("status: %d, message: %s", resp.StatusCode, reply.Message)
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+// processInfo of the container, the states of Docker are mapped to the states of supervisor,
+// the running container with an unhealthy health check is UNHEALTHY.
+func (d *Docker) processInfo(container *dockerContainer) ProcessInfo {
+	name := strings.TrimPrefix(container.Name, "/")
+	state := container.State
+	info := ProcessInfo{
+		Name:        name,
+		Group:       name,
+		Description: state.Status,
+		Start:       parseDockerTime(state.StartedAt),
+		Stop:        parseDockerTime(state.FinishedAt),
+		Now:         now().Unix(),
+		Spawnerr:    state.Error,
+		ExitStatus:  state.ExitCode,
+	}
+
+	switch state.Status {
+	case "running":
+		info.State = stateRunning
+	case "restarting":
+		info.State = stateBackoff
+	case "created", "paused":
+		info.State = stateStopped
+	case "removing":
+		info.State = stateStopping
+	case "exited":
+		info.State = stateExited
+	case "dead":
+		info.State = stateFatal
+	default:
+		info.State = stateUnknown
+	}
+	info.Statename = stateNames[info.State]
+
+	if health := state.Health; health != nil && info.State == stateRunning {
+		info.Description += " (" + health.Status + ")"
+		switch health.Status {
+		case "starting":
+			info.State = stateStarting
+			info.Statename = stateNames[stateStarting]
+		case "unhealthy":
+			info.State = stateUnknown
+			info.Statename = "UNHEALTHY"
+			if len(health.Log) > 0 {
+				info.Spawnerr = strings.TrimSpace(health.Log[len(health.Log)-1].Output)
+			}
+		}
+	}
+
+	return info
+}
+
+// parseDockerTime to the unix time, the zero time `0001-01-01T00:00:00Z` is 0
+func parseDockerTime(value string) int64 {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil || t.Year() <= 1 {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,128 @@
+package rpc
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
+
+	"github.com/gigcodes/launch-util/config"
+)
+
+// newFakeDocker is an in-process Docker Engine API on a unix socket with the containers by name
+func newFakeDocker(t *testing.T, containers map[string]string) string {
+	socketPath := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	assert.NoError(t, err)
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/containers/json" {
+			var ids []string
+			for _, name := range []string{"app", "redis"} {
+				if _, ok := containers[name]; ok {
+					ids = append(ids, fmt.Sprintf(`{"Id":%q}`, name))
+				}
+			}
+			fmt.Fprint(w, "["+strings.Join(ids, ",")+"]")
+			return
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/containers/"), "/json")
+		state, ok := containers[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"message":"No such container: %s"}`, name)
+			return
+		}
+		fmt.Fprintf(w, `{"Name":"/%s","State":%s}`, name, state)
+	})}
+	go server.Serve(listener) //nolint:errcheck
+	t.Cleanup(func() { server.Close() })
+
+	originalNow := now
+	now = func() time.Time { return time.Unix(1700005000, 0) }
+	t.Cleanup(func() { now = originalNow })
+
+	return socketPath
+}
+
+func newTestDocker(t *testing.T, socketPath string, containers []string) config.SubConfig {
+	v := viper.New()
+	v.Set("type", ProviderDocker)
+	v.Set("socket", socketPath)
+	v.Set("containers", containers)
+	return config.SubConfig{Name: "containers", Type: ProviderDocker, Viper: v}
+}
+
+func TestDocker(t *testing.T) {
+	socketPath := newFakeDocker(t, map[string]string{
+		"app": `{"Status":"running","StartedAt":"2023-11-14T22:13:20.123Z","FinishedAt":"0001-01-01T00:00:00Z",` +
+			`"Health":{"Status":"unhealthy","Log":[{"ExitCode":1,"Output":"curl: (7) Failed to connect\n"}]}}`,
+		"redis": `{"Status":"exited","ExitCode":137,"StartedAt":"2023-11-14T22:13:20Z","FinishedAt":"2023-11-14T23:00:00Z"}`,
+	})
+
+	_, p, err := newProvider(newTestDocker(t, socketPath, nil))
+	assert.NoError(t, err)
+
+	infos, err := p.AllProcessInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, []ProcessInfo{
+		{
+			Name: "app", Group: "app", Description: "running (unhealthy)", Start: 1700000000, Now: 1700005000,
+			State: stateUnknown, Statename: "UNHEALTHY", Spawnerr: "curl: (7) Failed to connect",
+		},
+		{
+			Name: "redis", Group: "redis", Description: "exited", Start: 1700000000, Stop: 1700002800, Now: 1700005000,
+			State: stateExited, Statename: "EXITED", ExitStatus: 137,
+		},
+	}, infos)
+
+	_, err = p.ProcessInfo("web")
+	assert.EqualError(t, err, "container web not found")
+
+	// The configured containers not found are UNKNOWN
+	_, p, err = newProvider(newTestDocker(t, socketPath, []string{"redis", "web"}))
+	assert.NoError(t, err)
+	infos, err = p.AllProcessInfo()
+	assert.NoError(t, err)
+	assert.Len(t, infos, 2)
+	assert.Equal(t, "EXITED", infos[0].Statename)
+	assert.Equal(t, "container not found", infos[1].Spawnerr)
+}
+
+func TestSendDaemonStatus_providers(t *testing.T) {
+	socketPath := newFakeDocker(t, map[string]string{
+		"redis": `{"Status":"running","StartedAt":"2023-11-14T22:13:20Z"}`,
+	})
+
+	originalGet, originalSend := getSupervisorProcessInfo, sendStatusWebhook
+	defer func() { getSupervisorProcessInfo, sendStatusWebhook = originalGet, originalSend }()
+	getSupervisorProcessInfo = func(instance config.SupervisorInstance, processID string) (*ProcessInfo, error) {
+		return nil, fmt.Errorf("BAD_NAME: %s", processID)
+	}
+	var statuses []DaemonStatus
+	sendStatusWebhook = func(payload map[string]interface{}) error {
+		statuses = payload["data"].([]DaemonStatus)
+		return nil
+	}
+
+	managers := map[string]config.SubConfig{"containers": newTestDocker(t, socketPath, nil)}
+	err := SendDaemonStatus([]string{"redis"}, defaultInstances, managers)
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 1)
+	assert.Equal(t, "containers", statuses[0].Instance)
+	assert.Equal(t, ProviderDocker, statuses[0].Provider)
+	assert.Equal(t, "fully_running", statuses[0].Status)
+	assert.Equal(t, int64(5000), statuses[0].Uptime)
+
+	err = SendDaemonStatus([]string{"default/redis"}, defaultInstances, managers)
+	assert.EqualError(t, err, "failed to retrieve process info: default/redis: BAD_NAME: redis")
+	assert.Equal(t, ProviderSupervisor, statuses[0].Provider)
+}
@@ -0,0 +1,95 @@
+package rpc
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/viper"
+
+	"github.com/gigcodes/launch-util/config"
+)
+
+const (
+	ProviderSupervisor = "supervisor"
+	ProviderSystemd    = "systemd"
+	ProviderDocker     = "docker"
+)
+
+// Provider of the processes of a process manager, as the ProcessInfo of supervisor
+type Provider interface {
+	// ProcessInfo of the process by the id, the process of supervisor, the unit of systemd or the container of Docker
+	ProcessInfo(id string) (*ProcessInfo, error)
+	// AllProcessInfo of all the processes
+	AllProcessInfo() ([]ProcessInfo, error)
+}
+
+// Base provider
+type Base struct {
+	name  string
+	kind  string
+	viper *viper.Viper
+}
+
+// source of the daemon statuses, a supervisor instance or a process manager
+type source struct {
+	Base
+	Provider
+}
+
+// Supervisor provider of a supervisor instance over XML-RPC
+type Supervisor struct {
+	Base
+	instance config.SupervisorInstance
+}
+
+func (s *Supervisor) ProcessInfo(id string) (*ProcessInfo, error) {
+	return getSupervisorProcessInfo(s.instance, id)
+}
+
+func (s *Supervisor) AllProcessInfo() ([]ProcessInfo, error) {
+	return getAllSupervisorProcessInfo(s.instance)
+}
+
+func newProvider(managerConfig config.SubConfig) (Base, Provider, error) {
+	base := Base{
+		name:  managerConfig.Name,
+		kind:  managerConfig.Type,
+		viper: managerConfig.Viper,
+	}
+	if base.viper == nil {
+		base.viper = viper.New()
+	}
+
+	var p Provider
+	switch managerConfig.Type {
+	case ProviderSystemd:
+		p = newSystemd(base)
+	case ProviderDocker:
+		p = newDocker(base)
+	default:
+		return base, nil, fmt.Errorf("[%s] process manager type has not implement", managerConfig.Type)
+	}
+
+	return base, p, nil
+}
+
+// newSources of the supervisor instances and the process managers, sorted by name
+func newSources(instances []config.SupervisorInstance, managers map[string]config.SubConfig) ([]source, error) {
+	sources := make([]source, 0, len(instances)+len(managers))
+	for _, instance := range instances {
+		base := Base{name: instance.Name, kind: ProviderSupervisor, viper: viper.New()}
+		sources = append(sources, source{Base: base, Provider: &Supervisor{Base: base, instance: instance}})
+	}
+
+	for _, managerConfig := range managers {
+		base, p, err := newProvider(managerConfig)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source{Base: base, Provider: p})
+	}
+
+	sort.Slice(sources, func(i, j int) bool { return sources[i].name < sources[j].name })
+
+	return sources, nil
+}
@@ -1,11 +1,16 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 
 	"github.com/gigcodes/launch-util/config"
 	"github.com/gigcodes/launch-util/logger"
@@ -13,6 +18,11 @@ import (
 	"github.com/kolo/xmlrpc"
 )
 
+const (
+	// defaultEndpoint of the XML-RPC over the unix socket
+	defaultEndpoint = "http://localhost/RPC2"
+)
+
 // ProcessInfo represents the structure returned by Supervisor for a process.
 type ProcessInfo struct {
 	Name        string `xmlrpc:"name" json:"name"`
@@ -24,11 +34,14 @@ type ProcessInfo struct {
 	Statename   string `xmlrpc:"statename" json:"statename"`
 	State       int    `xmlrpc:"state" json:"state"`
 	Spawnerr    string `xmlrpc:"spawnerr" json:"spawnerr"`
+	ExitStatus  int    `xmlrpc:"exitstatus" json:"exitstatus"`
 	Uptime      int64  `json:"uptime"` // Process-specific uptime
 }
 
 // DaemonStatus is the structure used for each daemon's status.
 type DaemonStatus struct {
+	Instance    string        `json:"instance,omitempty"`
+	Provider    string        `json:"provider,omitempty"`
 	DaemonID    string        `json:"daemon_id"`
 	Status      string        `json:"status"`
 	Error       string        `json:"error,omitempty"`
@@ -39,27 +52,79 @@ type DaemonStatus struct {
 	Uptime      int64         `json:"total_uptime"` // Highest uptime in the group
 }
 
-// newUnixSocketXMLRPCClient creates a new XML-RPC client that communicates over a Unix socket.
-func newUnixSocketXMLRPCClient(socketPath, rpcEndpoint string) (*xmlrpc.Client, error) {
-	transport := &http.Transport{
-		Dial: func(network, addr string) (net.Conn, error) {
-			return net.Dial("unix", socketPath)
-		},
-		// You can also set timeouts or other transport options if desired.
+// newXMLRPCClient creates a new XML-RPC client of the supervisor instance, over the unix socket
+// or over TCP to the URL, with basic auth when the username is set.
+func newXMLRPCClient(instance config.SupervisorInstance) (*xmlrpc.Client, error) {
+	transport := &http.Transport{}
+	endpoint := instance.URL
+	if len(instance.Socket) > 0 {
+		socketPath := instance.Socket
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
+		// The host is not used over the unix socket
+		if len(endpoint) == 0 {
+			endpoint = defaultEndpoint
+		}
 	}
-	client, err := xmlrpc.NewClient(rpcEndpoint, transport)
+
+	endpoint, err := rpcURL(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("error creating XML-RPC client of %s: %w", instance.Name, err)
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if len(instance.Username) > 0 {
+		roundTripper = &basicAuthTransport{
+			username:  instance.Username,
+			password:  instance.Password,
+			transport: transport,
+		}
+	}
+
+	client, err := xmlrpc.NewClient(endpoint, roundTripper)
 	if err != nil {
 		return nil, fmt.Errorf("error creating XML-RPC client: %w", err)
 	}
 	return client, nil
 }
 
-// getSupervisorProcessInfo connects to Supervisor's XML-RPC endpoint via Unix socket and retrieves process info.
-func getSupervisorProcessInfo(socketPath, rpcEndpoint, processID string) (*ProcessInfo, error) {
-	client, err := newUnixSocketXMLRPCClient(socketPath, rpcEndpoint)
+// rpcURL of the `inet_http_server`, `http://127.0.0.1:9001` is `http://127.0.0.1:9001/RPC2`
+func rpcURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported url %q, http:// or https:// is required", endpoint)
+	}
+	if len(u.Path) == 0 || u.Path == "/" {
+		u.Path = "/RPC2"
+	}
+	return u.String(), nil
+}
+
+// basicAuthTransport send the username and password of `[unix_http_server]` or `[inet_http_server]`
+type basicAuthTransport struct {
+	username  string
+	password  string
+	transport http.RoundTripper
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.username, t.password)
+	return t.transport.RoundTrip(req)
+}
+
+// getSupervisorProcessInfo connects to Supervisor's XML-RPC endpoint of the instance and retrieves process info.
+var getSupervisorProcessInfo = func(instance config.SupervisorInstance, processID string) (*ProcessInfo, error) {
+	client, err := newXMLRPCClient(instance)
 	if err != nil {
 		return nil, err
 	}
+	defer client.Close()
 	var info ProcessInfo
 	err = client.Call("supervisor.getProcessInfo", []interface{}{processID}, &info)
 	if err != nil {
@@ -68,16 +133,41 @@ func getSupervisorProcessInfo(socketPath, rpcEndpoint, processID string) (*Proce
 	return &info, nil
 }
 
-func sendStatusWebhook(payload map[string]interface{}) error {
-	webhook := notifier.NewWebhook(config.Pulse.Webhook)
-	return webhook.Notify(payload)
+var sendStatusWebhook = func(payload map[string]interface{}) error {
+	statuses, _ := payload["data"].([]DaemonStatus)
+
+	message := notifier.Message{
+		Event:   notifier.EventSupervisor,
+		Status:  "fully_running",
+		Success: true,
+		Title:   "Supervisor status",
+		Payload: payload,
+	}
+
+	var text []string
+	for _, status := range statuses {
+		if status.Status != "fully_running" {
+			message.Status = status.Status
+			message.Success = false
+		}
+		line := fmt.Sprintf("%s/%s: %s", status.Instance, status.DaemonID, status.Status)
+		if len(status.Error) > 0 {
+			line += " (" + status.Error + ")"
+		}
+		text = append(text, line)
+	}
+	sort.Strings(text)
+	message.Text = strings.Join(text, "\n")
+
+	return notifier.Send(config.Notifiers, config.DefaultRetry, message)
 }
 
-func getAllSupervisorProcessInfo(socketPath, rpcEndpoint string) ([]ProcessInfo, error) {
-	client, err := newUnixSocketXMLRPCClient(socketPath, rpcEndpoint)
+var getAllSupervisorProcessInfo = func(instance config.SupervisorInstance) ([]ProcessInfo, error) {
+	client, err := newXMLRPCClient(instance)
 	if err != nil {
 		return nil, err
 	}
+	defer client.Close()
 	var infos []ProcessInfo
 	err = client.Call("supervisor.getAllProcessInfo", nil, &infos)
 	if err != nil {
@@ -86,63 +176,127 @@ func getAllSupervisorProcessInfo(socketPath, rpcEndpoint string) ([]ProcessInfo,
 	return infos, nil
 }
 
-// SendDaemonStatus checks the status for each given daemon ID using Supervisor via Unix socket,
-// prepares a payload with event "d_stat" and an array of daemon statuses (including uptime and extra info),
-// and sends it via your configured webhook.
-// The parameters socketPath and rpcEndpoint must be provided. For example:
-//
-//	socketPath: "/var/run/supervisor.sock"
-//	rpcEndpoint: "http://localhost/RPC2"
-func SendDaemonStatus(daemonIDs []string, socketPath, rpcEndpoint string) error {
-	statusMap := make(map[string]*DaemonStatus)
+// SendDaemonStatus checks the status for each given daemon ID on the supervisor instances and the
+// process managers, systemd units and Docker containers, prepares a payload with event "d_stat" and
+// an array of daemon statuses of all of them (including the instance, provider, uptime and extra info),
+// and sends it to the notifiers. A daemon ID `instance/id` is only checked on the instance, the other
+// IDs are checked on all instances and reported as not running when no instance has them.
+func SendDaemonStatus(daemonIDs []string, instances []config.SupervisorInstance, managers map[string]config.SubConfig) error {
+	sources, err := newSources(instances, managers)
+	if err != nil {
+		return err
+	}
+
+	statusMaps := make(map[string]map[string]*DaemonStatus)
+	kinds := make(map[string]string)
+	var missing []DaemonStatus
+	var lookupErrs []error
+
+	for _, s := range sources {
+		statusMaps[s.name] = make(map[string]*DaemonStatus)
+		kinds[s.name] = s.kind
+	}
 
 	// If no daemon IDs are provided, retrieve status for all daemons.
 	if len(daemonIDs) == 0 {
-		allInfos, err := getAllSupervisorProcessInfo(socketPath, rpcEndpoint)
-		if err != nil {
-			return fmt.Errorf("failed to retrieve all process info: %w", err)
-		}
-		for _, info := range allInfos {
-			updateDaemonGroupStatus(statusMap, &info)
-		}
-	} else {
-		// Retrieve status for the provided daemon IDs.
-		for _, id := range daemonIDs {
-			info, err := getSupervisorProcessInfo(socketPath, rpcEndpoint, id)
+		for _, s := range sources {
+			allInfos, err := s.AllProcessInfo()
 			if err != nil {
-				log.Printf("Error retrieving process info for %s: %v", id, err)
-				statusMap[id] = &DaemonStatus{
-					DaemonID:  id,
+				log.Printf("Error retrieving all process info of %s: %v", s.name, err)
+				lookupErrs = append(lookupErrs, fmt.Errorf("%s: %w", s.name, err))
+				missing = append(missing, DaemonStatus{
+					Instance:  s.name,
+					Provider:  s.kind,
+					DaemonID:  "all",
 					Status:    "not_running",
 					Error:     err.Error(),
 					Processes: []ProcessInfo{},
+				})
+				continue
+			}
+			for _, info := range allInfos {
+				updateDaemonGroupStatus(statusMaps[s.name], &info)
+			}
+		}
+	} else {
+		// Retrieve status for the provided daemon IDs.
+		for _, id := range daemonIDs {
+			instanceName, processID, ok := strings.Cut(id, "/")
+			if !ok {
+				instanceName, processID = "", id
+			}
+
+			found := false
+			errs := []error{}
+			for _, s := range sources {
+				if len(instanceName) > 0 && s.name != instanceName {
+					continue
 				}
+				info, err := s.ProcessInfo(processID)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				found = true
+				updateDaemonGroupStatus(statusMaps[s.name], info)
+			}
+			if found {
 				continue
 			}
-			updateDaemonGroupStatus(statusMap, info)
+
+			err := errors.Join(errs...)
+			if err == nil {
+				err = fmt.Errorf("instance %s not found", instanceName)
+			}
+			log.Printf("Error retrieving process info for %s: %v", id, err)
+			lookupErrs = append(lookupErrs, fmt.Errorf("%s: %w", id, err))
+			missing = append(missing, DaemonStatus{
+				Instance:  instanceName,
+				Provider:  kinds[instanceName],
+				DaemonID:  processID,
+				Status:    "not_running",
+				Error:     err.Error(),
+				Processes: []ProcessInfo{},
+			})
 		}
 	}
 
 	// Convert map to slice
-	var statuses []DaemonStatus
-	for _, status := range statusMap {
+	statuses := missing
+	for instanceName, statusMap := range statusMaps {
+		for _, status := range statusMap {
+			status.Instance = instanceName
+			status.Provider = kinds[instanceName]
+			statuses = append(statuses, *status)
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Instance != statuses[j].Instance {
+			return statuses[i].Instance < statuses[j].Instance
+		}
+		return statuses[i].DaemonID < statuses[j].DaemonID
+	})
+
+	for i := range statuses {
+		status := &statuses[i]
 		// Determine the final group-level status
 		runningCount := 0
 		for _, p := range status.Processes {
-			if p.State == 20 { // RUNNING
+			if p.State == stateRunning {
 				runningCount++
 			}
 		}
 
-		if runningCount == len(status.Processes) {
+		if len(status.Processes) == 0 {
+			// The daemon is not found
+			status.Status = "not_running"
+		} else if runningCount == len(status.Processes) {
 			status.Status = "fully_running"
 		} else if runningCount > 0 {
 			status.Status = "partially_running"
 		} else {
 			status.Status = "not_running"
 		}
-
-		statuses = append(statuses, *status)
 	}
 
 	payload := map[string]interface{}{
@@ -158,6 +312,11 @@ func SendDaemonStatus(daemonIDs []string, socketPath, rpcEndpoint string) error
 	}
 
 	logger.Infof("Grouped supervisor statuses by Group sent successfully: %+v", statuses)
+
+	// The daemons not found are sent as not running, and reported to the caller
+	if len(lookupErrs) > 0 {
+		return fmt.Errorf("failed to retrieve process info: %w", errors.Join(lookupErrs...))
+	}
 	return nil
 }
 
@@ -184,7 +343,7 @@ func updateDaemonGroupStatus(statusMap map[string]*DaemonStatus, info *ProcessIn
 
 	// Calculate uptime for this specific process
 	uptime := int64(0)
-	if info.State == 20 && info.Start > 0 {
+	if info.State == stateRunning && info.Start > 0 {
 		uptime = info.Now - info.Start
 	}
 
@@ -3,11 +3,17 @@ package rpc
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/longbridgeapp/assert"
+
+	"github.com/gigcodes/launch-util/config"
 )
 
+var defaultInstances = []config.SupervisorInstance{{Name: "default", Socket: "/var/run/supervisor.sock"}}
+
 // TestUpdateDaemonGroupStatus validates that processes are grouped correctly and uptime is assigned properly.
 func TestUpdateDaemonGroupStatus(t *testing.T) {
 	statusMap := make(map[string]*DaemonStatus)
@@ -49,7 +55,7 @@ func TestUpdateDaemonGroupStatus(t *testing.T) {
 	assert.True(t, statusMap["worker-group"] != nil)
 
 	// The highest uptime should be taken (process2 has the highest uptime)
-	assert.Equal(t, int64(7034), statusMap["worker-group"].Uptime)
+	assert.Equal(t, int64(7000), statusMap["worker-group"].Uptime)
 
 	// Ensure all processes are stored in the group
 	assert.Equal(t, len(statusMap["worker-group"].Processes), 3)
@@ -63,7 +69,7 @@ func TestUpdateDaemonGroupStatus(t *testing.T) {
 // TestSendDaemonStatus_NoDaemonIDs ensures it correctly processes all daemons when no IDs are provided.
 func TestSendDaemonStatus_NoDaemonIDs(t *testing.T) {
 	// Mock the getAllSupervisorProcessInfo function
-	getAllSupervisorProcessInfo = func(socketPath, rpcEndpoint string) ([]ProcessInfo, error) {
+	getAllSupervisorProcessInfo = func(instance config.SupervisorInstance) ([]ProcessInfo, error) {
 		return []ProcessInfo{
 			{Name: "worker-1", Group: "worker-group", Start: 1700000000, Now: 1700005000, State: 20},
 			{Name: "worker-2", Group: "worker-group", Start: 1699998000, Now: 1700005000, State: 20},
@@ -72,7 +78,7 @@ func TestSendDaemonStatus_NoDaemonIDs(t *testing.T) {
 	}
 
 	// Call function
-	err := SendDaemonStatus(nil, "/var/run/supervisor.sock", "http://localhost/RPC2")
+	err := SendDaemonStatus(nil, defaultInstances, nil)
 
 	// Validate results
 	assert.Nil(t, err)
@@ -81,7 +87,7 @@ func TestSendDaemonStatus_NoDaemonIDs(t *testing.T) {
 // TestSendDaemonStatus_WithDaemonIDs ensures correct processing when daemon IDs are provided.
 func TestSendDaemonStatus_WithDaemonIDs(t *testing.T) {
 	// Mock getSupervisorProcessInfo
-	getSupervisorProcessInfo = func(socketPath, rpcEndpoint, processID string) (*ProcessInfo, error) {
+	getSupervisorProcessInfo = func(instance config.SupervisorInstance, processID string) (*ProcessInfo, error) {
 		if processID == "worker-1" {
 			return &ProcessInfo{Name: "worker-1", Group: "worker-group", Start: 1700000000, Now: 1700005000, State: 20}, nil
 		}
@@ -92,7 +98,7 @@ func TestSendDaemonStatus_WithDaemonIDs(t *testing.T) {
 	}
 
 	// Call function with specific daemon IDs
-	err := SendDaemonStatus([]string{"worker-1", "worker-2"}, "/var/run/supervisor.sock", "http://localhost/RPC2")
+	err := SendDaemonStatus([]string{"worker-1", "worker-2"}, defaultInstances, nil)
 
 	// Validate results
 	assert.Nil(t, err)
@@ -101,13 +107,95 @@ func TestSendDaemonStatus_WithDaemonIDs(t *testing.T) {
 // TestSendDaemonStatus_ErrorHandling ensures the function handles errors properly.
 func TestSendDaemonStatus_ErrorHandling(t *testing.T) {
 	// Mock getSupervisorProcessInfo to return an error
-	getSupervisorProcessInfo = func(socketPath, rpcEndpoint, processID string) (*ProcessInfo, error) {
+	getSupervisorProcessInfo = func(instance config.SupervisorInstance, processID string) (*ProcessInfo, error) {
 		return nil, fmt.Errorf("Failed to fetch process info")
 	}
 
 	// Call function with a non-existing daemon ID
-	err := SendDaemonStatus([]string{"non-existing"}, "/var/run/supervisor.sock", "http://localhost/RPC2")
+	err := SendDaemonStatus([]string{"non-existing"}, defaultInstances, nil)
 
 	// Validate that an error is returned
 	assert.NotNil(t, err)
 }
+
+func TestSendDaemonStatus_instances(t *testing.T) {
+	originalGet, originalGetAll, originalSend := getSupervisorProcessInfo, getAllSupervisorProcessInfo, sendStatusWebhook
+	defer func() {
+		getSupervisorProcessInfo, getAllSupervisorProcessInfo, sendStatusWebhook = originalGet, originalGetAll, originalSend
+	}()
+
+	instances := []config.SupervisorInstance{{Name: "app", Socket: "/tmp/supervisor.sock"}, {Name: "queue", URL: "http://127.0.0.1:9001"}}
+	getAllSupervisorProcessInfo = func(instance config.SupervisorInstance) ([]ProcessInfo, error) {
+		if instance.Name == "queue" {
+			return nil, errors.New("connection refused")
+		}
+		return []ProcessInfo{{Name: "web", Group: "web", State: 20}}, nil
+	}
+	getSupervisorProcessInfo = func(instance config.SupervisorInstance, processID string) (*ProcessInfo, error) {
+		if instance.Name == "queue" && processID == "worker" {
+			return &ProcessInfo{Name: "worker", Group: "worker", State: 20}, nil
+		}
+		return nil, errors.New("BAD_NAME")
+	}
+	var statuses []DaemonStatus
+	sendStatusWebhook = func(payload map[string]interface{}) error {
+		statuses = payload["data"].([]DaemonStatus)
+		return nil
+	}
+
+	// All the instances in a single payload, the unreachable one is not running
+	err := SendDaemonStatus(nil, instances, nil)
+	assert.EqualError(t, err, "failed to retrieve process info: queue: connection refused")
+	assert.Len(t, statuses, 2)
+	assert.Equal(t, "app", statuses[0].Instance)
+	assert.Equal(t, "web", statuses[0].DaemonID)
+	assert.Equal(t, "fully_running", statuses[0].Status)
+	assert.Equal(t, "queue", statuses[1].Instance)
+	assert.Equal(t, "all", statuses[1].DaemonID)
+	assert.Equal(t, "not_running", statuses[1].Status)
+
+	// The daemon found on any instance, or on the given instance
+	err = SendDaemonStatus([]string{"worker", "app/worker"}, instances, nil)
+	assert.Error(t, err)
+	assert.Len(t, statuses, 2)
+	assert.Equal(t, "app", statuses[0].Instance)
+	assert.Equal(t, "not_running", statuses[0].Status)
+	assert.Equal(t, "queue", statuses[1].Instance)
+	assert.Equal(t, "fully_running", statuses[1].Status)
+
+	err = SendDaemonStatus([]string{"missing/worker"}, instances, nil)
+	assert.EqualError(t, err, "failed to retrieve process info: missing/worker: instance missing not found")
+}
+
+func TestNewXMLRPCClient(t *testing.T) {
+	var paths, auths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		username, password, _ := r.BasicAuth()
+		auths = append(auths, username+":"+password)
+		fmt.Fprint(w, response("<string>RUNNING</string>"))
+	}))
+	defer server.Close()
+
+	client, err := newXMLRPCClient(config.SupervisorInstance{Name: "queue", URL: server.URL, Username: "user", Password: "123"})
+	assert.NoError(t, err)
+	defer client.Close()
+
+	var state string
+	assert.NoError(t, client.Call("supervisor.getState", nil, &state))
+	assert.Equal(t, "RUNNING", state)
+	assert.Equal(t, []string{"/RPC2"}, paths)
+	assert.Equal(t, []string{"user:123"}, auths)
+
+	_, err = newXMLRPCClient(config.SupervisorInstance{Name: "queue", URL: "127.0.0.1:9001"})
+	assert.Error(t, err)
+
+	// The unix socket without the url
+	s := newFakeSupervisor(t, map[string]func(params []string) string{
+		"supervisor.getState": func(params []string) string { return response("<string>RUNNING</string>") },
+	})
+	client, err = newXMLRPCClient(s.instance())
+	assert.NoError(t, err)
+	defer client.Close()
+	assert.NoError(t, client.Call("supervisor.getState", nil, &state))
+}
@@ -0,0 +1,191 @@
+package rpc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gigcodes/launch-util/helper"
+)
+
+const (
+	systemdProperties = "Id,Description,LoadState,ActiveState,SubState,Result,ExecMainStatus,ActiveEnterTimestamp,InactiveEnterTimestamp"
+	// systemdTimestampLayout of `systemctl show`, `Thu 2026-10-16 19:00:00 UTC`
+	systemdTimestampLayout = "Mon 2006-01-02 15:04:05 MST"
+)
+
+var (
+	systemctl = func(args ...string) (string, error) {
+		return helper.Exec("systemctl", args...)
+	}
+)
+
+// Systemd provider of the units by `systemctl show`
+//
+// type: systemd
+// units: nginx, php-fpm.service (all the services when empty)
+// user: false (the units of the user with `systemctl --user`)
+type Systemd struct {
+	Base
+	units []string
+	user  bool
+}
+
+func newSystemd(base Base) *Systemd {
+	return &Systemd{
+		Base:  base,
+		units: base.viper.GetStringSlice("units"),
+		user:  base.viper.GetBool("user"),
+	}
+}
+
+func (s *Systemd) ProcessInfo(id string) (*ProcessInfo, error) {
+	units, err := s.show(unitName(id))
+	if err != nil {
+		return nil, err
+	}
+	if len(units) == 0 || units[0]["LoadState"] == "not-found" {
+		return nil, fmt.Errorf("unit %s not found", unitName(id))
+	}
+
+	info := s.processInfo(units[0])
+	return &info, nil
+}
+
+// AllProcessInfo of the units, the units not found are UNKNOWN. All the loaded services when `units` is empty.
+func (s *Systemd) AllProcessInfo() ([]ProcessInfo, error) {
+	names := make([]string, 0, len(s.units))
+	for _, unit := range s.units {
+		names = append(names, unitName(unit))
+	}
+
+	if len(names) == 0 {
+		output, err := s.systemctl("list-units", "--type=service", "--all", "--plain", "--no-legend", "--no-pager")
+		if err != nil {
+			return nil, fmt.Errorf("error listing units: %w", err)
+		}
+		for _, line := range strings.Split(output, "\n") {
+			// The failed units start with `●` in some versions
+			fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(line), "●"))
+			if len(fields) < 2 || fields[1] == "not-found" {
+				continue
+			}
+			names = append(names, fields[0])
+		}
+		if len(names) == 0 {
+			return nil, nil
+		}
+	}
+
+	units, err := s.show(names...)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]ProcessInfo, 0, len(units))
+	for _, unit := range units {
+		infos = append(infos, s.processInfo(unit))
+	}
+	return infos, nil
+}
+
+func (s *Systemd) systemctl(args ...string) (string, error) {
+	if s.user {
+		args = append([]string{"--user"}, args...)
+	}
+	return systemctl(args...)
+}
+
+// show the properties of the units, the units are separated by an empty line
+func (s *Systemd) show(units ...string) ([]map[string]string, error) {
+	args := append([]string{"show", "--no-pager", "--property=" + systemdProperties}, units...)
+	output, err := s.systemctl(args...)
+	if err != nil {
+		return nil, fmt.Errorf("error calling systemctl show: %w", err)
+	}
+
+	var result []map[string]string
+	for _, block := range strings.Split(output, "\n\n") {
+		properties := map[string]string{}
+		for _, line := range strings.Split(block, "\n") {
+			key, value, ok := strings.Cut(line, "=")
+			if ok {
+				properties[key] = value
+			}
+		}
+		if len(properties) > 0 {
+			result = append(result, properties)
+		}
+	}
+	return result, nil
+}
+
+// processInfo of the unit, the states of systemd are mapped to the states of supervisor
+func (s *Systemd) processInfo(unit map[string]string) ProcessInfo {
+	name := strings.TrimSuffix(unit["Id"], ".service")
+	info := ProcessInfo{
+		Name:        name,
+		Group:       name,
+		Description: unit["Description"],
+		Start:       parseSystemdTimestamp(unit["ActiveEnterTimestamp"]),
+		Stop:        parseSystemdTimestamp(unit["InactiveEnterTimestamp"]),
+		Now:         now().Unix(),
+	}
+	info.ExitStatus, _ = strconv.Atoi(unit["ExecMainStatus"])
+	if len(unit["SubState"]) > 0 {
+		info.Description += " (" + unit["SubState"] + ")"
+	}
+
+	switch unit["ActiveState"] {
+	case "active", "reloading":
+		info.State = stateRunning
+	case "activating":
+		info.State = stateStarting
+		if unit["SubState"] == "auto-restart" {
+			info.State = stateBackoff
+		}
+	case "deactivating":
+		info.State = stateStopping
+	case "failed":
+		info.State = stateFatal
+		info.Spawnerr = unit["Result"]
+	case "inactive":
+		info.State = stateStopped
+		if info.ExitStatus != 0 {
+			info.State = stateExited
+		}
+	default:
+		info.State = stateUnknown
+	}
+	if unit["LoadState"] == "not-found" {
+		info.State = stateUnknown
+		info.Spawnerr = "unit not found"
+	}
+	info.Statename = stateNames[info.State]
+
+	return info
+}
+
+// unitName of the id, `nginx` is `nginx.service`
+func unitName(id string) string {
+	if strings.Contains(id, ".") {
+		return id
+	}
+	return id + ".service"
+}
+
+// parseSystemdTimestamp to the unix time, `n/a` and the empty timestamp are 0.
+// The timestamp is in the local time, or `@1700000000` with `--timestamp=unix`.
+func parseSystemdTimestamp(value string) int64 {
+	if strings.HasPrefix(value, "@") {
+		seconds, _ := strconv.ParseInt(value[1:], 10, 64)
+		return seconds
+	}
+
+	t, err := time.ParseInLocation(systemdTimestampLayout, value, time.Local)
+	if err != nil {
+		return 0
+	}
+	return t.Unix()
+}
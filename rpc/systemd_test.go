@@ -0,0 +1,117 @@
+package rpc
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
+
+	"github.com/gigcodes/launch-util/config"
+)
+
+const systemdShowOutput = `Id=nginx.service
+Description=A high performance web server
+LoadState=loaded
+ActiveState=active
+SubState=running
+Result=success
+ExecMainStatus=0
+ActiveEnterTimestamp=@1700000000
+InactiveEnterTimestamp=
+
+Id=queue.service
+Description=Queue worker
+LoadState=loaded
+ActiveState=failed
+SubState=failed
+Result=exit-code
+ExecMainStatus=1
+ActiveEnterTimestamp=n/a
+InactiveEnterTimestamp=@1700004000
+
+Id=missing.service
+LoadState=not-found
+ActiveState=inactive
+SubState=dead`
+
+func stubSystemctl(t *testing.T, outputs map[string]string) *[]string {
+	var calls []string
+	original, originalNow := systemctl, now
+	t.Cleanup(func() { systemctl, now = original, originalNow })
+
+	now = func() time.Time { return time.Unix(1700005000, 0) }
+	systemctl = func(args ...string) (string, error) {
+		calls = append(calls, strings.Join(args, " "))
+		return outputs[args[0]], nil
+	}
+	return &calls
+}
+
+func newTestSystemd(t *testing.T, settings map[string]interface{}) Provider {
+	v := viper.New()
+	for key, value := range settings {
+		v.Set(key, value)
+	}
+	_, p, err := newProvider(config.SubConfig{Name: "units", Type: ProviderSystemd, Viper: v})
+	assert.NoError(t, err)
+	return p
+}
+
+func TestSystemd_AllProcessInfo(t *testing.T) {
+	calls := stubSystemctl(t, map[string]string{
+		"show":       systemdShowOutput,
+		"list-units": "nginx.service loaded active running A high performance web server\n● queue.service loaded failed failed Queue worker\nold.service not-found inactive dead old.service",
+	})
+
+	infos, err := newTestSystemd(t, nil).AllProcessInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"list-units --type=service --all --plain --no-legend --no-pager",
+		"show --no-pager --property=" + systemdProperties + " nginx.service queue.service",
+	}, *calls)
+
+	assert.Len(t, infos, 3)
+	assert.Equal(t, ProcessInfo{
+		Name: "nginx", Group: "nginx", Description: "A high performance web server (running)",
+		Start: 1700000000, Now: 1700005000, State: stateRunning, Statename: "RUNNING",
+	}, infos[0])
+	assert.Equal(t, ProcessInfo{
+		Name: "queue", Group: "queue", Description: "Queue worker (failed)",
+		Stop: 1700004000, Now: 1700005000, State: stateFatal, Statename: "FATAL", Spawnerr: "exit-code", ExitStatus: 1,
+	}, infos[1])
+	assert.Equal(t, "UNKNOWN", infos[2].Statename)
+	assert.Equal(t, "unit not found", infos[2].Spawnerr)
+
+	// The configured units of the user
+	*calls = nil
+	_, err = newTestSystemd(t, map[string]interface{}{"units": []string{"nginx", "queue.service"}, "user": true}).AllProcessInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"--user show --no-pager --property=" + systemdProperties + " nginx.service queue.service"}, *calls)
+}
+
+func TestSystemd_ProcessInfo(t *testing.T) {
+	stubSystemctl(t, map[string]string{"show": systemdShowOutput})
+	p := newTestSystemd(t, nil)
+
+	info, err := p.ProcessInfo("nginx")
+	assert.NoError(t, err)
+	assert.Equal(t, "RUNNING", info.Statename)
+
+	stubSystemctl(t, map[string]string{"show": "Id=missing.service\nLoadState=not-found"})
+	_, err = p.ProcessInfo("missing")
+	assert.EqualError(t, err, "unit missing.service not found")
+
+	_, _, err = newProvider(config.SubConfig{Name: "units", Type: "launchd"})
+	assert.EqualError(t, err, "[launchd] process manager type has not implement")
+}
+
+func Test_parseSystemdTimestamp(t *testing.T) {
+	assert.Equal(t, int64(1700000000), parseSystemdTimestamp("@1700000000"))
+	assert.Equal(t, int64(0), parseSystemdTimestamp("n/a"))
+	assert.Equal(t, int64(0), parseSystemdTimestamp(""))
+
+	local := time.Unix(1700000000, 0).Local()
+	assert.Equal(t, int64(1700000000), parseSystemdTimestamp(local.Format(systemdTimestampLayout)))
+}
@@ -0,0 +1,293 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/notifier"
+)
+
+// Supervisor process states
+const (
+	stateStopped  = 0
+	stateStarting = 10
+	stateRunning  = 20
+	stateBackoff  = 30
+	stateStopping = 40
+	stateExited   = 100
+	stateFatal    = 200
+	stateUnknown  = 1000
+)
+
+var stateNames = map[int]string{
+	stateStopped: "STOPPED", stateStarting: "STARTING", stateRunning: "RUNNING", stateBackoff: "BACKOFF",
+	stateStopping: "STOPPING", stateExited: "EXITED", stateFatal: "FATAL", stateUnknown: "UNKNOWN",
+}
+
+// StateChange of a supervisor process between two polls of the watchdog
+type StateChange struct {
+	// Instance of the supervisor
+	Instance string `json:"instance,omitempty"`
+	// Name of the process, `group:name`
+	Name        string    `json:"name"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	Description string    `json:"description,omitempty"`
+	ExitStatus  int       `json:"exitstatus"`
+	Time        time.Time `json:"time"`
+	// Flapping is set on the change which makes the process flapping, the next changes are not notified
+	// until the process is stable for `flap_window`, then a change with Stable is notified.
+	Flapping bool `json:"flapping,omitempty"`
+	Stable   bool `json:"stable,omitempty"`
+	// Restarted the FATAL process with `restart_fatal`
+	Restarted    bool   `json:"restarted,omitempty"`
+	RestartError string `json:"restart_error,omitempty"`
+}
+
+// watchedProcess the state of a process since the previous polls
+type watchedProcess struct {
+	info ProcessInfo
+	// alerting is true after a failure state until the process is running again
+	alerting bool
+	// changes notified in the flap window
+	changes  []time.Time
+	flapping bool
+	// restarts in the last hour
+	restarts []time.Time
+}
+
+var (
+	watchLock = sync.Mutex{}
+	// watched processes by `instance/group:name`, nil before the first poll
+	watched map[string]*watchedProcess
+
+	now = time.Now
+
+	startProcess = func(instance config.SupervisorInstance, name string) error {
+		client, err := newXMLRPCClient(instance)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		result := control(client, ActionStart, name, "")[0]
+		if result.Status != resultOK {
+			return fmt.Errorf("%s", result.Error)
+		}
+		return nil
+	}
+
+	sendStateChanges = func(changes []StateChange) error {
+		message := notifier.Message{
+			Event:   notifier.EventSupervisor,
+			Status:  "recovered",
+			Success: true,
+			Title:   "Supervisor state changes",
+			Payload: map[string]interface{}{
+				"event": "d_state_change",
+				"data":  changes,
+			},
+		}
+
+		var text []string
+		for _, change := range changes {
+			name := change.Instance + "/" + change.Name
+			line := fmt.Sprintf("%s: %s → %s", name, change.From, change.To)
+			if len(change.From) == 0 {
+				line = fmt.Sprintf("%s: %s", name, change.To)
+			}
+			if len(change.Description) > 0 {
+				line += " (" + change.Description + ")"
+			}
+			switch {
+			case change.Flapping:
+				line += ", flapping, the next changes are not notified until it is stable"
+			case change.Stable:
+				line += ", stable again"
+			}
+			if change.Restarted {
+				line += ", restarted"
+			} else if len(change.RestartError) > 0 {
+				line += ", restart failed: " + change.RestartError
+			}
+			text = append(text, line)
+
+			if change.To != "RUNNING" {
+				message.Status = change.To
+				message.Success = false
+			}
+		}
+		message.Text = strings.Join(text, "\n")
+
+		return notifier.Send(config.Notifiers, config.DefaultRetry, message)
+	}
+)
+
+// Watch poll the processes of the supervisor instances once with the `supervisor` config, and send the
+// state changes since the previous poll to the notifiers with event "d_state_change". Only the failures,
+// BACKOFF, FATAL, UNKNOWN and EXITED with a non zero exit status, and the recoveries to RUNNING are notified.
+// An unreachable instance does not stop the others from being watched, its error is returned.
+func Watch() error {
+	cfg := config.Supervisor
+
+	watchLock.Lock()
+	defer watchLock.Unlock()
+
+	t := now()
+	var changes []StateChange
+	var errs []error
+	seen := map[string]bool{}
+	for _, instance := range cfg.Instances {
+		infos, err := getAllSupervisorProcessInfo(instance)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to retrieve all process info of %s: %w", instance.Name, err))
+			// Keep the processes of the instance until it is reachable again
+			for key := range watched {
+				if strings.HasPrefix(key, instance.Name+"/") {
+					seen[key] = true
+				}
+			}
+			continue
+		}
+
+		for _, info := range infos {
+			change, ok := watch(cfg, instance, info, t)
+			seen[instance.Name+"/"+change.Name] = true
+			if ok {
+				changes = append(changes, change)
+			}
+		}
+	}
+
+	for key, p := range watched {
+		if !seen[key] {
+			delete(watched, key)
+			continue
+		}
+
+		// The flapping process is stable for the flap window
+		p.changes = since(p.changes, t.Add(-cfg.FlapWindow))
+		if p.flapping && len(p.changes) == 0 {
+			p.flapping = false
+			instanceName, name, _ := strings.Cut(key, "/")
+			change := newStateChange(instanceName, name, p.info.Statename, p.info, t)
+			change.Stable = true
+			changes = append(changes, change)
+		}
+	}
+
+	if len(changes) > 0 {
+		if err := sendStateChanges(changes); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// watch update the watched process with the info of the poll, and return the state change to notify
+func watch(cfg config.SupervisorConfig, instance config.SupervisorInstance, info ProcessInfo, t time.Time) (StateChange, bool) {
+	name := info.Group + ":" + info.Name
+	key := instance.Name + "/" + name
+
+	p, ok := watched[key]
+	if !ok {
+		p = &watchedProcess{info: info, alerting: isFailure(info)}
+		if watched == nil {
+			watched = map[string]*watchedProcess{}
+		}
+		watched[key] = p
+
+		// The processes found FATAL are restarted, the other states are the baseline
+		change := newStateChange(instance.Name, name, "", info, t)
+		if info.State == stateFatal && cfg.RestartFatal {
+			restartFatal(cfg, instance, p, &change, t)
+			return change, true
+		}
+		return change, false
+	}
+
+	previous := p.info
+	p.info = info
+	change := newStateChange(instance.Name, name, previous.Statename, info, t)
+	if previous.State == info.State {
+		return change, false
+	}
+
+	failure := isFailure(info)
+	recovered := p.alerting && info.State == stateRunning
+	if !failure && !recovered {
+		return change, false
+	}
+	p.alerting = failure
+
+	p.changes = append(since(p.changes, t.Add(-cfg.FlapWindow)), t)
+	suppressed := p.flapping
+	if !p.flapping && cfg.FlapThreshold > 0 && len(p.changes) >= cfg.FlapThreshold {
+		p.flapping = true
+		change.Flapping = true
+	}
+
+	if info.State == stateFatal && cfg.RestartFatal {
+		restartFatal(cfg, instance, p, &change, t)
+	}
+
+	return change, !suppressed
+}
+
+// isFailure return true when the process failed to start or exited unexpectedly,
+// the expected exit codes are not in the process info so a non zero exit status is unexpected.
+func isFailure(info ProcessInfo) bool {
+	switch info.State {
+	case stateBackoff, stateFatal, stateUnknown:
+		return true
+	case stateExited:
+		return info.ExitStatus != 0
+	}
+	return false
+}
+
+func newStateChange(instance, name, from string, info ProcessInfo, t time.Time) StateChange {
+	change := StateChange{
+		Instance:    instance,
+		Name:        name,
+		From:        from,
+		To:          info.Statename,
+		Description: info.Description,
+		ExitStatus:  info.ExitStatus,
+		Time:        t,
+	}
+	if len(info.Spawnerr) > 0 {
+		change.Description = info.Spawnerr
+	}
+	return change
+}
+
+// restartFatal restart the FATAL process up to `max_restarts_per_hour` times
+func restartFatal(cfg config.SupervisorConfig, instance config.SupervisorInstance, p *watchedProcess, change *StateChange, t time.Time) {
+	p.restarts = since(p.restarts, t.Add(-time.Hour))
+	if len(p.restarts) >= cfg.MaxRestartsPerHour {
+		change.RestartError = fmt.Sprintf("restarted %d times in the last hour", len(p.restarts))
+		return
+	}
+
+	p.restarts = append(p.restarts, t)
+	if err := startProcess(instance, change.Name); err != nil {
+		change.RestartError = err.Error()
+		return
+	}
+	change.Restarted = true
+}
+
+// since return the times after `start`
+func since(times []time.Time, start time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(start) {
+		i++
+	}
+	return times[i:]
+}
@@ -0,0 +1,195 @@
+package rpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+
+	"github.com/gigcodes/launch-util/config"
+)
+
+// fakeWatch stub the supervisor, the clock and the notifications of the watchdog
+type fakeWatch struct {
+	states   map[string]int
+	exits    map[string]int
+	time     time.Time
+	started  []string
+	startErr error
+	// unreachable instances
+	down map[string]error
+	sent [][]StateChange
+}
+
+func newFakeWatch(t *testing.T, cfg config.SupervisorConfig) *fakeWatch {
+	f := &fakeWatch{states: map[string]int{}, exits: map[string]int{}, time: time.Unix(1700000000, 0), down: map[string]error{}}
+
+	originalConfig, originalGetAll, originalStart, originalSend, originalNow :=
+		config.Supervisor, getAllSupervisorProcessInfo, startProcess, sendStateChanges, now
+	t.Cleanup(func() {
+		config.Supervisor, getAllSupervisorProcessInfo, startProcess, sendStateChanges, now =
+			originalConfig, originalGetAll, originalStart, originalSend, originalNow
+		watched = nil
+	})
+
+	watched = nil
+	if len(cfg.Instances) == 0 {
+		cfg.Instances = []config.SupervisorInstance{{Name: "default"}}
+	}
+	config.Supervisor = cfg
+	getAllSupervisorProcessInfo = func(instance config.SupervisorInstance) ([]ProcessInfo, error) {
+		if err := f.down[instance.Name]; err != nil {
+			return nil, err
+		}
+		var infos []ProcessInfo
+		for _, name := range []string{"web", "worker"} {
+			if state, ok := f.states[name]; ok {
+				infos = append(infos, ProcessInfo{Name: name, Group: name, State: state, Statename: stateNames[state], ExitStatus: f.exits[name]})
+			}
+		}
+		return infos, nil
+	}
+	startProcess = func(instance config.SupervisorInstance, name string) error {
+		f.started = append(f.started, instance.Name+"/"+name)
+		return f.startErr
+	}
+	sendStateChanges = func(changes []StateChange) error {
+		f.sent = append(f.sent, changes)
+		return nil
+	}
+	now = func() time.Time { return f.time }
+
+	return f
+}
+
+// poll set the states, advance the clock and watch, return the changes sent
+func (f *fakeWatch) poll(t *testing.T, states map[string]int) []StateChange {
+	for name, state := range states {
+		f.states[name] = state
+	}
+	f.time = f.time.Add(30 * time.Second)
+
+	sent := len(f.sent)
+	assert.NoError(t, Watch())
+	if len(f.sent) == sent {
+		return nil
+	}
+	return f.sent[len(f.sent)-1]
+}
+
+func transitions(changes []StateChange) []string {
+	var result []string
+	for _, change := range changes {
+		result = append(result, change.Instance+"/"+change.Name+" "+change.From+">"+change.To)
+	}
+	return result
+}
+
+func TestWatch(t *testing.T) {
+	f := newFakeWatch(t, config.SupervisorConfig{FlapThreshold: 5, FlapWindow: 10 * time.Minute})
+
+	// The first poll is the baseline
+	assert.Nil(t, f.poll(t, map[string]int{"web": stateRunning, "worker": stateStopped}))
+
+	// The stop and the start are not notified
+	assert.Nil(t, f.poll(t, map[string]int{"worker": stateStarting}))
+	assert.Nil(t, f.poll(t, map[string]int{"worker": stateRunning}))
+
+	changes := f.poll(t, map[string]int{"web": stateFatal})
+	assert.Equal(t, []string{"default/web:web RUNNING>FATAL"}, transitions(changes))
+	assert.False(t, changes[0].Restarted)
+	assert.Len(t, f.started, 0)
+
+	// Recovered
+	assert.Equal(t, []string{"default/web:web FATAL>RUNNING"}, transitions(f.poll(t, map[string]int{"web": stateRunning})))
+	assert.Nil(t, f.poll(t, nil))
+
+	// The expected exit is not notified, the unexpected one is
+	assert.Nil(t, f.poll(t, map[string]int{"worker": stateExited}))
+	f.exits["worker"] = 1
+	assert.Nil(t, f.poll(t, map[string]int{"worker": stateRunning}))
+	changes = f.poll(t, map[string]int{"worker": stateExited})
+	assert.Equal(t, []string{"default/worker:worker RUNNING>EXITED"}, transitions(changes))
+	assert.Equal(t, 1, changes[0].ExitStatus)
+
+	// The removed process is forgotten
+	delete(f.states, "worker")
+	assert.Nil(t, f.poll(t, nil))
+	assert.Len(t, watched, 1)
+}
+
+func TestWatch_restartFatal(t *testing.T) {
+	f := newFakeWatch(t, config.SupervisorConfig{RestartFatal: true, MaxRestartsPerHour: 2})
+
+	// The process found FATAL is restarted
+	changes := f.poll(t, map[string]int{"web": stateFatal})
+	assert.Equal(t, []string{"default/web:web >FATAL"}, transitions(changes))
+	assert.True(t, changes[0].Restarted)
+	assert.Equal(t, []string{"default/web:web"}, f.started)
+
+	f.poll(t, map[string]int{"web": stateBackoff})
+	f.startErr = errors.New("SPAWN_ERROR: web")
+	changes = f.poll(t, map[string]int{"web": stateFatal})
+	assert.False(t, changes[0].Restarted)
+	assert.Equal(t, "SPAWN_ERROR: web", changes[0].RestartError)
+
+	// Up to 2 restarts per hour
+	f.poll(t, map[string]int{"web": stateBackoff})
+	changes = f.poll(t, map[string]int{"web": stateFatal})
+	assert.Equal(t, "restarted 2 times in the last hour", changes[0].RestartError)
+	assert.Len(t, f.started, 2)
+
+	f.time = f.time.Add(time.Hour)
+	f.startErr = nil
+	f.poll(t, map[string]int{"web": stateBackoff})
+	changes = f.poll(t, map[string]int{"web": stateFatal})
+	assert.True(t, changes[0].Restarted)
+	assert.Len(t, f.started, 3)
+}
+
+func TestWatch_flapping(t *testing.T) {
+	f := newFakeWatch(t, config.SupervisorConfig{FlapThreshold: 3, FlapWindow: 5 * time.Minute})
+
+	f.poll(t, map[string]int{"web": stateRunning})
+
+	// BACKOFF loop
+	assert.Len(t, f.poll(t, map[string]int{"web": stateBackoff}), 1)
+	assert.Nil(t, f.poll(t, map[string]int{"web": stateStarting}))
+	assert.Len(t, f.poll(t, map[string]int{"web": stateBackoff}), 1)
+	assert.Nil(t, f.poll(t, map[string]int{"web": stateStarting}))
+	changes := f.poll(t, map[string]int{"web": stateBackoff})
+	assert.Len(t, changes, 1)
+	assert.True(t, changes[0].Flapping)
+
+	// The changes of the flapping process are suppressed
+	assert.Nil(t, f.poll(t, map[string]int{"web": stateStarting}))
+	assert.Nil(t, f.poll(t, map[string]int{"web": stateBackoff}))
+	assert.Nil(t, f.poll(t, map[string]int{"web": stateRunning}))
+
+	// Stable for the flap window
+	f.time = f.time.Add(5 * time.Minute)
+	changes = f.poll(t, nil)
+	assert.Equal(t, []string{"default/web:web RUNNING>RUNNING"}, transitions(changes))
+	assert.True(t, changes[0].Stable)
+
+	assert.Len(t, f.poll(t, map[string]int{"web": stateFatal}), 1)
+}
+
+func TestWatch_instances(t *testing.T) {
+	f := newFakeWatch(t, config.SupervisorConfig{Instances: []config.SupervisorInstance{{Name: "a"}, {Name: "b"}}})
+
+	f.poll(t, map[string]int{"web": stateRunning})
+	assert.Len(t, watched, 2)
+
+	// The unreachable instance does not stop the other, its processes are kept
+	f.down["b"] = errors.New("connection refused")
+	f.states["web"] = stateFatal
+	err := Watch()
+	assert.EqualError(t, err, "failed to retrieve all process info of b: connection refused")
+	assert.Equal(t, []string{"a/web:web RUNNING>FATAL"}, transitions(f.sent[len(f.sent)-1]))
+	assert.Len(t, watched, 2)
+
+	delete(f.down, "b")
+	assert.Equal(t, []string{"b/web:web RUNNING>FATAL"}, transitions(f.poll(t, nil)))
+}
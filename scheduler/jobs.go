@@ -0,0 +1,187 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	superlogger "github.com/gigcodes/launch-util/logger"
+	"github.com/gigcodes/launch-util/model"
+	"github.com/go-co-op/gocron"
+)
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+var (
+	// ErrJobRunning the model is queued or running
+	ErrJobRunning = errors.New("job is already running")
+
+	// performs of the models run one at a time
+	performLock = sync.Mutex{}
+
+	jobsLock  = sync.Mutex{}
+	jobs      = map[string]*Job{}
+	scheduled = map[string]*gocron.Job{}
+
+	performModel = func(modelConfig config.ModelConfig) error {
+		m := model.Model{
+			Config: modelConfig,
+		}
+		return m.Perform()
+	}
+)
+
+// Job the running or the last perform of a model
+type Job struct {
+	Model   string `json:"model"`
+	Status  string `json:"status"`
+	Trigger string `json:"trigger"`
+	// Duration in seconds
+	Duration   float64    `json:"duration"`
+	QueuedAt   time.Time  `json:"queued_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Done return true when the job is finished
+func (j Job) Done() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed
+}
+
+// ModelStatus the schedule and the job of a model
+type ModelStatus struct {
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule"`
+	NextRun  *time.Time `json:"next_run,omitempty"`
+	Job      *Job       `json:"job,omitempty"`
+}
+
+// Perform the model in background, return ErrJobRunning when the model is queued or running
+func Perform(name, trigger string) (*Job, error) {
+	modelConfig := config.GetModelConfigByName(name)
+	if modelConfig == nil {
+		return nil, fmt.Errorf("model %s not found", name)
+	}
+
+	job, err := queue(name, trigger)
+	if err != nil {
+		return nil, err
+	}
+
+	result := job.copy()
+	go perform(*modelConfig, job)
+
+	return result, nil
+}
+
+// Jobs return the running and the last jobs of the models
+func Jobs() []Job {
+	jobsLock.Lock()
+	defer jobsLock.Unlock()
+
+	result := make([]Job, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, *job)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Model < result[j].Model })
+
+	return result
+}
+
+// Status return the schedules and the jobs of the models in the config
+func Status() []ModelStatus {
+	jobsLock.Lock()
+	defer jobsLock.Unlock()
+
+	result := make([]ModelStatus, 0, len(config.Models))
+	for _, modelConfig := range config.Models {
+		status := ModelStatus{
+			Name:     modelConfig.Name,
+			Schedule: modelConfig.Schedule.String(),
+		}
+		if job, ok := jobs[modelConfig.Name]; ok {
+			status.Job = job.copy()
+		}
+		if cronJob, ok := scheduled[modelConfig.Name]; ok {
+			next := cronJob.NextRun()
+			status.NextRun = &next
+		}
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result
+}
+
+// queue a new job of the model unless the last one is not done
+func queue(name, trigger string) (*Job, error) {
+	jobsLock.Lock()
+	defer jobsLock.Unlock()
+
+	if job, ok := jobs[name]; ok && !job.Done() {
+		return nil, ErrJobRunning
+	}
+
+	job := &Job{
+		Model:    name,
+		Status:   JobQueued,
+		Trigger:  trigger,
+		QueuedAt: time.Now(),
+	}
+	jobs[name] = job
+
+	return job, nil
+}
+
+func perform(modelConfig config.ModelConfig, job *Job) {
+	logger := superlogger.Tag(fmt.Sprintf("Scheduler: %s", modelConfig.Name))
+
+	performLock.Lock()
+	defer performLock.Unlock()
+
+	logger.Info("Performing...")
+	startedAt := time.Now()
+	update(func() {
+		job.Status = JobRunning
+		job.StartedAt = &startedAt
+	})
+
+	err := performModel(modelConfig)
+
+	finishedAt := time.Now()
+	update(func() {
+		job.Status = JobSucceeded
+		if err != nil {
+			job.Status = JobFailed
+			job.Error = err.Error()
+		}
+		job.FinishedAt = &finishedAt
+		job.Duration = finishedAt.Sub(startedAt).Seconds()
+	})
+
+	if err != nil {
+		logger.Errorf("Failed to perform: %s", err.Error())
+	}
+	logger.Info("Done.")
+}
+
+func update(fn func()) {
+	jobsLock.Lock()
+	defer jobsLock.Unlock()
+
+	fn()
+}
+
+func (j *Job) copy() *Job {
+	job := *j
+	return &job
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+
+	"github.com/gigcodes/launch-util/config"
+)
+
+func TestPerform(t *testing.T) {
+	config.Models = []config.ModelConfig{{Name: "foo"}, {Name: "bar", Schedule: config.ScheduleConfig{Enabled: true, Cron: "0 0 * * *"}}}
+	jobs = map[string]*Job{}
+
+	release := make(chan error)
+	performModel = func(modelConfig config.ModelConfig) error {
+		return <-release
+	}
+
+	_, err := Perform("baz", "api")
+	assert.EqualError(t, err, "model baz not found")
+
+	job, err := Perform("foo", "api")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", job.Model)
+	assert.Equal(t, "api", job.Trigger)
+
+	_, err = Perform("foo", "api")
+	assert.Equal(t, ErrJobRunning, err)
+
+	release <- errors.New("dump failed")
+	waitJob(t, "foo")
+
+	status := Status()
+	assert.Len(t, status, 2)
+	assert.Equal(t, "bar", status[0].Name)
+	assert.Nil(t, status[0].Job)
+	assert.Equal(t, "cron 0 0 * * *", status[0].Schedule)
+	assert.Equal(t, "foo", status[1].Name)
+	assert.Equal(t, JobFailed, status[1].Job.Status)
+	assert.Equal(t, "dump failed", status[1].Job.Error)
+	assert.NotNil(t, status[1].Job.FinishedAt)
+
+	// The last job is done, perform again
+	_, err = Perform("foo", "api")
+	assert.NoError(t, err)
+	release <- nil
+	waitJob(t, "foo")
+	assert.Equal(t, JobSucceeded, Jobs()[0].Status)
+}
+
+func waitJob(t *testing.T, name string) {
+	for i := 0; i < 100; i++ {
+		for _, job := range Jobs() {
+			if job.Model == name && job.Done() {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s is not done", name)
+}
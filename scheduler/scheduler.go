@@ -3,13 +3,12 @@ package scheduler
 import (
 	"fmt"
 	"github.com/gigcodes/launch-util/psutil"
-	"sync"
+	"github.com/gigcodes/launch-util/rpc"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/gigcodes/launch-util/config"
 	superlogger "github.com/gigcodes/launch-util/logger"
-	"github.com/gigcodes/launch-util/model"
 	"github.com/go-co-op/gocron"
 )
 
@@ -29,24 +28,43 @@ func Start() error {
 
 	mycron = gocron.NewScheduler(time.Local)
 
-	mu := sync.Mutex{}
-
 	if config.Pulse.Enabled {
-		logger.Info("Launch pulse initiated")
+		logger.Info(fmt.Sprintf("Launch pulse initiated every %s with %d alerts", config.Pulse.Interval, len(config.Pulse.Alerts)))
 
-		if _, err := mycron.Every(5).Minutes().StartImmediately().Do(func() {
+		if _, err := mycron.Every(config.Pulse.Interval).StartImmediately().SingletonMode().Do(func() {
 			psutilData, err := psutil.Fetch()
-
 			if err != nil {
-				logger.Fatal("Error fetching system stats:", err)
+				superlogger.Tag("Pulse").Errorf("Error fetching system stats: %v", err)
+				return
+			}
+
+			if config.Pulse.Report {
+				psutil.Pulse(psutilData)
+			}
+			if err := psutil.Evaluate(psutilData); err != nil {
+				superlogger.Tag("Pulse").Error(err)
 			}
+		}); err != nil {
+			logger.Errorf("Failed to register job func: %s", err.Error())
+		}
+	}
 
-			psutil.Pulse(psutilData)
+	if config.Supervisor.Enabled {
+		logger.Info(fmt.Sprintf("Watch supervisor every %s", config.Supervisor.Interval))
+
+		if _, err := mycron.Every(config.Supervisor.Interval).SingletonMode().Do(func() {
+			if err := rpc.Watch(); err != nil {
+				superlogger.Tag("Supervisor").Error(err)
+			}
 		}); err != nil {
 			logger.Errorf("Failed to register job func: %s", err.Error())
 		}
 	}
 
+	jobsLock.Lock()
+	defer jobsLock.Unlock()
+	scheduled = map[string]*gocron.Job{}
+
 	for _, modelConfig := range config.Models {
 		if !modelConfig.Schedule.Enabled {
 			continue
@@ -61,23 +79,20 @@ func Start() error {
 			continue
 		}
 
-		if _, err := scheduler.Do(func(modelConfig config.ModelConfig) {
-			defer mu.Unlock()
-			logger := superlogger.Tag(fmt.Sprintf("Scheduler: %s", modelConfig.Name))
-
-			logger.Info("Performing...")
-
-			m := model.Model{
-				Config: modelConfig,
-			}
-			mu.Lock()
-			if err := m.Perform(); err != nil {
-				logger.Errorf("Failed to perform: %s", err.Error())
+		cronJob, err := scheduler.Do(func(modelConfig config.ModelConfig) {
+			job, err := queue(modelConfig.Name, "schedule")
+			if err != nil {
+				superlogger.Tag(fmt.Sprintf("Scheduler: %s", modelConfig.Name)).Warnf("Skip performing: %v", err)
+				return
 			}
-			logger.Info("Done.")
-		}, modelConfig); err != nil {
+
+			perform(modelConfig, job)
+		}, modelConfig)
+		if err != nil {
 			logger.Errorf("Failed to register job func: %s", err.Error())
+			continue
 		}
+		scheduled[modelConfig.Name] = cronJob
 	}
 
 	mycron.StartAsync()
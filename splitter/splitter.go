@@ -0,0 +1,115 @@
+package splitter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/logger"
+)
+
+const (
+	// Parts are suffixed with 3 digits, e.g. `-000`, so they keep sorted by name
+	maxParts = 1000
+)
+
+// Run splitter, split the archive into parts of `split_into_chunks_of` MB inside a directory
+// named by the archive without extension, return the directory path:
+//
+//	2022.12.04.07.09.47.tar.xz -> 2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000
+//
+// The archive path is returned as is when `split_into_chunks_of` is not configured.
+func Run(archivePath string, model config.ModelConfig) (string, error) {
+	logger := logger.Tag("Splitter")
+
+	if model.SplitIntoChunksOf <= 0 {
+		return archivePath, nil
+	}
+
+	chunkSize := int64(model.SplitIntoChunksOf) * 1024 * 1024
+
+	archiveName := filepath.Base(archivePath)
+	dirName := archiveName
+	if model.Viper != nil {
+		dirName = strings.TrimSuffix(archiveName, model.Viper.GetString("Ext"))
+	}
+	if dirName == archiveName {
+		dirName = strings.SplitN(archiveName, ".tar", 2)[0]
+	}
+	dirPath := filepath.Join(filepath.Dir(archivePath), dirName)
+
+	logger.Info("=> Split | " + humanize.IBytes(uint64(chunkSize)))
+	if err := split(archivePath, dirPath, chunkSize); err != nil {
+		return "", err
+	}
+
+	if err := os.Remove(archivePath); err != nil {
+		logger.Warnf("Remove %s failed: %v", archivePath, err)
+	}
+	logger.Info("->", dirPath)
+
+	return dirPath, nil
+}
+
+// split the file into `chunkSize` parts in `dirPath`
+func split(archivePath, dirPath string, chunkSize int64) error {
+	if chunkSize <= 0 {
+		return fmt.Errorf("invalid chunk size %d", chunkSize)
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if parts := (info.Size() + chunkSize - 1) / chunkSize; parts > maxParts {
+		return fmt.Errorf("split %s into %d parts exceeds the limit of %d parts, increase `split_into_chunks_of`", archivePath, parts, maxParts)
+	}
+
+	if err := helper.MkdirP(dirPath); err != nil {
+		return err
+	}
+
+	archiveName := filepath.Base(archivePath)
+	for i := 0; ; i++ {
+		partPath := filepath.Join(dirPath, fmt.Sprintf("%s-%03d", archiveName, i))
+		n, err := writePart(partPath, f, chunkSize)
+		if err != nil {
+			return fmt.Errorf("write part %s failed: %v", partPath, err)
+		}
+
+		if n < chunkSize {
+			// The last part is empty when the file size is a multiple of chunkSize
+			if n == 0 && i > 0 {
+				return os.Remove(partPath)
+			}
+			return nil
+		}
+	}
+}
+
+func writePart(partPath string, r io.Reader, chunkSize int64) (int64, error) {
+	part, err := os.Create(partPath)
+	if err != nil {
+		return 0, err
+	}
+	defer part.Close()
+
+	n, err := io.CopyN(part, r, chunkSize)
+	if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
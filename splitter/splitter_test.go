@@ -0,0 +1,68 @@
+package splitter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
+)
+
+func TestRun(t *testing.T) {
+	// without split_into_chunks_of
+	archivePath, err := Run("/tmp/foo.tar.gz", config.ModelConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, archivePath, "/tmp/foo.tar.gz")
+
+	archivePath = filepath.Join(t.TempDir(), "2022.12.04.07.09.47.tar.xz.enc")
+	assert.NoError(t, os.WriteFile(archivePath, []byte("hello"), 0660))
+
+	v := viper.New()
+	v.Set("Ext", ".tar.xz.enc")
+	dirPath, err := Run(archivePath, config.ModelConfig{SplitIntoChunksOf: 1, Viper: v})
+	assert.NoError(t, err)
+	assert.Equal(t, dirPath, filepath.Join(filepath.Dir(archivePath), "2022.12.04.07.09.47"))
+	assert.False(t, strings.HasSuffix(dirPath, ".tar.xz.enc"))
+
+	entries, err := os.ReadDir(dirPath)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, entries[0].Name(), "2022.12.04.07.09.47.tar.xz.enc-000")
+
+	_, err = os.Stat(archivePath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSplit(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "foo.tar")
+	assert.NoError(t, os.WriteFile(archivePath, []byte("0123456789"), 0660))
+
+	dirPath := filepath.Join(dir, "foo")
+	assert.NoError(t, split(archivePath, dirPath, 4))
+
+	entries, err := os.ReadDir(dirPath)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	var parts []string
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(dirPath, e.Name()))
+		assert.NoError(t, err)
+		parts = append(parts, e.Name()+":"+string(data))
+	}
+	assert.Equal(t, parts, []string{"foo.tar-000:0123", "foo.tar-001:4567", "foo.tar-002:89"})
+
+	// no empty part when the size is a multiple of chunk size
+	dirPath = filepath.Join(dir, "bar")
+	assert.NoError(t, split(archivePath, dirPath, 5))
+	entries, err = os.ReadDir(dirPath)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	err = split(archivePath, filepath.Join(dir, "dar"), 0)
+	assert.Error(t, err)
+}
@@ -1,14 +1,21 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
 
 	"github.com/gigcodes/launch-util/helper"
@@ -37,6 +44,10 @@ type Azure struct {
 	client    *azblob.Client
 }
 
+// Files larger than the block size are staged block by block and committed at last,
+// the staged blocks are kept by Azure for a week, so a failed upload continues from the missing blocks.
+const azureBlockSize int64 = 32 * 1024 * 1024
+
 func (s *Azure) open() error {
 	s.viper.SetDefault("timeout", "300")
 	s.viper.SetDefault("container", "gobackup")
@@ -112,7 +123,15 @@ func (s *Azure) upload(fileKey string) (err error) {
 		}
 		defer f.Close()
 
-		progress := helper.NewProgressBar(logger, f)
+		progress := helper.NewProgressBar(logger, f, s.limiters()...)
+		if progress.FileLength > azureBlockSize {
+			if err := s.uploadBlocks(ctx, f, progress, remotePath); err != nil {
+				return progress.Errorf("Azure upload error: %v", err)
+			}
+			progress.Done(remotePath)
+			continue
+		}
+
 		if _, err = s.client.UploadStream(ctx, s.container, remotePath, progress.Reader, nil); err != nil {
 			return progress.Errorf("Azure upload error: %v", err)
 		}
@@ -123,7 +142,75 @@ func (s *Azure) upload(fileKey string) (err error) {
 	return nil
 }
 
+// uploadBlocks stage the file block by block then commit the block list,
+// the blocks already staged by the unfinished upload of the same file are skipped.
+func (s *Azure) uploadBlocks(ctx context.Context, f *os.File, progress helper.ProgressBar, remotePath string) error {
+	logger := logger.Tag("Azure")
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	blobClient := s.client.ServiceClient().NewContainerClient(s.container).NewBlockBlobClient(remotePath)
+
+	// Staged blocks of the same file, by block ID
+	staged := map[string]int64{}
+	state := s.loadUploadState(remotePath, info)
+	if state.resumed {
+		resp, err := blobClient.GetBlockList(ctx, blockblob.BlockListTypeUncommitted, nil)
+		if err != nil {
+			logger.Warnf("Get staged blocks of %s failed, start over: %v", remotePath, err)
+		} else if resp.BlockList.UncommittedBlocks != nil {
+			for _, block := range resp.BlockList.UncommittedBlocks {
+				staged[*block.Name] = *block.Size
+			}
+			logger.Infof("Resume upload of %s, %d blocks staged", remotePath, len(staged))
+		}
+	}
+	if err := state.save(); err != nil {
+		return err
+	}
+
+	var blockIDs []string
+	buf := make([]byte, azureBlockSize)
+	for offset, index := int64(0), 0; offset < size; offset, index = offset+azureBlockSize, index+1 {
+		n := min(azureBlockSize, size-offset)
+		// Block IDs of the blob must have the same length
+		blockID := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%010d", index)))
+		blockIDs = append(blockIDs, blockID)
+
+		if staged[blockID] == n {
+			progress.Skip(n)
+			continue
+		}
+
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(progress.Reader, buf[:n]); err != nil {
+			return err
+		}
+		if _, err := blobClient.StageBlock(ctx, blockID, streaming.NopCloser(bytes.NewReader(buf[:n])), nil); err != nil {
+			return err
+		}
+	}
+
+	if _, err := blobClient.CommitBlockList(ctx, blockIDs, nil); err != nil {
+		return err
+	}
+
+	state.remove()
+	return nil
+}
+
 func (s *Azure) delete(fileKey string) (err error) {
+	// No need to remove empty directory
+	if strings.HasSuffix(fileKey, "/") {
+		return nil
+	}
+
 	remotePath := filepath.Join(s.path, fileKey)
 	var ctx = context.Background()
 
@@ -137,7 +224,7 @@ func (s *Azure) delete(fileKey string) (err error) {
 // List the objects in the bucket with the prefix = parent
 // https://pkg.go.dev/github.com/Azure/azure-sdk-for-go/sdk/storage/azblob
 func (s *Azure) list(parent string) ([]FileItem, error) {
-	remotePath := filepath.Join(s.archivePath, parent)
+	remotePath := listPrefix(filepath.Join(s.path, parent))
 	var ctx = context.Background()
 
 	var fileItems []FileItem
@@ -154,8 +241,12 @@ func (s *Azure) list(parent string) ([]FileItem, error) {
 		}
 
 		for _, blob := range resp.Segment.BlobItems {
+			key, ok := relativeKey(s.path, *blob.Name)
+			if !ok {
+				continue
+			}
 			fileItems = append(fileItems, FileItem{
-				Filename:     *blob.Name,
+				Filename:     key,
 				LastModified: *blob.Properties.LastModified,
 				Size:         *blob.Properties.ContentLength,
 			})
@@ -168,7 +259,66 @@ func (s *Azure) list(parent string) ([]FileItem, error) {
 // Get a client download URL
 func (s *Azure) download(fileKey string) (string, error) {
 	containerClient := s.client.ServiceClient().NewContainerClient(s.container)
-	blobClient := containerClient.NewBlobClient(fileKey)
+	blobClient := containerClient.NewBlobClient(filepath.Join(s.path, fileKey))
 
 	return blobClient.GetSASURL(sas.BlobPermissions{Read: true}, time.Now(), time.Now().Add(time.Hour*1))
 }
+
+func (s *Azure) fetch(fileKey string, localPath string) error {
+	remotePath := filepath.Join(s.path, fileKey)
+
+	var ctx = context.Background()
+	var cancel context.CancelFunc
+	if s.timeout.Seconds() > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	f, err := createLocalFile(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := s.client.DownloadFile(ctx, s.container, remotePath, f, nil); err != nil {
+		return fmt.Errorf("Azure failed to download file %q, %v", remotePath, err)
+	}
+
+	return nil
+}
+
+func (s *Azure) uploadStream(fileKey string, r io.Reader) error {
+	logger := logger.Tag("Azure")
+
+	var ctx = context.Background()
+	var cancel context.CancelFunc
+	if s.timeout.Seconds() > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	// Check to create Azure Storage Container, And ignore error
+	_, _ = s.client.CreateContainer(ctx, s.container, nil)
+
+	remotePath := filepath.Join(s.path, fileKey)
+	logger.Info("-> Uploading (stream)...")
+	if _, err := s.client.UploadStream(ctx, s.container, remotePath, r, nil); err != nil {
+		return fmt.Errorf("Azure upload error: %v", err)
+	}
+	logger.Info("Uploaded:", remotePath)
+
+	return nil
+}
+
+// remoteMD5 return the Content-MD5 of the blob, which is empty for the blobs uploaded by blocks
+func (s *Azure) remoteMD5(fileKey string) (string, error) {
+	ctx := context.Background()
+	remotePath := filepath.Join(s.path, fileKey)
+	blobClient := s.client.ServiceClient().NewContainerClient(s.container).NewBlobClient(remotePath)
+	props, err := blobClient.GetProperties(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(props.ContentMD5), nil
+}
@@ -1,13 +1,28 @@
 package storage
 
 import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"github.com/gigcodes/launch-util/config"
-	"github.com/gigcodes/launch-util/logger"
-	"github.com/spf13/viper"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/history"
+	"github.com/gigcodes/launch-util/logger"
+	"github.com/spf13/viper"
+)
+
+var (
+	// 2022.12.04.07.09.47.tar.xz-000
+	partSuffixRegexp = regexp.MustCompile(`-\d{3}$`)
 )
 
 // Base storage
@@ -18,6 +33,10 @@ type Base struct {
 	fileKeys    []string
 	viper       *viper.Viper
 	keep        int
+	retention   Retention
+	repository  bool
+	rateLimiter *helper.RateLimiter
+	retry       helper.RetryConfig
 	cycler      *Cycler
 }
 
@@ -32,9 +51,12 @@ type Storage interface {
 	open() error
 	close()
 	upload(fileKey string) error
+	uploadStream(fileKey string, r io.Reader) error
 	delete(fileKey string) error
+	// list all files under `parent` recursively, `Filename` is the key relative to the storage path
 	list(parent string) ([]FileItem, error)
 	download(fileKey string) (string, error)
+	fetch(fileKey string, localPath string) error
 }
 
 func newBase(model config.ModelConfig, archivePath string, storageConfig config.SubConfig) (base Base, err error) {
@@ -68,20 +90,113 @@ func newBase(model config.ModelConfig, archivePath string, storageConfig config.
 		archivePath: archivePath,
 		fileKeys:    keys,
 		viper:       storageConfig.Viper,
+		retry:       model.Retry,
 		cycler:      &Cycler{name: cyclerName},
 	}
 
 	if base.viper != nil {
+		base.retry, err = config.LoadRetryConfig(base.viper.Sub("retry"), model.Retry)
+		if err != nil {
+			return base, err
+		}
+
 		base.keep = base.viper.GetInt("keep")
+		base.repository = base.viper.GetBool("repository")
+
+		rate, err := helper.ParseRate(base.viper.GetString("rate_limit"))
+		if err != nil {
+			return base, err
+		}
+		base.rateLimiter = helper.NewRateLimiter(rate)
+		base.retention, err = newRetention(base.viper.Sub("retention"))
+		if err != nil {
+			return base, err
+		}
 	}
 
 	return
 }
 
-func new(model config.ModelConfig, archivePath string, storageConfig config.SubConfig) (Base, Storage) {
+var (
+	globalRateLimiter     *helper.RateLimiter
+	globalRateLimit       int64
+	globalRateLimiterLock sync.Mutex
+)
+
+// limiters return the rate limiters of the uploads, the storage `rate_limit` and the global `rate_limit`
+// shared by all uploads running at the same time.
+func (b Base) limiters() []*helper.RateLimiter {
+	globalRateLimiterLock.Lock()
+	defer globalRateLimiterLock.Unlock()
+
+	// Renew the global limiter when the config is reloaded
+	if globalRateLimit != config.RateLimit {
+		globalRateLimit = config.RateLimit
+		globalRateLimiter = helper.NewRateLimiter(globalRateLimit)
+	}
+
+	var limiters []*helper.RateLimiter
+	for _, l := range []*helper.RateLimiter{b.rateLimiter, globalRateLimiter} {
+		if l != nil {
+			limiters = append(limiters, l)
+		}
+	}
+	return limiters
+}
+
+// openStorage open the storage with retry
+func (b Base) openStorage(s Storage) error {
+	return b.retry.Do(logger.Tag("Storage"), "Open storage", s.open)
+}
+
+// deleteFunc return the delete of the storage with retry
+func (b Base) deleteFunc(s Storage) func(fileKey string) error {
+	return func(fileKey string) error {
+		return b.retry.Do(logger.Tag("Storage"), "Delete "+fileKey, func() error {
+			return s.delete(fileKey)
+		})
+	}
+}
+
+// uploadArchive upload the archive with retry, the split parts are uploaded one by one
+// so a retry resumes at the part that failed.
+func uploadArchive(base Base, s Storage, fileKey string) error {
+	logger := logger.Tag("Storage")
+
+	if len(base.fileKeys) == 0 {
+		return base.retry.Do(logger, "Upload "+fileKey, func() error {
+			return s.upload(fileKey)
+		})
+	}
+
+	ps, ok := s.(interface{ setFileKeys([]string) })
+	if !ok {
+		return fmt.Errorf("storage %T does not support split parts", s)
+	}
+	defer ps.setFileKeys(base.fileKeys)
+
+	for _, key := range base.fileKeys {
+		ps.setFileKeys([]string{key})
+		err := base.retry.Do(logger, "Upload "+key, func() error {
+			return s.upload(fileKey)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setFileKeys is used to upload the split parts one by one
+func (b *Base) setFileKeys(fileKeys []string) {
+	b.fileKeys = fileKeys
+}
+
+func new(model config.ModelConfig, archivePath string, storageConfig config.SubConfig) (Base, Storage, error) {
 	base, err := newBase(model, archivePath, storageConfig)
 	if err != nil {
-		panic(err)
+		return base, nil, err
 	}
 
 	var s Storage
@@ -103,55 +218,364 @@ func new(model config.ModelConfig, archivePath string, storageConfig config.SubC
 	case "azure":
 		s = &Azure{Base: base}
 	default:
-		logger.Errorf("[%s] storage type has not implement.", storageConfig.Type)
+		return base, nil, fmt.Errorf("[%s] storage type has not implement", storageConfig.Type)
 	}
 
-	return base, s
+	return base, s, nil
 }
 
 // run storage
 func runModel(model config.ModelConfig, archivePath string, storageConfig config.SubConfig) (err error) {
-	logger := logger.Tag("Storage")
+	logger := logger.Tag(fmt.Sprintf("Storage: %s", storageConfig.Name))
 
 	newFileKey := filepath.Base(archivePath)
-	base, s := new(model, archivePath, storageConfig)
+	base, s, err := new(model, archivePath, storageConfig)
+	if err != nil {
+		return err
+	}
 
 	logger.Info("=> Storage | " + storageConfig.Type)
-	err = s.open()
+	err = base.openStorage(s)
 	if err != nil {
 		return err
 	}
 	defer s.close()
 
-	err = s.upload(newFileKey)
+	base.cleanUploadStates(s)
+
+	if base.repository {
+		r, name, err := openArchive(base)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return runRepository(base, s, name, r)
+	}
+
+	err = uploadArchive(base, s, newFileKey)
 	if err != nil {
 		return err
 	}
 
-	base.cycler.run(newFileKey, base.fileKeys, base.keep, s.delete)
+	pkg := Package{FileKey: newFileKey, FileKeys: base.fileKeys}
+	if model.Viper != nil {
+		pkg.Checksum = model.Viper.GetString("Checksum")
+		pkg.MD5 = model.Viper.GetString("MD5")
+		pkg.Chain = model.Viper.GetString("ArchiveChain")
+		pkg.Level = model.Viper.GetInt("ArchiveLevel")
+	}
+	if err = uploadSidecar(base, s, pkg); err != nil {
+		return err
+	}
+
+	syncIfNeeded(base, s, "")
+	base.cycler.run(pkg, base.keep, base.retention, base.deleteFunc(s))
 	return nil
 }
 
-// Run storage
-func Run(model config.ModelConfig, archivePath string) (err error) {
+// Run storage, upload to at most `parallel_uploads` storages at the same time
+func Run(model config.ModelConfig, archivePath string) ([]history.Outcome, error) {
 	var errors []error
 
-	n := len(model.Storages)
+	var storageConfigs []config.SubConfig
 	for _, storageConfig := range model.Storages {
-		err := runModel(model, archivePath, storageConfig)
+		storageConfigs = append(storageConfigs, storageConfig)
+	}
+
+	outcomes := make([]history.Outcome, len(storageConfigs))
+	errs := helper.RunParallel(len(storageConfigs), model.ParallelUploads, false, func(i int) error {
+		startedAt := time.Now()
+		err := runModel(model, archivePath, storageConfigs[i])
+		outcomes[i] = history.NewOutcome(storageConfigs[i].Name, storageConfigs[i].Type, startedAt, err)
+		return err
+	})
+
+	n := len(model.Storages)
+	for _, err := range errs {
 		if err != nil {
 			if n == 1 {
-				return err
-			} else {
-				errors = append(errors, err)
-				continue
+				return outcomes, err
 			}
+			errors = append(errors, err)
 		}
 	}
 
 	if len(errors) != 0 {
-		return fmt.Errorf("Storage errors: %v", errors)
+		return outcomes, fmt.Errorf("Storage errors: %v", errors)
 	}
 
+	return outcomes, nil
+}
+
+func runModelStream(model config.ModelConfig, fileKey string, storageConfig config.SubConfig, r io.Reader, checksum func() (string, string)) (err error) {
+	logger := logger.Tag("Storage")
+
+	base, s, err := new(model, "", storageConfig)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("=> Storage | " + storageConfig.Type + " (stream)")
+	err = base.openStorage(s)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	if base.repository {
+		return runRepository(base, s, fileKey, r)
+	}
+
+	r = helper.LimitReader(r, base.limiters()...)
+
+	err = s.uploadStream(fileKey, r)
+	if err != nil {
+		// Remove the partial uploaded file
+		if err := s.delete(fileKey); err != nil {
+			logger.Debugf("Remove partial %s failed: %v", fileKey, err)
+		}
+		return err
+	}
+
+	// The stream has been fully read when the upload succeeded
+	pkg := Package{FileKey: fileKey}
+	pkg.Checksum, pkg.MD5 = checksum()
+	if err = uploadSidecar(base, s, pkg); err != nil {
+		return err
+	}
+
+	syncIfNeeded(base, s, "")
+	base.cycler.run(pkg, base.keep, base.retention, base.deleteFunc(s))
 	return nil
 }
+
+// RunStream upload the stream to all storages of the model at the same time,
+// a failed storage aborts the others since they share the same stream.
+func RunStream(model config.ModelConfig, fileKey string, r io.Reader) ([]history.Outcome, error) {
+	var pipes []*io.PipeWriter
+	var wg sync.WaitGroup
+
+	sha256Hash := sha256.New()
+	md5Hash := md5.New()
+	writers := []io.Writer{sha256Hash, md5Hash}
+	checksum := func() (string, string) {
+		return hex.EncodeToString(sha256Hash.Sum(nil)), hex.EncodeToString(md5Hash.Sum(nil))
+	}
+
+	errs := make([]error, len(model.Storages))
+	outcomes := make([]history.Outcome, len(model.Storages))
+	i := 0
+	for _, storageConfig := range model.Storages {
+		pr, pw := io.Pipe()
+		writers = append(writers, pw)
+		pipes = append(pipes, pw)
+
+		wg.Add(1)
+		go func(i int, storageConfig config.SubConfig) {
+			defer wg.Done()
+			startedAt := time.Now()
+			errs[i] = runModelStream(model, fileKey, storageConfig, pr, checksum)
+			outcomes[i] = history.NewOutcome(storageConfig.Name, storageConfig.Type, startedAt, errs[i])
+			if errs[i] != nil {
+				pr.CloseWithError(errs[i])
+			} else {
+				pr.Close()
+			}
+		}(i, storageConfig)
+		i++
+	}
+
+	_, err := io.Copy(io.MultiWriter(writers...), r)
+	for _, pw := range pipes {
+		pw.CloseWithError(err)
+	}
+	wg.Wait()
+
+	var errors []error
+	for _, err := range errs {
+		if err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	if len(errors) == 1 {
+		return outcomes, errors[0]
+	} else if len(errors) > 1 {
+		return outcomes, fmt.Errorf("Storage errors: %v", errors)
+	}
+
+	return outcomes, err
+}
+
+// Download the package `fileKey` from the default storage of the model into `targetDir`
+// and return the local archive path, the latest package in the cycler is used when
+// `fileKey` is empty. Split parts are joined back into one archive.
+func Download(model config.ModelConfig, fileKey string, targetDir string) (archivePath string, err error) {
+	logger := logger.Tag("Storage")
+
+	storageConfig, ok := model.Storages[model.DefaultStorage]
+	if !ok {
+		return "", fmt.Errorf("default storage %s not found in model %s", model.DefaultStorage, model.Name)
+	}
+
+	base, s, err := new(model, "", storageConfig)
+	if err != nil {
+		return "", err
+	}
+
+	logger.Info("=> Storage | " + storageConfig.Type)
+	if err = base.openStorage(s); err != nil {
+		return "", err
+	}
+	defer s.close()
+
+	syncIfNeeded(base, s, fileKey)
+	base.cycler.load(base.cycler.fileName())
+	pkg := base.cycler.find(fileKey)
+	if pkg == nil {
+		if len(fileKey) == 0 {
+			return "", fmt.Errorf("no backup found for %s in cycler", base.cycler.name)
+		}
+		// Not tracked by cycler, assume it is a single file
+		pkg = &Package{FileKey: fileKey}
+	}
+
+	localPath, err := fetchPackage(s, *pkg, targetDir)
+	if err != nil {
+		return "", err
+	}
+
+	if len(pkg.FileKeys) == 0 {
+		logger.Info("Downloaded", localPath)
+		return localPath, nil
+	}
+
+	archivePath = filepath.Join(targetDir, archiveName(*pkg))
+	if err := joinParts(targetDir, pkg.FileKeys, archivePath); err != nil {
+		return "", err
+	}
+	if err := os.RemoveAll(localPath); err != nil {
+		logger.Warnf("Remove %s failed: %v", localPath, err)
+	}
+	logger.Info("Downloaded", archivePath)
+
+	return archivePath, nil
+}
+
+// archiveName return the file name of the archive before split
+func archiveName(pkg Package) string {
+	if isSnapshot(pkg.FileKey) {
+		return strings.TrimSuffix(filepath.Base(pkg.FileKey), snapshotExt)
+	}
+	if len(pkg.FileKeys) == 0 {
+		return filepath.Base(pkg.FileKey)
+	}
+
+	// 2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000 -> 2022.12.04.07.09.47.tar.xz
+	return partSuffixRegexp.ReplaceAllString(filepath.Base(pkg.FileKeys[0]), "")
+}
+
+// uploadSidecar upload the `.sha256` sidecar of the package in `sha256sum` format
+func uploadSidecar(base Base, s Storage, pkg Package) error {
+	if len(pkg.Checksum) == 0 {
+		return nil
+	}
+
+	content := fmt.Sprintf("%s  %s\n", pkg.Checksum, archiveName(pkg))
+	err := base.retry.Do(logger.Tag("Storage"), "Upload "+sidecarKey(pkg.FileKey), func() error {
+		return s.uploadStream(sidecarKey(pkg.FileKey), strings.NewReader(content))
+	})
+	if err != nil {
+		return fmt.Errorf("upload checksum of %s failed: %v", pkg.FileKey, err)
+	}
+
+	return nil
+}
+
+// fetchPackage fetch all files of the package into `targetDir` with the same layout
+// as the storage, return the local path of the package (a directory for split parts).
+// The archive is rebuilt from the chunks for the snapshot in repository mode.
+func fetchPackage(s Storage, pkg Package, targetDir string) (string, error) {
+	logger := logger.Tag("Storage")
+
+	if isSnapshot(pkg.FileKey) {
+		return restoreSnapshot(s, pkg.FileKey, targetDir)
+	}
+
+	if len(pkg.FileKeys) == 0 {
+		localPath := filepath.Join(targetDir, pkg.FileKey)
+		logger.Info("-> Fetching", pkg.FileKey)
+		if err := fetchFile(s, pkg.FileKey, localPath); err != nil {
+			return "", fmt.Errorf("fetch %s failed: %v", pkg.FileKey, err)
+		}
+		return localPath, nil
+	}
+
+	for _, key := range pkg.FileKeys {
+		logger.Info("-> Fetching", key)
+		if err := fetchFile(s, key, filepath.Join(targetDir, key)); err != nil {
+			return "", fmt.Errorf("fetch %s failed: %v", key, err)
+		}
+	}
+
+	return filepath.Join(targetDir, strings.TrimSuffix(pkg.FileKey, "/")), nil
+}
+
+// joinParts concatenate the split parts in `dir` into `archivePath`
+func joinParts(dir string, fileKeys []string, archivePath string) error {
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, key := range fileKeys {
+		part, err := os.Open(filepath.Join(dir, key))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, part)
+		part.Close()
+		if err != nil {
+			return fmt.Errorf("join %s failed: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+// listPrefix return the prefix to list the objects under the directory `remotePath`
+func listPrefix(remotePath string) string {
+	remotePath = strings.Trim(remotePath, "/")
+	if len(remotePath) == 0 || remotePath == "." {
+		return ""
+	}
+	return remotePath + "/"
+}
+
+// relativeKey return the key relative to the storage path `root`, false if the key is not under `root`
+func relativeKey(root, key string) (string, bool) {
+	prefix := listPrefix(root)
+	key = strings.TrimPrefix(key, "/")
+	if !strings.HasPrefix(key, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(key, prefix), true
+}
+
+// fetchFile fetch the file of `fileKey` into `localPath`, the partial file is removed when the fetch fails
+func fetchFile(s Storage, fileKey string, localPath string) error {
+	if err := s.fetch(fileKey, localPath); err != nil {
+		os.Remove(localPath)
+		return err
+	}
+	return nil
+}
+
+// createLocalFile create the file and its parent directories for fetch
+func createLocalFile(localPath string) (*os.File, error) {
+	if err := helper.MkdirP(filepath.Dir(localPath)); err != nil {
+		return nil, err
+	}
+	return os.Create(localPath)
+}
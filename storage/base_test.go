@@ -1,10 +1,16 @@
 package storage
 
 import (
+	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
 
 	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/history"
 	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
 )
 
 func TestBase_newBase(t *testing.T) {
@@ -17,3 +23,110 @@ func TestBase_newBase(t *testing.T) {
 	assert.Equal(t, s.viper, model.Viper)
 	assert.Equal(t, s.keep, 0)
 }
+
+func TestNew_error(t *testing.T) {
+	v := viper.New()
+	v.Set("rate_limit", "fast")
+	_, s, err := new(config.ModelConfig{}, "", config.SubConfig{Name: "local", Type: "local", Viper: v})
+	assert.Nil(t, s)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid rate_limit "fast"`)
+
+	_, _, err = new(config.ModelConfig{}, "", config.SubConfig{Name: "foo", Type: "foo"})
+	assert.EqualError(t, err, "[foo] storage type has not implement")
+}
+
+func TestRun_parallel(t *testing.T) {
+	cyclerPath = t.TempDir()
+
+	archivePath := filepath.Join(t.TempDir(), "2022.12.04.07.09.47.tar.gz")
+	assert.NoError(t, os.WriteFile(archivePath, []byte("hello"), 0660))
+
+	model := config.ModelConfig{
+		Name:            "test",
+		Storages:        map[string]config.SubConfig{},
+		ParallelUploads: 2,
+	}
+	var paths []string
+	for _, name := range []string{"local1", "local2", "local3"} {
+		storageViper := viper.New()
+		storageViper.Set("path", t.TempDir())
+		paths = append(paths, storageViper.GetString("path"))
+		model.Storages[name] = config.SubConfig{Name: name, Type: "local", Viper: storageViper}
+	}
+	model.Storages["unknown"] = config.SubConfig{Name: "unknown", Type: "unknown"}
+
+	outcomes, err := Run(model, archivePath)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Storage errors")
+	assert.Len(t, outcomes, 4)
+	for _, outcome := range outcomes {
+		if outcome.Name == "unknown" {
+			assert.Equal(t, history.StatusFailed, outcome.Status)
+		} else {
+			assert.Equal(t, history.StatusFinished, outcome.Status)
+		}
+	}
+
+	// The other storages are uploaded regardless of the failed one
+	for _, p := range paths {
+		assert.True(t, helper.IsExistsPath(filepath.Join(p, "2022.12.04.07.09.47.tar.gz")))
+	}
+}
+
+type flakyLocal struct {
+	*Local
+	failures map[string]int
+	uploads  []string
+}
+
+func (s *flakyLocal) upload(fileKey string) error {
+	for _, key := range s.fileKeys {
+		s.uploads = append(s.uploads, key)
+		if s.failures[key] > 0 {
+			s.failures[key]--
+			return syscall.ECONNRESET
+		}
+	}
+	return s.Local.upload(fileKey)
+}
+
+func TestUploadArchive_retry(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "2022.12.04.07.09.47")
+	assert.NoError(t, os.MkdirAll(archivePath, 0750))
+	for _, name := range []string{"2022.12.04.07.09.47.tar.xz-000", "2022.12.04.07.09.47.tar.xz-001", "2022.12.04.07.09.47.tar.xz-002"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(archivePath, name), []byte(name), 0660))
+	}
+
+	storageViper := viper.New()
+	storageViper.Set("path", t.TempDir())
+	storageViper.Set("retry.attempts", 3)
+	storageViper.Set("retry.initial_delay", "1ms")
+	model := config.ModelConfig{Retry: helper.RetryConfig{Errors: []string{helper.RetryNetwork}}}
+
+	base, err := newBase(model, archivePath, config.SubConfig{Type: "local", Viper: storageViper})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, base.retry.Attempts)
+	assert.Equal(t, []string{helper.RetryNetwork}, base.retry.Errors)
+
+	local := &Local{Base: base}
+	assert.NoError(t, local.open())
+	s := &flakyLocal{Local: local, failures: map[string]int{"2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-001": 2}}
+
+	// The failed part is retried without uploading the parts before it again
+	assert.NoError(t, uploadArchive(base, s, "2022.12.04.07.09.47"))
+	assert.Equal(t, []string{
+		"2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000",
+		"2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-001",
+		"2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-001",
+		"2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-001",
+		"2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-002",
+	}, s.uploads)
+	for _, key := range base.fileKeys {
+		assert.True(t, helper.IsExistsPath(filepath.Join(local.path, key)))
+	}
+
+	// The attempts are used up
+	s.failures["2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-002"] = 3
+	assert.Equal(t, syscall.ECONNRESET, uploadArchive(base, s, "2022.12.04.07.09.47"))
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"io"
+	"math/bits"
+)
+
+const (
+	defaultChunkSize = 1 << 20
+)
+
+// gearTable is the random table of the gear hash, it must never change,
+// otherwise the chunks of the repositories are no longer deduplicated.
+var gearTable = func() (table [256]uint64) {
+	// splitmix64
+	seed := uint64(0x6c61756e63682d61)
+	for i := range table {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return
+}()
+
+// chunker split the stream into content-defined chunks with the gear rolling hash like FastCDC,
+// a boundary only depends on the bytes before it, so a change only affects the chunks around it.
+// The chunks are between 1/4 and 8 times the average size.
+type chunker struct {
+	r    io.Reader
+	buf  []byte
+	n    int
+	eof  bool
+	min  int
+	mask uint64
+}
+
+func newChunker(r io.Reader, avgSize int) *chunker {
+	if avgSize <= 0 {
+		avgSize = defaultChunkSize
+	}
+	maskBits := bits.Len(uint(avgSize)) - 1
+
+	return &chunker{
+		r:   r,
+		buf: make([]byte, avgSize*8),
+		min: avgSize / 4,
+		// The high bits of the gear hash are affected by more bytes
+		mask: (uint64(1)<<maskBits - 1) << (64 - maskBits),
+	}
+}
+
+// next return the next chunk, io.EOF when the stream is done
+func (c *chunker) next() ([]byte, error) {
+	for !c.eof && c.n < len(c.buf) {
+		n, err := c.r.Read(c.buf[c.n:])
+		c.n += n
+		if err == io.EOF {
+			c.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.n == 0 {
+		return nil, io.EOF
+	}
+
+	cut := c.boundary(c.buf[:c.n])
+	chunk := append([]byte(nil), c.buf[:cut]...)
+	c.n = copy(c.buf, c.buf[cut:c.n])
+
+	return chunk, nil
+}
+
+func (c *chunker) boundary(data []byte) int {
+	if len(data) <= c.min {
+		return len(data)
+	}
+
+	var hash uint64
+	for i := c.min; i < len(data); i++ {
+		hash = (hash << 1) + gearTable[data[i]]
+		if hash&c.mask == 0 {
+			return i + 1
+		}
+	}
+
+	return len(data)
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/longbridgeapp/assert"
+)
+
+func chunkAll(t *testing.T, data []byte, avgSize int) (chunks [][]byte) {
+	c := newChunker(bytes.NewReader(data), avgSize)
+	for {
+		chunk, err := c.next()
+		if err == io.EOF {
+			return
+		}
+		assert.NoError(t, err)
+		chunks = append(chunks, chunk)
+	}
+}
+
+func TestChunker(t *testing.T) {
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	chunks := chunkAll(t, data, 4096)
+	assert.Equal(t, data, bytes.Join(chunks, nil))
+	for _, chunk := range chunks[:len(chunks)-1] {
+		assert.True(t, len(chunk) >= 1024)
+		assert.True(t, len(chunk) <= 4096*8)
+	}
+
+	// Insert bytes at the beginning, only the chunks around it are changed
+	shifted := chunkAll(t, append([]byte("hello world"), data...), 4096)
+	known := map[string]bool{}
+	for _, chunk := range chunks {
+		known[string(chunk)] = true
+	}
+	var reused int
+	for _, chunk := range shifted {
+		if known[string(chunk)] {
+			reused++
+		}
+	}
+	assert.True(t, reused >= len(chunks)-2)
+
+	assert.Equal(t, 0, len(chunkAll(t, nil, 4096)))
+}
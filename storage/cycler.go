@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,9 +16,15 @@ import (
 type PackageList []Package
 
 // When `FileKeys` is not empty, `FileKey` is the directory
+// `Checksum` and `MD5` are of the whole archive before split, the SHA-256 is also stored in the `.sha256` sidecar
+// `Chain` and `Level` are of the incremental archive, a level N package needs the packages below it in the chain
 type Package struct {
 	FileKey   string    `json:"file_key"`
 	FileKeys  []string  `json:"file_keys,omitempty"`
+	Checksum  string    `json:"checksum,omitempty"`
+	MD5       string    `json:"md5,omitempty"`
+	Chain     string    `json:"chain,omitempty"`
+	Level     int       `json:"level,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -32,11 +39,17 @@ type Cycler struct {
 }
 
 func (c *Cycler) add(fileKey string, fileKeys []string) {
-	c.packages = append(c.packages, Package{
-		FileKey:   fileKey,
-		FileKeys:  fileKeys,
-		CreatedAt: time.Now(),
-	})
+	c.push(Package{FileKey: fileKey, FileKeys: fileKeys})
+}
+
+// push add the package, replace the package with the same file key, e.g. rebuilt from remote
+func (c *Cycler) push(pkg Package) {
+	pkg.CreatedAt = time.Now()
+	if existing := c.find(pkg.FileKey); len(pkg.FileKey) > 0 && existing != nil {
+		*existing = pkg
+		return
+	}
+	c.packages = append(c.packages, pkg)
 }
 
 func (c *Cycler) shiftByKeep(keep int) (first *Package) {
@@ -49,39 +62,170 @@ func (c *Cycler) shiftByKeep(keep int) (first *Package) {
 	return
 }
 
-func (c *Cycler) run(fileKey string, fileKeys []string, keep int, deletePackage func(fileKey string) error) {
+func (c *Cycler) run(pkg Package, keep int, retention Retention, deletePackage func(fileKey string) error) {
 	logger := logger.Tag("Cycler")
 
-	cyclerFileName := filepath.Join(cyclerPath, c.name+".json")
+	cyclerFileName := c.fileName()
 
 	c.load(cyclerFileName)
-	c.add(fileKey, fileKeys)
+	c.push(pkg)
 	defer c.save(cyclerFileName)
 
-	if keep == 0 {
+	kept, expired := c.packages, PackageList{}
+	if retention.enabled() {
+		kept, expired = retention.expired(c.packages, keep, time.Now())
+	} else if keep > 0 {
+		rest := Cycler{packages: append(PackageList{}, c.packages...)}
+		for {
+			pkg := rest.shiftByKeep(keep)
+			if pkg == nil {
+				break
+			}
+			expired = append(expired, *pkg)
+		}
+		kept = rest.packages
+	}
+
+	kept, expired = keepDependencies(kept, expired)
+
+	if retention.DryRun {
+		for _, pkg := range expired {
+			logger.Infof("Would remove %s created at %s (dry run)", pkg.FileKey, pkg.CreatedAt.Format(time.RFC3339))
+		}
 		return
 	}
 
-	for {
-		pkg := c.shiftByKeep(keep)
-		if pkg == nil {
+	c.packages = kept
+	for _, pkg := range expired {
+		c.removePackage(pkg, deletePackage)
+	}
+}
+
+// keepDependencies move the expired packages back to kept when a kept incremental package needs them,
+// which is the latest package before it in the same chain with a lower level, up to the full one.
+func keepDependencies(kept, expired PackageList) (PackageList, PackageList) {
+	for changed := true; changed; {
+		changed = false
+		for _, pkg := range kept {
+			if len(pkg.Chain) == 0 || pkg.Level == 0 {
+				continue
+			}
+
+			dependency := -1
+			for i, candidate := range expired {
+				if candidate.Chain != pkg.Chain || candidate.Level >= pkg.Level || !candidate.CreatedAt.Before(pkg.CreatedAt) {
+					continue
+				}
+				if dependency == -1 || candidate.CreatedAt.After(expired[dependency].CreatedAt) {
+					dependency = i
+				}
+			}
+			if dependency == -1 || kept.hasDependency(pkg, expired[dependency]) {
+				continue
+			}
+
+			kept = append(kept, expired[dependency])
+			expired = append(expired[:dependency:dependency], expired[dependency+1:]...)
+			changed = true
 			break
 		}
+	}
 
-		fk := pkg.FileKey
-		if len(pkg.FileKeys) != 0 && !strings.HasSuffix(fk, "/") {
-			fk += "/"
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].CreatedAt.Before(kept[j].CreatedAt)
+	})
+	return kept, expired
+}
+
+// hasDependency return true when a kept package is a newer dependency of `pkg` than `candidate`
+func (packages PackageList) hasDependency(pkg, candidate Package) bool {
+	for _, p := range packages {
+		if p.Chain == pkg.Chain && p.Level < pkg.Level && p.CreatedAt.Before(pkg.CreatedAt) && p.CreatedAt.After(candidate.CreatedAt) {
+			return true
 		}
-		for _, k := range append(pkg.FileKeys, fk) {
-			// deletePackage() should handle directory case which has `/` suffix
-			err := deletePackage(k)
-			if err != nil {
-				logger.Warnf("Remove %s failed: %v", k, err)
-			} else {
-				logger.Info("Removed", k)
-			}
+	}
+	return false
+}
+
+// removePackage delete all files of the package
+func (c *Cycler) removePackage(pkg Package, deletePackage func(fileKey string) error) {
+	logger := logger.Tag("Cycler")
+
+	fk := pkg.FileKey
+	if len(pkg.FileKeys) != 0 && !strings.HasSuffix(fk, "/") {
+		fk += "/"
+	}
+	keys := append(pkg.FileKeys, fk)
+	if len(pkg.Checksum) > 0 {
+		keys = append(keys, sidecarKey(pkg.FileKey))
+	}
+	for _, k := range keys {
+		// deletePackage() should handle directory case which has `/` suffix
+		err := deletePackage(k)
+		if err != nil {
+			logger.Warnf("Remove %s failed: %v", k, err)
+		} else {
+			logger.Info("Removed", k)
+		}
+	}
+}
+
+// find the package by fileKey, return the latest package when fileKey is empty
+func (c *Cycler) find(fileKey string) *Package {
+	if len(fileKey) == 0 {
+		if len(c.packages) == 0 {
+			return nil
+		}
+		return &c.packages[len(c.packages)-1]
+	}
+
+	// The snapshot in repository mode is also found by the archive name
+	fileKey = strings.TrimSuffix(fileKey, "/")
+	for i := range c.packages {
+		key := strings.TrimSuffix(c.packages[i].FileKey, "/")
+		if key == fileKey || key == fileKey+snapshotExt {
+			return &c.packages[i]
+		}
+	}
+
+	return nil
+}
+
+// sidecarKey return the key of the `.sha256` sidecar of the package
+func sidecarKey(fileKey string) string {
+	return strings.TrimSuffix(fileKey, "/") + ".sha256"
+}
+
+// PackageCounts return the number of the packages in the cycler of each storage of the model
+func PackageCounts(model config.ModelConfig) (map[string]int, error) {
+	counts := map[string]int{}
+	for name, storageConfig := range model.Storages {
+		base, err := newBase(model, "", storageConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := os.ReadFile(base.cycler.fileName())
+		if os.IsNotExist(err) {
+			counts[name] = 0
+			continue
 		}
+		if err != nil {
+			return nil, err
+		}
+
+		var packages PackageList
+		if err := json.Unmarshal(data, &packages); err != nil {
+			return nil, err
+		}
+		counts[name] = len(packages)
 	}
+
+	return counts, nil
+}
+
+func (c *Cycler) fileName() string {
+	return filepath.Join(cyclerPath, c.name+".json")
 }
 
 func (c *Cycler) load(cyclerFileName string) {
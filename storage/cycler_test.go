@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -43,3 +44,67 @@ func TestCycler_shiftByKeep(t *testing.T) {
 	assert.Equal(t, len(cycler.packages), 4)
 	assert.Nil(t, pkg)
 }
+
+func TestCycler_find(t *testing.T) {
+	cycler := Cycler{}
+	assert.Nil(t, cycler.find(""))
+
+	cycler.add("p1", []string{})
+	cycler.add("p2/", []string{"p2/p2.tar.gz-000", "p2/p2.tar.gz-001"})
+	cycler.add("p3", []string{})
+
+	assert.Equal(t, cycler.find("").FileKey, "p3")
+	assert.Equal(t, cycler.find("p1").FileKey, "p1")
+	assert.Len(t, cycler.find("p2").FileKeys, 2)
+	assert.Nil(t, cycler.find("p4"))
+
+	cycler.add("p5.tar.snapshot", []string{})
+	assert.Equal(t, cycler.find("p5.tar").FileKey, "p5.tar.snapshot")
+}
+
+func TestKeepDependencies(t *testing.T) {
+	now := time.Now()
+	chain := func(name, chain string, level, hours int) Package {
+		return Package{FileKey: name, Chain: chain, Level: level, CreatedAt: now.Add(time.Duration(hours) * time.Hour)}
+	}
+
+	// incremental: p4 needs p3, p2 and the full p1
+	kept, expired := keepDependencies(
+		PackageList{chain("p4", "c1", 3, 4), chain("p5", "c2", 0, 5)},
+		PackageList{chain("p1", "c1", 0, 1), chain("p2", "c1", 1, 2), chain("p3", "c1", 2, 3), chain("p0", "c0", 0, 0)},
+	)
+	assert.Equal(t, []string{"p1", "p2", "p3", "p4", "p5"}, fileKeys(kept))
+	assert.Equal(t, []string{"p0"}, fileKeys(expired))
+
+	// differential: p4 only needs the full p1
+	kept, expired = keepDependencies(
+		PackageList{chain("p4", "c1", 1, 4)},
+		PackageList{chain("p1", "c1", 0, 1), chain("p2", "c1", 1, 2), chain("p3", "c1", 1, 3)},
+	)
+	assert.Equal(t, []string{"p1", "p4"}, fileKeys(kept))
+	assert.Equal(t, []string{"p2", "p3"}, fileKeys(expired))
+}
+
+func TestCycler_run_incremental(t *testing.T) {
+	cyclerPath = t.TempDir()
+
+	var deleted []string
+	deleteFn := func(fileKey string) error {
+		deleted = append(deleted, fileKey)
+		return nil
+	}
+
+	for i, level := range []int{0, 1, 2, 0} {
+		cycler := Cycler{name: "test"}
+		cycler.run(Package{FileKey: fmt.Sprintf("p%d", i+1), Chain: fmt.Sprintf("c%d", i/3), Level: level}, 2, Retention{}, deleteFn)
+		time.Sleep(time.Millisecond)
+	}
+
+	// p3 needs p2 and p1
+	assert.Equal(t, 0, len(deleted))
+
+	cycler := Cycler{name: "test"}
+	cycler.run(Package{FileKey: "p5", Chain: "c1", Level: 1}, 2, Retention{}, deleteFn)
+	assert.Equal(t, []string{"p1", "p2", "p3"}, deleted)
+	assert.Equal(t, []string{"p4", "p5"}, fileKeys(cycler.packages))
+}
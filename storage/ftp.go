@@ -3,6 +3,7 @@ package storage
 import (
 	"crypto/tls"
 	"fmt"
+	"io"
 	"net/textproto"
 	"os"
 	"path"
@@ -10,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/jlaffaye/ftp"
 
 	"github.com/gigcodes/launch-util/helper"
@@ -40,6 +42,8 @@ type FTP struct {
 	skipVerifyTLSCert bool
 
 	client *ftp.ServerConn
+	// dirs created by mkdirAll
+	dirs map[string]bool
 }
 
 func (s *FTP) open() error {
@@ -116,6 +120,31 @@ func (s *FTP) mkdir(rpath string) error {
 	return nil
 }
 
+// mkdirAll create the directory and its parents under the storage path. MKD of the existing directory fails,
+// so the errors are ignored and the upload into the directory reports the missing one.
+func (s *FTP) mkdirAll(rpath string) {
+	if s.dirs == nil {
+		s.dirs = map[string]bool{}
+	}
+
+	rel, ok := relativeKey(s.path, rpath)
+	if !ok || len(rel) == 0 || rel == "." {
+		return
+	}
+
+	dir := s.path
+	for _, name := range strings.Split(rel, "/") {
+		dir = path.Join(dir, name)
+		if s.dirs[dir] {
+			continue
+		}
+		if err := s.client.MakeDir(dir); err != nil {
+			logger.Tag("FTP").Debugf("MakeDir %s: %v", dir, err)
+		}
+		s.dirs[dir] = true
+	}
+}
+
 func (s *FTP) upload(fileKey string) error {
 	logger := logger.Tag("FTP")
 	logger.Info("-> Uploading...")
@@ -126,8 +155,8 @@ func (s *FTP) upload(fileKey string) error {
 		// 2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000
 		fileKeys = s.fileKeys
 
-		remotePath := filepath.Join(s.path, fileKey)
-		remoteDir := filepath.Dir(remotePath)
+		// 2022.12.04.07.09.47
+		remoteDir := filepath.Join(s.path, fileKey)
 
 		// mkdir
 		if err := s.mkdir(remoteDir); err != nil {
@@ -149,17 +178,67 @@ func (s *FTP) upload(fileKey string) error {
 		}
 		defer f.Close()
 
-		progress := helper.NewProgressBar(logger, f)
-		if err := s.client.Stor(remotePath, progress.Reader); err != nil {
-			return progress.Errorf("upload failed %v", err)
+		if err := s.stor(f, remotePath); err != nil {
+			return err
 		}
-		progress.Done(remotePath)
 	}
 
 	logger.Info("Store succeeded")
 	return nil
 }
 
+// stor upload the file, the unfinished upload of the same file continues from the remote size by REST
+func (s *FTP) stor(f *os.File, remotePath string) error {
+	logger := logger.Tag("FTP")
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	state := s.loadUploadState(remotePath, info)
+	if state.resumed {
+		if size, err := s.client.FileSize(remotePath); err == nil && size <= info.Size() {
+			offset = size
+			logger.Infof("Resume upload of %s from %s", remotePath, humanize.Bytes(uint64(offset)))
+		}
+	}
+	if err := state.save(); err != nil {
+		return err
+	}
+
+	progress := helper.NewProgressBar(logger, f, s.limiters()...)
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		progress.Skip(offset)
+		err = s.client.StorFrom(remotePath, progress.Reader, uint64(offset))
+	} else {
+		err = s.client.Stor(remotePath, progress.Reader)
+	}
+	if err != nil {
+		return progress.Errorf("upload failed %v", err)
+	}
+	progress.Done(remotePath)
+
+	state.remove()
+	return nil
+}
+
+// abortUpload remove the partial remote file of the unfinished upload
+func (s *FTP) abortUpload(state *uploadState) error {
+	if err := s.client.Delete(state.RemotePath); err != nil {
+		// The file does not exist
+		if _, sizeErr := s.client.FileSize(state.RemotePath); sizeErr != nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
 func (s *FTP) delete(fileKey string) error {
 	logger := logger.Tag("FTP")
 	remotePath := path.Join(s.path, fileKey)
@@ -177,20 +256,26 @@ func (s *FTP) delete(fileKey string) error {
 func (s *FTP) list(parent string) ([]FileItem, error) {
 	remotePath := path.Join(s.path, parent)
 
-	entries, err := s.client.List(remotePath)
-	if err != nil {
-		return nil, err
-	}
-
 	var items []FileItem
-	for _, entry := range entries {
-		if entry.Type == ftp.EntryTypeFile {
-			items = append(items, FileItem{
-				Filename:     entry.Name,
-				Size:         int64(entry.Size),
-				LastModified: entry.Time,
-			})
+	walker := s.client.Walk(remotePath)
+	for walker.Next() {
+		entry := walker.Stat()
+		if entry.Type != ftp.EntryTypeFile {
+			continue
+		}
+
+		key, ok := relativeKey(s.path, walker.Path())
+		if !ok {
+			continue
 		}
+		items = append(items, FileItem{
+			Filename:     key,
+			Size:         int64(entry.Size),
+			LastModified: entry.Time,
+		})
+	}
+	if err := walker.Err(); err != nil {
+		return nil, err
 	}
 
 	return items, nil
@@ -200,3 +285,41 @@ func (s *FTP) list(parent string) ([]FileItem, error) {
 func (s *FTP) download(fileKey string) (string, error) {
 	return "", fmt.Errorf("FTP download is not supported")
 }
+
+func (s *FTP) fetch(fileKey string, localPath string) error {
+	remotePath := path.Join(s.path, fileKey)
+
+	resp, err := s.client.Retr(remotePath)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	f, err := createLocalFile(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, resp); err != nil {
+		return err
+	}
+	// The transfer aborted by the server is only reported with the reply after the data
+	return resp.Close()
+}
+
+func (s *FTP) uploadStream(fileKey string, r io.Reader) error {
+	logger := logger.Tag("FTP")
+
+	remotePath := path.Join(s.path, fileKey)
+	// The chunks and the locks of the repository are in sub directories
+	s.mkdirAll(path.Dir(remotePath))
+
+	logger.Info("-> Uploading (stream)...")
+	if err := s.client.Stor(remotePath, r); err != nil {
+		return fmt.Errorf("upload failed %v", err)
+	}
+	logger.Info("Store succeeded", remotePath)
+
+	return nil
+}
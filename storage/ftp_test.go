@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/longbridgeapp/assert"
+)
+
+// testFTPServer is an in-process FTP server of the files under `root`, the file of `aborted`
+// is sent in half before the transfer is aborted.
+type testFTPServer struct {
+	root    string
+	aborted string
+}
+
+func (srv *testFTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	c := textproto.NewConn(conn)
+	reply := func(code int, message string) {
+		_ = c.PrintfLine("%d %s", code, message)
+	}
+
+	var data net.Listener
+	defer func() {
+		if data != nil {
+			data.Close()
+		}
+	}()
+	// transfer over the data connection of the previous EPSV
+	transfer := func(fn func(dataConn net.Conn) error) {
+		if data == nil {
+			reply(ftp.StatusCanNotOpenDataConnection, "no data connection")
+			return
+		}
+		reply(ftp.StatusAboutToSend, "opening data connection")
+		dataConn, err := data.Accept()
+		if err != nil {
+			reply(ftp.StatusCanNotOpenDataConnection, err.Error())
+			return
+		}
+		err = fn(dataConn)
+		dataConn.Close()
+		if err != nil {
+			reply(ftp.StatusTransfertAborted, err.Error())
+			return
+		}
+		reply(ftp.StatusClosingDataConnection, "transfer complete")
+	}
+
+	reply(ftp.StatusReady, "ready")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(line, " ")
+		localPath := filepath.Join(srv.root, filepath.FromSlash(arg))
+
+		switch strings.ToUpper(command) {
+		case "USER":
+			reply(ftp.StatusUserOK, "password required")
+		case "PASS":
+			reply(ftp.StatusLoggedIn, "logged in")
+		case "TYPE":
+			reply(ftp.StatusCommandOK, "type set")
+		case "EPSV":
+			if data != nil {
+				data.Close()
+			}
+			if data, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				reply(ftp.StatusCanNotOpenDataConnection, err.Error())
+				continue
+			}
+			reply(ftp.StatusExtendedPassiveMode, fmt.Sprintf("Entering Extended Passive Mode (|||%d|)", data.Addr().(*net.TCPAddr).Port))
+		case "RETR":
+			content, err := os.ReadFile(localPath)
+			if err != nil {
+				reply(ftp.StatusFileUnavailable, err.Error())
+				continue
+			}
+			transfer(func(dataConn net.Conn) error {
+				if arg == srv.aborted {
+					_, _ = dataConn.Write(content[:len(content)/2])
+					return fmt.Errorf("transfer aborted")
+				}
+				_, err := dataConn.Write(content)
+				return err
+			})
+		case "STOR":
+			f, err := os.Create(localPath)
+			if err != nil {
+				reply(ftp.StatusFileUnavailable, err.Error())
+				continue
+			}
+			transfer(func(dataConn net.Conn) error {
+				_, err := io.Copy(f, dataConn)
+				return err
+			})
+			f.Close()
+		case "LIST":
+			entries, err := os.ReadDir(localPath)
+			if err != nil {
+				reply(ftp.StatusFileUnavailable, err.Error())
+				continue
+			}
+			transfer(func(dataConn net.Conn) error {
+				for _, entry := range entries {
+					info, err := entry.Info()
+					if err != nil {
+						return err
+					}
+					kind := "-"
+					if info.IsDir() {
+						kind = "d"
+					}
+					// -rw-r--r-- 1 owner group 11 Dec 04 2022 foo.tar.gz
+					if _, err := fmt.Fprintf(dataConn, "%srw-r--r-- 1 owner group %d %s %s\r\n", kind, info.Size(), info.ModTime().Format("Jan 02 2006"), entry.Name()); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		case "SIZE":
+			info, err := os.Stat(localPath)
+			if err != nil {
+				reply(ftp.StatusFileUnavailable, err.Error())
+				continue
+			}
+			reply(ftp.StatusFile, fmt.Sprint(info.Size()))
+		case "MKD":
+			if err := os.Mkdir(localPath, 0750); err != nil {
+				reply(ftp.StatusFileUnavailable, err.Error())
+				continue
+			}
+			reply(ftp.StatusPathCreated, fmt.Sprintf("%q created", arg))
+		case "DELE", "RMD":
+			if err := os.Remove(localPath); err != nil {
+				reply(ftp.StatusFileUnavailable, err.Error())
+				continue
+			}
+			reply(ftp.StatusRequestedFileActionOK, "removed")
+		case "QUIT":
+			reply(ftp.StatusClosing, "bye")
+			return
+		default:
+			reply(ftp.StatusNotImplemented, "not implemented")
+		}
+	}
+}
+
+// newTestFTPClient connect a client to an in-process FTP server
+func newTestFTPClient(t *testing.T, srv *testFTPServer) *ftp.ServerConn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+
+	client, err := ftp.Dial(listener.Addr().String())
+	assert.NoError(t, err)
+	assert.NoError(t, client.Login("launch", "secret"))
+	t.Cleanup(func() { _ = client.Quit() })
+
+	return client
+}
+
+func TestFTP_fetch(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "backups"), 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "backups", "foo.tar.gz"), []byte("hello world"), 0660))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "backups", "bar.tar.gz"), []byte("hello world"), 0660))
+
+	s := &FTP{
+		path:   "/backups",
+		client: newTestFTPClient(t, &testFTPServer{root: root, aborted: "/backups/bar.tar.gz"}),
+	}
+
+	localPath := filepath.Join(t.TempDir(), "foo.tar.gz")
+	assert.NoError(t, fetchFile(s, "foo.tar.gz", localPath))
+
+	data, err := os.ReadFile(localPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+
+	// The partial file of the aborted transfer is removed
+	localPath = filepath.Join(t.TempDir(), "bar.tar.gz")
+	assert.Error(t, fetchFile(s, "bar.tar.gz", localPath))
+	_, err = os.Stat(localPath)
+	assert.True(t, os.IsNotExist(err))
+
+	localPath = filepath.Join(t.TempDir(), "not-found.tar.gz")
+	assert.Error(t, fetchFile(s, "not-found.tar.gz", localPath))
+	_, err = os.Stat(localPath)
+	assert.True(t, os.IsNotExist(err))
+}
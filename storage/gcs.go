@@ -1,18 +1,24 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/dustin/go-humanize"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 
 	"github.com/gigcodes/launch-util/helper"
 	"github.com/gigcodes/launch-util/logger"
@@ -28,12 +34,18 @@ import (
 // timeout: 300
 type GCS struct {
 	Base
-	bucket  string
-	path    string
-	timeout time.Duration
-	client  *storage.Client
+	bucket     string
+	path       string
+	timeout    time.Duration
+	client     *storage.Client
+	httpClient *http.Client
+	endpoint   string
 }
 
+// Files larger than the chunk size are uploaded in a resumable session which is resumed after a failure,
+// the chunk size must be a multiple of 256 KiB.
+var gcsChunkSize int64 = 16 * 1024 * 1024
+
 func (s *GCS) open() (err error) {
 	// https://cloud.google.com/storage/docs/locations
 	s.viper.SetDefault("timeout", "300")
@@ -70,6 +82,13 @@ func (s *GCS) open() (err error) {
 		return err
 	}
 
+	// The resumable upload session is not exposed by the client, use the JSON API directly
+	s.endpoint = "https://storage.googleapis.com"
+	s.httpClient, _, err = htransport.NewClient(ctx, opt, option.WithScopes(storage.ScopeReadWrite))
+	if err != nil {
+		return err
+	}
+
 	return
 }
 
@@ -111,7 +130,15 @@ func (s *GCS) upload(fileKey string) (err error) {
 		}
 		defer f.Close()
 
-		progress := helper.NewProgressBar(logger, f)
+		progress := helper.NewProgressBar(logger, f, s.limiters()...)
+		if progress.FileLength > gcsChunkSize {
+			if err := s.uploadResumable(ctx, f, progress, remotePath); err != nil {
+				return progress.Errorf("GCS upload error: %v", err)
+			}
+			progress.Done(remotePath)
+			continue
+		}
+
 		object := s.client.Bucket(s.bucket).Object(remotePath).If(storage.Conditions{DoesNotExist: true})
 		writer := object.NewWriter(ctx)
 
@@ -127,6 +154,162 @@ func (s *GCS) upload(fileKey string) (err error) {
 	return nil
 }
 
+// uploadResumable upload the file in a resumable session, the session URI is kept in the upload state,
+// so the upload continues from the last committed byte after a failure.
+// https://cloud.google.com/storage/docs/performing-resumable-uploads
+func (s *GCS) uploadResumable(ctx context.Context, f *os.File, progress helper.ProgressBar, remotePath string) error {
+	logger := logger.Tag("GCS")
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	var offset int64
+	state := s.loadUploadState(remotePath, info)
+	if state.resumed && len(state.SessionURI) > 0 {
+		var done bool
+		offset, done, err = s.putChunk(ctx, state.SessionURI, nil, 0, size)
+		if err != nil {
+			logger.Warnf("Resume upload of %s failed, start over: %v", remotePath, err)
+			state.SessionURI = ""
+			offset = 0
+		} else if done {
+			state.remove()
+			return nil
+		} else {
+			logger.Infof("Resume upload of %s from %s", remotePath, humanize.Bytes(uint64(offset)))
+		}
+	} else {
+		state.SessionURI = ""
+	}
+
+	if len(state.SessionURI) == 0 {
+		if state.SessionURI, err = s.startSession(ctx, remotePath); err != nil {
+			return err
+		}
+	}
+	if err := state.save(); err != nil {
+		return err
+	}
+
+	progress.Skip(offset)
+	buf := make([]byte, gcsChunkSize)
+	for offset < size {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		n := min(gcsChunkSize, size-offset)
+		if _, err := io.ReadFull(progress.Reader, buf[:n]); err != nil {
+			return err
+		}
+
+		next, done, err := s.putChunk(ctx, state.SessionURI, buf[:n], offset, size)
+		if err != nil {
+			return err
+		}
+		if done {
+			break
+		}
+		// Not all bytes of the chunk may be committed, send them again
+		progress.Skip(next - offset - n)
+		offset = next
+	}
+
+	state.remove()
+	return nil
+}
+
+// startSession start a resumable upload session and return the session URI
+func (s *GCS) startSession(ctx context.Context, remotePath string) (string, error) {
+	query := url.Values{}
+	query.Set("uploadType", "resumable")
+	query.Set("name", remotePath)
+	query.Set("ifGenerationMatch", "0")
+	uploadURL := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", s.endpoint, url.PathEscape(s.bucket), query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("start resumable upload failed, status: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	return resp.Header.Get("Location"), nil
+}
+
+// putChunk upload the chunk at `offset` of the object, an empty chunk queries the status of the session.
+// Return the offset of the next byte to upload, or true when the upload is done.
+func (s *GCS) putChunk(ctx context.Context, sessionURI string, chunk []byte, offset, size int64) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, sessionURI, bytes.NewReader(chunk))
+	if err != nil {
+		return 0, false, err
+	}
+	if len(chunk) == 0 {
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+	} else {
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(len(chunk))-1, size))
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return size, true, nil
+	case http.StatusPermanentRedirect:
+		// Range: bytes=0-1048575, no Range when nothing is committed
+		var last int64 = -1
+		if r := resp.Header.Get("Range"); len(r) > 0 {
+			if _, err := fmt.Sscanf(r, "bytes=0-%d", &last); err != nil {
+				return 0, false, fmt.Errorf("invalid Range %q of the resumable upload", r)
+			}
+		}
+		return last + 1, false, nil
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	return 0, false, fmt.Errorf("resumable upload failed, status: %d, body: %s", resp.StatusCode, string(body))
+}
+
+// abortUpload cancel the resumable upload session of the state, the expired session is gone already
+func (s *GCS) abortUpload(state *uploadState) error {
+	if len(state.SessionURI) == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, state.SessionURI, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 499 Client Closed Request when the session is cancelled
+	switch resp.StatusCode {
+	case 499, http.StatusNotFound, http.StatusGone:
+		return nil
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	return fmt.Errorf("cancel resumable upload failed, status: %d, body: %s", resp.StatusCode, string(body))
+}
+
 func (s *GCS) delete(fileKey string) (err error) {
 	// No need to remove empty directory
 	if !strings.HasSuffix(fileKey, "/") {
@@ -143,7 +326,7 @@ func (s *GCS) delete(fileKey string) (err error) {
 // List all files in the bucket
 func (s *GCS) list(parent string) ([]FileItem, error) {
 	var files []FileItem
-	remotePath := filepath.Join(s.path, parent)
+	remotePath := listPrefix(filepath.Join(s.path, parent))
 
 	it := s.client.Bucket(s.bucket).Objects(context.Background(), &storage.Query{Prefix: remotePath})
 	for {
@@ -155,8 +338,12 @@ func (s *GCS) list(parent string) ([]FileItem, error) {
 			return nil, err
 		}
 
+		key, ok := relativeKey(s.path, attrs.Name)
+		if !ok {
+			continue
+		}
 		file := FileItem{
-			Filename:     attrs.Name,
+			Filename:     key,
 			Size:         attrs.Size,
 			LastModified: attrs.Created,
 		}
@@ -169,7 +356,78 @@ func (s *GCS) list(parent string) ([]FileItem, error) {
 
 // Generate a sign URL for download
 func (s *GCS) download(fileKey string) (string, error) {
-	return s.client.Bucket(s.bucket).SignedURL(fileKey, &storage.SignedURLOptions{
+	remotePath := filepath.Join(s.path, fileKey)
+	return s.client.Bucket(s.bucket).SignedURL(remotePath, &storage.SignedURLOptions{
 		Expires: time.Now().Add(time.Hour * 1),
 	})
 }
+
+func (s *GCS) fetch(fileKey string, localPath string) error {
+	remotePath := filepath.Join(s.path, fileKey)
+
+	var ctx = context.Background()
+	var cancel context.CancelFunc
+	if s.timeout.Seconds() > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	reader, err := s.client.Bucket(s.bucket).Object(remotePath).NewReader(ctx)
+	if err != nil {
+		return fmt.Errorf("GCS failed to read file %q, %v", remotePath, err)
+	}
+	defer reader.Close()
+
+	f, err := createLocalFile(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, reader); err != nil {
+		return fmt.Errorf("GCS download error: %v", err)
+	}
+
+	return nil
+}
+
+func (s *GCS) uploadStream(fileKey string, r io.Reader) error {
+	logger := logger.Tag("GCS")
+
+	// Cancel the context to abort the upload on error, otherwise Close() commits a partial object
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if s.timeout.Seconds() > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), s.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+
+	remotePath := filepath.Join(s.path, fileKey)
+	object := s.client.Bucket(s.bucket).Object(remotePath).If(storage.Conditions{DoesNotExist: true})
+	writer := object.NewWriter(ctx)
+
+	logger.Info("-> Uploading (stream)...")
+	if _, err := io.Copy(writer, r); err != nil {
+		cancel()
+		return fmt.Errorf("GCS upload error: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("GCS upload Writer.Close: %v", err)
+	}
+	logger.Info("Uploaded:", remotePath)
+
+	return nil
+}
+
+func (s *GCS) remoteMD5(fileKey string) (string, error) {
+	ctx := context.Background()
+	remotePath := filepath.Join(s.path, fileKey)
+	attrs, err := s.client.Bucket(s.bucket).Object(remotePath).Attrs(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(attrs.MD5), nil
+}
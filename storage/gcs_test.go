@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/longbridgeapp/assert"
+
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/logger"
+)
+
+func TestGCS_uploadResumable(t *testing.T) {
+	uploadsPath = t.TempDir()
+	chunkSize := gcsChunkSize
+	gcsChunkSize = 4
+	defer func() { gcsChunkSize = chunkSize }()
+
+	localPath := filepath.Join(t.TempDir(), "foo.tar.gz")
+	assert.NoError(t, os.WriteFile(localPath, []byte("hello world"), 0660))
+
+	var sessions int
+	var received []byte
+	var failed bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			assert.Equal(t, "/upload/storage/v1/b/bucket/o", r.URL.Path)
+			assert.Equal(t, "backups/foo.tar.gz", r.URL.Query().Get("name"))
+			sessions++
+			w.Header().Set("Location", "http://"+r.Host+"/session")
+			return
+		}
+
+		var start, end, total int64
+		body, _ := io.ReadAll(r.Body)
+		if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err == nil {
+			// The second chunk fails once
+			if start == 4 && !failed {
+				failed = true
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			assert.Equal(t, int64(len(received)), start)
+			received = append(received, body...)
+		}
+
+		if int64(len(received)) == 11 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if len(received) > 0 {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(received)-1))
+		}
+		w.WriteHeader(http.StatusPermanentRedirect)
+	}))
+	defer server.Close()
+
+	s := &GCS{
+		Base:       Base{cycler: &Cycler{name: "test_gcs"}},
+		bucket:     "bucket",
+		endpoint:   server.URL,
+		httpClient: server.Client(),
+	}
+
+	upload := func() error {
+		f, err := os.Open(localPath)
+		assert.NoError(t, err)
+		defer f.Close()
+		return s.uploadResumable(context.Background(), f, helper.NewProgressBar(logger.Tag("GCS"), f), "backups/foo.tar.gz")
+	}
+
+	assert.Error(t, upload())
+	assert.NoError(t, upload())
+
+	assert.Equal(t, 1, sessions)
+	assert.Equal(t, "hello world", string(received))
+}
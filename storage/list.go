@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/logger"
+)
+
+// Backup is a backup of the model, merged from the remote files and the cycler of the storages
+type Backup struct {
+	FileKey   string    `json:"file_key"`
+	FileKeys  []string  `json:"file_keys,omitempty"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"checksum,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Names of the storages which hold a copy
+	Storages []string `json:"storages"`
+}
+
+// List the backups of the model in all storages, or only in `storageName` when it is not empty,
+// the newest backup first.
+func List(model config.ModelConfig, storageName string) ([]Backup, error) {
+	var names []string
+	for name := range model.Storages {
+		if len(storageName) == 0 || name == storageName {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("storage %s not found in model %s", storageName, model.Name)
+	}
+	sort.Strings(names)
+
+	var backups []Backup
+	index := map[string]int{}
+	var errors []error
+
+	for _, name := range names {
+		packages, sizes, err := listStorage(model, model.Storages[name])
+		if err != nil {
+			errors = append(errors, fmt.Errorf("[%s] %v", name, err))
+			continue
+		}
+
+		for _, pkg := range packages {
+			key := strings.TrimSuffix(pkg.FileKey, "/")
+			i, ok := index[key]
+			if !ok {
+				i = len(backups)
+				index[key] = i
+				backups = append(backups, Backup{
+					FileKey:   key,
+					FileKeys:  pkg.FileKeys,
+					Size:      sizes[key],
+					Checksum:  pkg.Checksum,
+					CreatedAt: pkg.CreatedAt,
+				})
+			}
+			backups[i].Storages = append(backups[i].Storages, name)
+			if len(backups[i].Checksum) == 0 {
+				backups[i].Checksum = pkg.Checksum
+			}
+		}
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+
+	if len(errors) != 0 {
+		return backups, fmt.Errorf("List errors: %v", errors)
+	}
+
+	return backups, nil
+}
+
+// listStorage return the packages in the storage with the metadata from the cycler, and the size of each package.
+// The cycler state is used when the storage does not support list, e.g. SCP.
+func listStorage(model config.ModelConfig, storageConfig config.SubConfig) (PackageList, map[string]int64, error) {
+	logger := logger.Tag("Storage")
+
+	base, s, err := new(model, "", storageConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Do not create an empty state here, which stops rebuilding the cycler from remote
+	var state PackageList
+	if cyclerFileName := base.cycler.fileName(); helper.IsExistsPath(cyclerFileName) {
+		base.cycler.load(cyclerFileName)
+		state = base.cycler.packages
+	}
+
+	if err := base.openStorage(s); err != nil {
+		return nil, nil, err
+	}
+	defer s.close()
+
+	sizes := map[string]int64{}
+	items, err := s.list("")
+	if err != nil {
+		logger.Warnf("List %s failed, use the cycler state: %v", storageConfig.Name, err)
+		return state, sizes, nil
+	}
+
+	for _, item := range items {
+		// 2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000 -> 2022.12.04.07.09.47
+		key := strings.SplitN(item.Filename, "/", 2)[0]
+		sizes[key] += item.Size
+	}
+
+	packages, _, _, _ := reconcile(state, items)
+	return packages, sizes, nil
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
+)
+
+func TestList(t *testing.T) {
+	cyclerPath = t.TempDir()
+
+	model := config.ModelConfig{
+		Name:     "test",
+		Storages: map[string]config.SubConfig{},
+	}
+	paths := map[string]string{}
+	for _, name := range []string{"local1", "local2"} {
+		paths[name] = t.TempDir()
+		storageViper := viper.New()
+		storageViper.Set("path", paths[name])
+		model.Storages[name] = config.SubConfig{Name: name, Type: "local", Viper: storageViper}
+	}
+
+	for _, name := range []string{"local1", "local2"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(paths[name], "2022.12.04.07.09.47.tar.xz"), []byte("hello"), 0660))
+	}
+	assert.NoError(t, os.MkdirAll(filepath.Join(paths["local1"], "2022.12.04.07.09.48"), 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(paths["local1"], "2022.12.04.07.09.48/2022.12.04.07.09.48.tar.xz-000"), []byte("hello "), 0660))
+	assert.NoError(t, os.WriteFile(filepath.Join(paths["local1"], "2022.12.04.07.09.48/2022.12.04.07.09.48.tar.xz-001"), []byte("world"), 0660))
+
+	cycler := Cycler{name: "test_local1"}
+	cycler.load(cycler.fileName())
+	cycler.push(Package{FileKey: "2022.12.04.07.09.47.tar.xz", Checksum: "abc"})
+	cycler.save(cycler.fileName())
+
+	backups, err := List(model, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(backups))
+
+	// Created at is taken from the cycler
+	assert.Equal(t, "2022.12.04.07.09.47.tar.xz", backups[0].FileKey)
+	assert.Equal(t, int64(5), backups[0].Size)
+	assert.Equal(t, "abc", backups[0].Checksum)
+	assert.Equal(t, []string{"local1", "local2"}, backups[0].Storages)
+
+	assert.Equal(t, "2022.12.04.07.09.48", backups[1].FileKey)
+	assert.Equal(t, int64(11), backups[1].Size)
+	assert.Equal(t, 2, len(backups[1].FileKeys))
+	assert.Equal(t, []string{"local1"}, backups[1].Storages)
+
+	backups, err = List(model, "local2")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(backups))
+
+	_, err = List(model, "not-found")
+	assert.Error(t, err)
+}
@@ -2,7 +2,7 @@ package storage
 
 import (
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -22,6 +22,12 @@ type Local struct {
 
 func (s *Local) open() error {
 	s.path = s.viper.GetString("path")
+
+	// Related path
+	if !path.IsAbs(s.path) {
+		s.path = path.Join(s.model.WorkDir, s.path)
+	}
+
 	return helper.MkdirP(s.path)
 }
 
@@ -30,18 +36,18 @@ func (s *Local) close() {}
 func (s *Local) upload(fileKey string) (err error) {
 	logger := logger.Tag("Local")
 
-	// Related path
-	if !path.IsAbs(s.path) {
-		s.path = path.Join(s.model.WorkDir, s.path)
-	}
-
 	targetPath := path.Join(s.path, fileKey)
 	targetDir := path.Dir(targetPath)
 	if err := helper.MkdirP(targetDir); err != nil {
 		logger.Errorf("failed to mkdir %q, %v", targetDir, err)
 	}
 
-	_, err = helper.Exec("cp", "-a", s.archivePath, targetPath)
+	// Copy the split parts one by one, only the parts in `fileKeys` are copied on retry
+	if limiters := s.limiters(); len(limiters) > 0 || len(s.fileKeys) > 0 {
+		err = s.copyFiles(fileKey, limiters)
+	} else {
+		_, err = helper.Exec("cp", "-a", s.archivePath, targetPath)
+	}
 	if err != nil {
 		return err
 	}
@@ -49,6 +55,38 @@ func (s *Local) upload(fileKey string) (err error) {
 	return nil
 }
 
+// copyFiles copy the archive or the split parts with the rate limit instead of `cp`
+func (s *Local) copyFiles(fileKey string, limiters []*helper.RateLimiter) error {
+	keys := s.fileKeys
+	if len(keys) == 0 {
+		keys = []string{fileKey}
+	}
+
+	for _, key := range keys {
+		src, err := os.Open(filepath.Join(filepath.Dir(s.archivePath), key))
+		if err != nil {
+			return err
+		}
+
+		dst, err := createLocalFile(filepath.Join(s.path, key))
+		if err != nil {
+			src.Close()
+			return err
+		}
+
+		_, err = io.Copy(dst, helper.LimitReader(src, limiters...))
+		src.Close()
+		if closeErr := dst.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *Local) delete(fileKey string) (err error) {
 	targetPath := filepath.Join(s.path, fileKey)
 	logger.Info("Deleting", targetPath)
@@ -61,19 +99,27 @@ func (s *Local) list(parent string) ([]FileItem, error) {
 	remotePath := filepath.Join(s.path, parent)
 	var items = []FileItem{}
 
-	files, err := ioutil.ReadDir(remotePath)
-	if err != nil {
-		return nil, err
-	}
+	err := filepath.Walk(remotePath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
 
-	for _, file := range files {
-		if !file.IsDir() {
-			items = append(items, FileItem{
-				Filename:     file.Name(),
-				Size:         file.Size(),
-				LastModified: file.ModTime(),
-			})
+		key, err := filepath.Rel(s.path, filePath)
+		if err != nil {
+			return err
 		}
+		items = append(items, FileItem{
+			Filename:     filepath.ToSlash(key),
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return items, nil
@@ -82,3 +128,39 @@ func (s *Local) list(parent string) ([]FileItem, error) {
 func (s *Local) download(fileKey string) (string, error) {
 	return "", fmt.Errorf("Local is not support download")
 }
+
+func (s *Local) fetch(fileKey string, localPath string) error {
+	sourcePath := filepath.Join(s.path, fileKey)
+
+	src, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := createLocalFile(localPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+func (s *Local) uploadStream(fileKey string, r io.Reader) error {
+	logger := logger.Tag("Local")
+
+	targetPath := path.Join(s.path, fileKey)
+	f, err := createLocalFile(targetPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	logger.Info("Store succeeded", targetPath)
+	return nil
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
+)
+
+func newTestLocal(t *testing.T) *Local {
+	viper := viper.New()
+	viper.Set("path", t.TempDir())
+
+	base, err := newBase(config.ModelConfig{}, "", config.SubConfig{Type: "local", Viper: viper})
+	assert.NoError(t, err)
+
+	s := &Local{Base: base}
+	assert.NoError(t, s.open())
+	return s
+}
+
+func TestLocal_fetch(t *testing.T) {
+	s := newTestLocal(t)
+	assert.NoError(t, os.WriteFile(filepath.Join(s.path, "foo.tar.gz"), []byte("hello"), 0660))
+
+	localPath := filepath.Join(t.TempDir(), "restore", "foo.tar.gz")
+	assert.NoError(t, s.fetch("foo.tar.gz", localPath))
+
+	data, err := os.ReadFile(localPath)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), "hello")
+
+	assert.Error(t, s.fetch("not-found.tar.gz", localPath))
+}
+
+func TestDownload(t *testing.T) {
+	cyclerPath = t.TempDir()
+
+	storagePath := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(storagePath, "2022.12.04.07.09.47"), 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(storagePath, "2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000"), []byte("hello "), 0660))
+	assert.NoError(t, os.WriteFile(filepath.Join(storagePath, "2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-001"), []byte("world"), 0660))
+
+	viper := viper.New()
+	viper.Set("path", storagePath)
+	model := config.ModelConfig{
+		Name:           "test",
+		DefaultStorage: "local",
+		Storages: map[string]config.SubConfig{
+			"local": {Name: "local", Type: "local", Viper: viper},
+		},
+	}
+
+	cycler := Cycler{name: "test_local"}
+	cycler.load(cycler.fileName())
+	cycler.add("2022.12.04.07.09.47/", []string{
+		"2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000",
+		"2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-001",
+	})
+	cycler.save(cycler.fileName())
+
+	targetDir := t.TempDir()
+	archivePath, err := Download(model, "", targetDir)
+	assert.NoError(t, err)
+	assert.Equal(t, archivePath, filepath.Join(targetDir, "2022.12.04.07.09.47.tar.xz"))
+
+	data, err := os.ReadFile(archivePath)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), "hello world")
+	assert.False(t, helper.IsExistsPath(filepath.Join(targetDir, "2022.12.04.07.09.47")))
+}
+
+func TestLocal_uploadParts(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "2022.12.04.07.09.47")
+	assert.NoError(t, os.MkdirAll(archivePath, 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(archivePath, "2022.12.04.07.09.47.tar.xz-000"), []byte("hello "), 0660))
+	assert.NoError(t, os.WriteFile(filepath.Join(archivePath, "2022.12.04.07.09.47.tar.xz-001"), []byte("world"), 0660))
+
+	viper := viper.New()
+	viper.Set("path", t.TempDir())
+
+	base, err := newBase(config.ModelConfig{}, archivePath, config.SubConfig{Type: "local", Viper: viper})
+	assert.NoError(t, err)
+	assert.Equal(t, base.fileKeys, []string{
+		"2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000",
+		"2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-001",
+	})
+
+	s := &Local{Base: base}
+	assert.NoError(t, s.open())
+	assert.NoError(t, s.upload("2022.12.04.07.09.47"))
+
+	for _, key := range base.fileKeys {
+		assert.True(t, helper.IsExistsPath(filepath.Join(s.path, key)))
+	}
+
+	// Cycler removes all parts and the directory together
+	cycler := Cycler{}
+	cycler.add("2022.12.04.07.09.47", base.fileKeys)
+	cycler.add("2022.12.04.07.09.48.tar.xz", nil)
+	pkg := cycler.shiftByKeep(1)
+	for _, key := range append(pkg.FileKeys, pkg.FileKey+"/") {
+		assert.NoError(t, s.delete(key))
+	}
+	assert.False(t, helper.IsExistsPath(filepath.Join(s.path, "2022.12.04.07.09.47")))
+}
+
+func TestLocal_upload_rateLimit(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "2022.12.04.07.09.47.tar.gz")
+	assert.NoError(t, os.WriteFile(archivePath, make([]byte, 20000), 0660))
+
+	viper := viper.New()
+	viper.Set("path", t.TempDir())
+	viper.Set("rate_limit", "10KB/s")
+
+	base, err := newBase(config.ModelConfig{}, archivePath, config.SubConfig{Type: "local", Viper: viper})
+	assert.NoError(t, err)
+	assert.Len(t, base.limiters(), 1)
+
+	s := &Local{Base: base}
+	assert.NoError(t, s.open())
+
+	// The burst is 10KB, the rest takes about 1 second
+	start := time.Now()
+	assert.NoError(t, s.upload("2022.12.04.07.09.47.tar.gz"))
+	assert.True(t, time.Since(start) > 900*time.Millisecond)
+
+	info, err := os.Stat(filepath.Join(s.path, "2022.12.04.07.09.47.tar.gz"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20000), info.Size())
+
+	viper.Set("rate_limit", "fast")
+	_, err = newBase(config.ModelConfig{}, archivePath, config.SubConfig{Type: "local", Viper: viper})
+	assert.Error(t, err)
+}
+
+func TestLocal_uploadStream(t *testing.T) {
+	s := newTestLocal(t)
+
+	assert.NoError(t, s.uploadStream("foo.tar.gz", strings.NewReader("hello")))
+
+	data, err := os.ReadFile(filepath.Join(s.path, "foo.tar.gz"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), "hello")
+}
+
+func TestLocal_list(t *testing.T) {
+	s := newTestLocal(t)
+	assert.NoError(t, os.MkdirAll(filepath.Join(s.path, "2022.12.04.07.09.47"), 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(s.path, "2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000"), []byte("hello"), 0660))
+	assert.NoError(t, os.WriteFile(filepath.Join(s.path, "foo.tar.gz"), []byte("hello"), 0660))
+
+	items, err := s.list("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000", "foo.tar.gz"}, fileItemNames(items))
+
+	items, err = s.list("2022.12.04.07.09.47")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000"}, fileItemNames(items))
+}
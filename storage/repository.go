@@ -0,0 +1,424 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/logger"
+)
+
+// Repository mode stores the archive as content-defined chunks, a chunk is uploaded only once
+// and shared by all snapshots, like restic or borg. Enable it on any storage:
+//
+//	storages:
+//	  s3:
+//	    type: s3
+//	    repository: true
+//	    chunk_size: 1048576
+//
+// The remote layout is:
+//
+//	chunks/ab/ab12...ef                      zstd compressed chunk named by the SHA-256 of its content
+//	locks/shared-1670137787000000000-host-1  the lock of a running upload, `exclusive-` of the garbage collection
+//	2022.12.04.07.09.47.tar.snapshot         the manifest of the archive, tracked by the cycler
+//	2022.12.04.07.09.47.tar.snapshot.sha256  the sidecar of the archive
+//
+// Compressed or encrypted archives change entirely on every run, use `compress_with: tar`
+// without `encrypt_with` to deduplicate, the chunks are compressed one by one. A listed chunk
+// is reused only with the size of the compressed chunk, a partial upload is uploaded again.
+// Removing snapshots by the cycler deletes the chunks no longer referenced, unless another
+// upload holds a lock of the repository, and an upload waits for the running garbage collection.
+const (
+	snapshotExt = ".snapshot"
+	chunksDir   = "chunks"
+	locksDir    = "locks"
+)
+
+var (
+	// lockStaleAge of the repository locks, the older locks are left by the killed processes and ignored
+	lockStaleAge = 24 * time.Hour
+	// lockWaitInterval of the upload waiting for the garbage collection
+	lockWaitInterval = 10 * time.Second
+)
+
+type snapshotManifest struct {
+	// Name of the archive
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"checksum"`
+	CreatedAt time.Time `json:"created_at"`
+	Chunks    []string  `json:"chunks"`
+}
+
+func isSnapshot(fileKey string) bool {
+	return strings.HasSuffix(fileKey, snapshotExt)
+}
+
+func chunkKey(hash string) string {
+	return path.Join(chunksDir, hash[:2], hash)
+}
+
+// openArchive open the archive of the base, the split parts are read one after another
+func openArchive(base Base) (io.ReadCloser, string, error) {
+	if len(base.fileKeys) == 0 {
+		f, err := os.Open(base.archivePath)
+		return f, filepath.Base(base.archivePath), err
+	}
+
+	var readers []io.Reader
+	var files []*os.File
+	for _, key := range base.fileKeys {
+		f, err := os.Open(filepath.Join(filepath.Dir(base.archivePath), key))
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, "", err
+		}
+		files = append(files, f)
+		readers = append(readers, f)
+	}
+
+	return &multiReadCloser{Reader: io.MultiReader(readers...), files: files}, archiveName(Package{FileKeys: base.fileKeys}), nil
+}
+
+type multiReadCloser struct {
+	io.Reader
+	files []*os.File
+}
+
+func (m *multiReadCloser) Close() error {
+	for _, f := range m.files {
+		f.Close()
+	}
+	return nil
+}
+
+// uploadRepository upload the new chunks of the archive `name` read from `r` and its snapshot manifest,
+// return the package of the snapshot.
+func uploadRepository(base Base, s Storage, name string, r io.Reader) (Package, error) {
+	logger := logger.Tag("Repository")
+
+	lock, err := lockRepository(s, false)
+	if err != nil {
+		return Package{}, fmt.Errorf("lock repository failed: %v", err)
+	}
+	defer unlockRepository(s, lock)
+
+	// The storages without list, e.g. SCP, upload all chunks
+	existing := map[string]int64{}
+	items, err := waitForGC(s, lock)
+	if err != nil {
+		logger.Warnf("List chunks failed, upload all chunks: %v", err)
+	}
+	for _, item := range items {
+		if strings.HasPrefix(item.Filename, chunksDir+"/") {
+			existing[path.Base(item.Filename)] = item.Size
+		}
+	}
+
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return Package{}, err
+	}
+	defer encoder.Close()
+
+	chunkSize := 0
+	if base.viper != nil {
+		chunkSize = base.viper.GetInt("chunk_size")
+	}
+
+	manifest := snapshotManifest{Name: name, CreatedAt: time.Now()}
+	archiveHash := sha256.New()
+	c := newChunker(io.TeeReader(r, archiveHash), chunkSize)
+
+	var uploaded, uploadedSize int
+	for {
+		chunk, err := c.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Package{}, err
+		}
+
+		sum := sha256.Sum256(chunk)
+		hash := hex.EncodeToString(sum[:])
+		manifest.Chunks = append(manifest.Chunks, hash)
+		manifest.Size += int64(len(chunk))
+
+		data := encoder.EncodeAll(chunk, nil)
+		if size, ok := existing[hash]; ok {
+			if size == int64(len(data)) {
+				continue
+			}
+			logger.Warnf("Chunk %s has %d bytes instead of %d, upload it again", hash, size, len(data))
+		}
+
+		err = base.retry.Do(logger, "Upload chunk "+hash, func() error {
+			return s.uploadStream(chunkKey(hash), helper.LimitReader(bytes.NewReader(data), base.limiters()...))
+		})
+		if err != nil {
+			return Package{}, fmt.Errorf("upload chunk %s failed: %v", hash, err)
+		}
+		existing[hash] = int64(len(data))
+		uploaded++
+		uploadedSize += len(data)
+	}
+	manifest.Checksum = hex.EncodeToString(archiveHash.Sum(nil))
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return Package{}, err
+	}
+
+	fileKey := name + snapshotExt
+	err = base.retry.Do(logger, "Upload "+fileKey, func() error {
+		return s.uploadStream(fileKey, bytes.NewReader(data))
+	})
+	if err != nil {
+		return Package{}, fmt.Errorf("upload snapshot %s failed: %v", fileKey, err)
+	}
+	logger.Infof("Stored %s: %d chunks, %d new (%s)", fileKey, len(manifest.Chunks), uploaded, humanize.Bytes(uint64(uploadedSize)))
+
+	return Package{FileKey: fileKey, Checksum: manifest.Checksum}, nil
+}
+
+// fetchSnapshot fetch the snapshot manifest
+func fetchSnapshot(s Storage, fileKey string, targetDir string) (manifest snapshotManifest, err error) {
+	localPath := filepath.Join(targetDir, fileKey)
+	if err := fetchFile(s, fileKey, localPath); err != nil {
+		return manifest, fmt.Errorf("fetch %s failed: %v", fileKey, err)
+	}
+	defer os.Remove(localPath)
+
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return manifest, err
+	}
+	err = json.Unmarshal(data, &manifest)
+	return manifest, err
+}
+
+// restoreSnapshot rebuild the archive of the snapshot into `targetDir` from the chunks,
+// every chunk is checked against its hash.
+func restoreSnapshot(s Storage, fileKey string, targetDir string) (string, error) {
+	logger := logger.Tag("Repository")
+
+	manifest, err := fetchSnapshot(s, fileKey, targetDir)
+	if err != nil {
+		return "", err
+	}
+
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return "", err
+	}
+	defer decoder.Close()
+
+	archivePath := filepath.Join(targetDir, manifest.Name)
+	f, err := createLocalFile(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	chunkPath := filepath.Join(targetDir, chunksDir)
+	defer os.RemoveAll(chunkPath)
+
+	logger.Infof("-> Restoring %s from %d chunks", manifest.Name, len(manifest.Chunks))
+	for _, hash := range manifest.Chunks {
+		localPath := filepath.Join(targetDir, chunkKey(hash))
+		if err := fetchFile(s, chunkKey(hash), localPath); err != nil {
+			return "", fmt.Errorf("fetch chunk %s failed: %v", hash, err)
+		}
+
+		data, err := os.ReadFile(localPath)
+		if err != nil {
+			return "", err
+		}
+		os.Remove(localPath)
+
+		chunk, err := decoder.DecodeAll(data, nil)
+		if err != nil {
+			return "", fmt.Errorf("decompress chunk %s failed: %v", hash, err)
+		}
+		if sum := sha256.Sum256(chunk); hex.EncodeToString(sum[:]) != hash {
+			return "", fmt.Errorf("chunk %s is corrupted", hash)
+		}
+
+		if _, err := f.Write(chunk); err != nil {
+			return "", err
+		}
+	}
+
+	return archivePath, f.Close()
+}
+
+// gcRepository delete the chunks not referenced by any snapshot, nothing is deleted when any
+// snapshot can not be read, or when another upload holds a lock of the repository, since its
+// snapshot may reference the chunks which are not referenced yet.
+func gcRepository(s Storage, deleteFn func(fileKey string) error) error {
+	logger := logger.Tag("Repository")
+
+	lock, err := lockRepository(s, true)
+	if err != nil {
+		return fmt.Errorf("lock repository failed: %v", err)
+	}
+	defer unlockRepository(s, lock)
+
+	items, err := s.list("")
+	if err != nil {
+		return fmt.Errorf("list repository failed: %v", err)
+	}
+	if locks := activeLocks(items, lock, false); len(locks) > 0 {
+		return fmt.Errorf("repository is locked by %s", strings.Join(locks, ", "))
+	}
+
+	tempDir, err := os.MkdirTemp("", "launch-agent-gc")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	referenced := map[string]bool{}
+	var chunks []FileItem
+	for _, item := range items {
+		switch {
+		case strings.HasPrefix(item.Filename, chunksDir+"/"):
+			chunks = append(chunks, item)
+		case isSnapshot(item.Filename):
+			manifest, err := fetchSnapshot(s, item.Filename, tempDir)
+			if err != nil {
+				return fmt.Errorf("read snapshot %s failed: %v", item.Filename, err)
+			}
+			for _, hash := range manifest.Chunks {
+				referenced[hash] = true
+			}
+		}
+	}
+
+	var removed int
+	var removedSize int64
+	for _, item := range chunks {
+		if referenced[path.Base(item.Filename)] {
+			continue
+		}
+		if err := deleteFn(item.Filename); err != nil {
+			logger.Warnf("Remove chunk %s failed: %v", item.Filename, err)
+			continue
+		}
+		removed++
+		removedSize += item.Size
+	}
+	logger.Infof("Removed %d unreferenced chunks (%s)", removed, humanize.Bytes(uint64(removedSize)))
+
+	return nil
+}
+
+// lockRepository create the lock of the repository, exclusive for the garbage collection, return its key.
+// The creation time is in the key, since not all storages list the modification time.
+func lockRepository(s Storage, exclusive bool) (string, error) {
+	kind := "shared"
+	if exclusive {
+		kind = "exclusive"
+	}
+	host, _ := os.Hostname()
+	key := path.Join(locksDir, fmt.Sprintf("%s-%d-%s-%d", kind, time.Now().UnixNano(), host, os.Getpid()))
+
+	return key, s.uploadStream(key, strings.NewReader(""))
+}
+
+func unlockRepository(s Storage, lock string) {
+	if err := s.delete(lock); err != nil {
+		logger.Tag("Repository").Warnf("Remove lock %s failed: %v", lock, err)
+	}
+}
+
+// activeLocks return the locks of the other processes in the listing which are not stale, only the locks
+// of the garbage collection with `exclusive`
+func activeLocks(items []FileItem, own string, exclusive bool) []string {
+	var locks []string
+	for _, item := range items {
+		if !strings.HasPrefix(item.Filename, locksDir+"/") || item.Filename == own {
+			continue
+		}
+
+		// shared-1670137787000000000-host-1
+		fields := strings.SplitN(path.Base(item.Filename), "-", 3)
+		if len(fields) < 3 || (exclusive && fields[0] != "exclusive") {
+			continue
+		}
+		createdAt, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || time.Since(time.Unix(0, createdAt)) > lockStaleAge {
+			continue
+		}
+		locks = append(locks, item.Filename)
+	}
+	return locks
+}
+
+// waitForGC list the repository until no garbage collection holds a lock of it. The lock `own` of the upload
+// is created before, so the garbage collection started later is skipped.
+func waitForGC(s Storage, own string) ([]FileItem, error) {
+	for {
+		items, err := s.list("")
+		if err != nil {
+			return nil, err
+		}
+
+		locks := activeLocks(items, own, true)
+		if len(locks) == 0 {
+			return items, nil
+		}
+		logger.Tag("Repository").Infof("Waiting for the garbage collection %s", strings.Join(locks, ", "))
+		time.Sleep(lockWaitInterval)
+	}
+}
+
+// runRepository store the archive of the base in the repository, then remove the expired snapshots
+// and their chunks.
+func runRepository(base Base, s Storage, name string, r io.Reader) error {
+	pkg, err := uploadRepository(base, s, name, r)
+	if err != nil {
+		return err
+	}
+
+	if base.model.Viper != nil {
+		pkg.Chain = base.model.Viper.GetString("ArchiveChain")
+		pkg.Level = base.model.Viper.GetInt("ArchiveLevel")
+	}
+	if err = uploadSidecar(base, s, pkg); err != nil {
+		return err
+	}
+
+	syncIfNeeded(base, s, "")
+
+	var removed bool
+	deleteFn := base.deleteFunc(s)
+	base.cycler.run(pkg, base.keep, base.retention, func(fileKey string) error {
+		removed = true
+		return deleteFn(fileKey)
+	})
+	if removed {
+		if err := gcRepository(s, deleteFn); err != nil {
+			logger.Tag("Repository").Warnf("Garbage collection skipped: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
+	"golang.org/x/net/webdav"
+)
+
+func countChunks(t *testing.T, dir string) int {
+	var n int
+	err := filepath.Walk(filepath.Join(dir, chunksDir), func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			n++
+		}
+		return err
+	})
+	assert.NoError(t, err)
+	return n
+}
+
+func TestRepository(t *testing.T) {
+	cyclerPath = t.TempDir()
+	remotePath := t.TempDir()
+
+	storageViper := viper.New()
+	storageViper.Set("path", remotePath)
+	storageViper.Set("repository", true)
+	storageViper.Set("chunk_size", 4096)
+	storageViper.Set("keep", 1)
+	storageConfig := config.SubConfig{Name: "local", Type: "local", Viper: storageViper}
+	model := config.ModelConfig{
+		Name:           "test",
+		DefaultStorage: "local",
+		Storages:       map[string]config.SubConfig{"local": storageConfig},
+	}
+
+	data := make([]byte, 256<<10)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	archivePath := filepath.Join(t.TempDir(), "2022.12.04.07.09.47.tar")
+	assert.NoError(t, os.WriteFile(archivePath, data, 0660))
+	assert.NoError(t, runModel(model, archivePath, storageConfig))
+	assert.True(t, helper.IsExistsPath(filepath.Join(remotePath, "2022.12.04.07.09.47.tar.snapshot")))
+	assert.True(t, helper.IsExistsPath(filepath.Join(remotePath, "2022.12.04.07.09.47.tar.snapshot.sha256")))
+	assert.False(t, helper.IsExistsPath(filepath.Join(remotePath, "2022.12.04.07.09.47.tar")))
+	first := countChunks(t, remotePath)
+
+	// Only the changed chunks are uploaded, the chunks of the first snapshot are removed with it
+	changed := append([]byte("hello world"), data[:128<<10]...)
+	archivePath = filepath.Join(t.TempDir(), "2022.12.05.07.09.47.tar")
+	assert.NoError(t, os.WriteFile(archivePath, changed, 0660))
+	assert.NoError(t, runModel(model, archivePath, storageConfig))
+	assert.False(t, helper.IsExistsPath(filepath.Join(remotePath, "2022.12.04.07.09.47.tar.snapshot")))
+	second := countChunks(t, remotePath)
+	assert.True(t, second < first)
+
+	// Restore the archive from the chunks
+	restored, err := Download(model, "", t.TempDir())
+	assert.NoError(t, err)
+	assert.Equal(t, "2022.12.05.07.09.47.tar", filepath.Base(restored))
+	restoredData, err := os.ReadFile(restored)
+	assert.NoError(t, err)
+	assert.Equal(t, changed, restoredData)
+
+	_, err = Verify(model, "", t.TempDir())
+	assert.NoError(t, err)
+
+	// A corrupted chunk fails the verify
+	err = filepath.Walk(filepath.Join(remotePath, chunksDir), func(filePath string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			return os.WriteFile(filePath, []byte("corrupted"), 0660)
+		}
+		return err
+	})
+	assert.NoError(t, err)
+	_, err = Verify(model, "", t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestRepository_partialChunk(t *testing.T) {
+	cyclerPath = t.TempDir()
+	remotePath := t.TempDir()
+
+	storageViper := viper.New()
+	storageViper.Set("path", remotePath)
+	storageViper.Set("repository", true)
+	storageViper.Set("chunk_size", 4096)
+	storageConfig := config.SubConfig{Name: "local", Type: "local", Viper: storageViper}
+	model := config.ModelConfig{
+		Name:           "test",
+		DefaultStorage: "local",
+		Storages:       map[string]config.SubConfig{"local": storageConfig},
+	}
+
+	data := make([]byte, 64<<10)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	archivePath := filepath.Join(t.TempDir(), "2022.12.04.07.09.47.tar")
+	assert.NoError(t, os.WriteFile(archivePath, data, 0660))
+	assert.NoError(t, runModel(model, archivePath, storageConfig))
+
+	// Truncate all chunks like interrupted uploads
+	err := filepath.Walk(filepath.Join(remotePath, chunksDir), func(filePath string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			return os.Truncate(filePath, info.Size()/2)
+		}
+		return err
+	})
+	assert.NoError(t, err)
+
+	archivePath = filepath.Join(t.TempDir(), "2022.12.05.07.09.47.tar")
+	assert.NoError(t, os.WriteFile(archivePath, data, 0660))
+	assert.NoError(t, runModel(model, archivePath, storageConfig))
+
+	_, err = Verify(model, "2022.12.05.07.09.47.tar.snapshot", t.TempDir())
+	assert.NoError(t, err)
+}
+
+func TestRepository_locks(t *testing.T) {
+	remotePath := t.TempDir()
+	storageViper := viper.New()
+	storageViper.Set("path", remotePath)
+	_, s, err := new(config.ModelConfig{Name: "test"}, "", config.SubConfig{Name: "local", Type: "local", Viper: storageViper})
+	assert.NoError(t, err)
+	assert.NoError(t, s.open())
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(remotePath, chunksDir, "ab"), 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(remotePath, chunksDir, "ab", "abcd"), []byte("chunk"), 0660))
+
+	// The garbage collection is skipped while an upload holds a lock
+	lock, err := lockRepository(s, false)
+	assert.NoError(t, err)
+	err = gcRepository(s, s.delete)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "repository is locked by "+lock)
+	assert.True(t, helper.IsExistsPath(filepath.Join(remotePath, chunksDir, "ab", "abcd")))
+
+	// The stale lock is ignored
+	unlockRepository(s, lock)
+	stale := fmt.Sprintf("shared-%d-host-1", time.Now().Add(-lockStaleAge-time.Hour).UnixNano())
+	assert.NoError(t, os.WriteFile(filepath.Join(remotePath, locksDir, stale), nil, 0660))
+	assert.NoError(t, gcRepository(s, s.delete))
+	assert.False(t, helper.IsExistsPath(filepath.Join(remotePath, chunksDir, "ab", "abcd")))
+
+	// The upload waits for the garbage collection
+	interval := lockWaitInterval
+	lockWaitInterval = 10 * time.Millisecond
+	defer func() { lockWaitInterval = interval }()
+
+	gcLock, err := lockRepository(s, true)
+	assert.NoError(t, err)
+	own, err := lockRepository(s, false)
+	assert.NoError(t, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		unlockRepository(s, gcLock)
+	}()
+	startedAt := time.Now()
+	_, err = waitForGC(s, own)
+	assert.NoError(t, err)
+	assert.True(t, time.Since(startedAt) >= 50*time.Millisecond)
+}
+
+// testRepositoryStorage store a snapshot in the repository of the storage, restore it, then remove
+// its chunks by the garbage collection after the snapshot is deleted
+func testRepositoryStorage(t *testing.T, base Base, s Storage) {
+	data := make([]byte, 64<<10)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	pkg, err := uploadRepository(base, s, "2022.12.04.07.09.47.tar", bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, "2022.12.04.07.09.47.tar.snapshot", pkg.FileKey)
+
+	restored, err := restoreSnapshot(s, pkg.FileKey, t.TempDir())
+	assert.NoError(t, err)
+	restoredData, err := os.ReadFile(restored)
+	assert.NoError(t, err)
+	assert.Equal(t, data, restoredData)
+
+	var chunks, locks int
+	items, err := s.list("")
+	assert.NoError(t, err)
+	for _, item := range items {
+		switch {
+		case strings.HasPrefix(item.Filename, chunksDir+"/"):
+			chunks++
+		case strings.HasPrefix(item.Filename, locksDir+"/"):
+			locks++
+		}
+	}
+	assert.True(t, chunks > 0)
+	assert.Equal(t, 0, locks)
+
+	assert.NoError(t, s.delete(pkg.FileKey))
+	assert.NoError(t, gcRepository(s, s.delete))
+	items, err = s.list("")
+	assert.NoError(t, err)
+	for _, item := range items {
+		assert.False(t, strings.HasPrefix(item.Filename, chunksDir+"/"))
+	}
+}
+
+func newRepositoryBase(t *testing.T, storageType string, storageViper *viper.Viper) Base {
+	storageViper.Set("repository", true)
+	storageViper.Set("chunk_size", 4096)
+	base, err := newBase(config.ModelConfig{Name: "test"}, "", config.SubConfig{Name: storageType, Type: storageType, Viper: storageViper})
+	assert.NoError(t, err)
+	return base
+}
+
+func TestRepository_SFTP(t *testing.T) {
+	remotePath := t.TempDir()
+	storageViper := viper.New()
+	storageViper.Set("path", remotePath)
+
+	base := newRepositoryBase(t, "sftp", storageViper)
+	testRepositoryStorage(t, base, &SFTP{Base: base, path: remotePath, client: newTestSFTPClient(t)})
+}
+
+func TestRepository_FTP(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "backups"), 0750))
+	storageViper := viper.New()
+	storageViper.Set("path", "/backups")
+
+	base := newRepositoryBase(t, "ftp", storageViper)
+	testRepositoryStorage(t, base, &FTP{Base: base, path: "/backups", client: newTestFTPClient(t, &testFTPServer{root: root})})
+}
+
+func TestRepository_WebDAV(t *testing.T) {
+	server := httptest.NewServer(&webdav.Handler{
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	})
+	defer server.Close()
+
+	storageViper := viper.New()
+	storageViper.Set("root", server.URL)
+	storageViper.Set("path", "backups")
+
+	base := newRepositoryBase(t, "webdav", storageViper)
+	s := &WebDAV{Base: base}
+	assert.NoError(t, s.open())
+	testRepositoryStorage(t, base, s)
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/logger"
+)
+
+var (
+	uploadsPath = filepath.Join(config.LaunchAgentDir, "uploads")
+
+	// ResumeMaxAge of the unfinished uploads, the older uploads are aborted and their states are removed.
+	// The archive of a failed upload is kept for the next perform as long.
+	ResumeMaxAge = 24 * time.Hour
+)
+
+// uploadState of an unfinished upload, kept under `LaunchAgentDir/uploads` until the upload is done,
+// so a retry or a restarted process continues the upload of the same file instead of sending it again.
+// The state is discarded when the local file has been changed.
+type uploadState struct {
+	Storage    string    `json:"storage"`
+	RemotePath string    `json:"remote_path"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mod_time"`
+
+	// S3 multipart upload
+	UploadID string         `json:"upload_id,omitempty"`
+	PartSize int64          `json:"part_size,omitempty"`
+	Parts    []uploadedPart `json:"parts,omitempty"`
+
+	// GCS resumable upload session
+	SessionURI string `json:"session_uri,omitempty"`
+
+	fileName string
+	// true when the state is loaded from a previous upload
+	resumed bool
+}
+
+type uploadedPart struct {
+	Number int64  `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// loadUploadState return the state of the upload of the local file to `remotePath` in the storage,
+// a new state is returned when there is no unfinished upload of the same file.
+func (b Base) loadUploadState(remotePath string, info os.FileInfo) *uploadState {
+	logger := logger.Tag("Storage")
+
+	hash := sha256.Sum256([]byte(b.cycler.name + ":" + remotePath))
+	state := &uploadState{
+		Storage:    b.cycler.name,
+		RemotePath: remotePath,
+		Size:       info.Size(),
+		ModTime:    info.ModTime(),
+		fileName:   filepath.Join(uploadsPath, hex.EncodeToString(hash[:16])+".json"),
+	}
+
+	data, err := os.ReadFile(state.fileName)
+	if err != nil {
+		return state
+	}
+
+	var previous uploadState
+	if err := json.Unmarshal(data, &previous); err != nil {
+		logger.Warnf("Load upload state of %s failed: %v", remotePath, err)
+		return state
+	}
+	if previous.RemotePath != remotePath || previous.Size != state.Size || !previous.ModTime.Equal(state.ModTime) {
+		logger.Warnf("%s has been changed since the last upload, start over", remotePath)
+		return state
+	}
+
+	previous.fileName = state.fileName
+	previous.resumed = true
+	return &previous
+}
+
+func (s *uploadState) save() error {
+	if err := helper.MkdirP(uploadsPath); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.fileName, data, 0660)
+}
+
+// remove the state when the upload is done
+func (s *uploadState) remove() {
+	if err := os.Remove(s.fileName); err != nil && !os.IsNotExist(err) {
+		logger.Tag("Storage").Warnf("Remove upload state %s failed: %v", s.fileName, err)
+	}
+}
+
+// uploadAborter is implemented by the storages which keep the data of the unfinished uploads remotely
+type uploadAborter interface {
+	// abortUpload discard the remote data of the unfinished upload, the upload which is gone is not an error
+	abortUpload(state *uploadState) error
+}
+
+// orphanAborter is implemented by the storages which can list their unfinished uploads, including the
+// uploads whose state has been lost
+type orphanAborter interface {
+	// abortOrphanUploads abort the unfinished uploads started before `before`, except the `active` upload IDs
+	abortOrphanUploads(before time.Time, active map[string]bool) error
+}
+
+// cleanUploadStates abort the unfinished uploads of the storage whose file is older than ResumeMaxAge
+// and remove their states, the file is not kept any longer so the upload can't be resumed.
+func (b Base) cleanUploadStates(s Storage) {
+	logger := logger.Tag("Storage")
+
+	entries, err := os.ReadDir(uploadsPath)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warnf("Load upload states failed: %v", err)
+	}
+
+	before := time.Now().Add(-ResumeMaxAge)
+	active := map[string]bool{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		fileName := filepath.Join(uploadsPath, entry.Name())
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			continue
+		}
+		var state uploadState
+		if err := json.Unmarshal(data, &state); err != nil {
+			continue
+		}
+		state.fileName = fileName
+
+		if !state.ModTime.Before(before) {
+			if len(state.UploadID) > 0 {
+				active[state.UploadID] = true
+			}
+			continue
+		}
+		if state.Storage != b.cycler.name {
+			continue
+		}
+
+		if a, ok := s.(uploadAborter); ok {
+			if err := a.abortUpload(&state); err != nil {
+				logger.Warnf("Abort the unfinished upload of %s failed: %v", state.RemotePath, err)
+				continue
+			}
+		}
+		logger.Infof("Discard the unfinished upload of %s", state.RemotePath)
+		state.remove()
+	}
+
+	if a, ok := s.(orphanAborter); ok {
+		if err := a.abortOrphanUploads(before, active); err != nil {
+			logger.Warnf("Abort the orphan uploads failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+)
+
+func TestLoadUploadState(t *testing.T) {
+	uploadsPath = t.TempDir()
+
+	localPath := filepath.Join(t.TempDir(), "foo.tar.gz")
+	assert.NoError(t, os.WriteFile(localPath, []byte("hello"), 0660))
+	info, err := os.Stat(localPath)
+	assert.NoError(t, err)
+
+	base := Base{cycler: &Cycler{name: "test_s3"}}
+
+	state := base.loadUploadState("backups/foo.tar.gz", info)
+	assert.False(t, state.resumed)
+	state.UploadID = "upload-1"
+	state.Parts = []uploadedPart{{Number: 1, ETag: "etag-1", Size: 5}}
+	assert.NoError(t, state.save())
+
+	// Same file
+	state = base.loadUploadState("backups/foo.tar.gz", info)
+	assert.True(t, state.resumed)
+	assert.Equal(t, "upload-1", state.UploadID)
+	assert.Equal(t, []uploadedPart{{Number: 1, ETag: "etag-1", Size: 5}}, state.Parts)
+
+	// Other storage
+	other := Base{cycler: &Cycler{name: "test_gcs"}}
+	assert.False(t, other.loadUploadState("backups/foo.tar.gz", info).resumed)
+
+	// Changed file
+	assert.NoError(t, os.Chtimes(localPath, time.Now(), time.Now().Add(time.Hour)))
+	changed, err := os.Stat(localPath)
+	assert.NoError(t, err)
+	state = base.loadUploadState("backups/foo.tar.gz", changed)
+	assert.False(t, state.resumed)
+	assert.Equal(t, "", state.UploadID)
+
+	state.remove()
+	_, err = os.Stat(state.fileName)
+	assert.True(t, os.IsNotExist(err))
+}
+
+type fakeAbortStorage struct {
+	Storage
+	aborted []string
+}
+
+func (s *fakeAbortStorage) abortUpload(state *uploadState) error {
+	s.aborted = append(s.aborted, state.RemotePath)
+	return nil
+}
+
+func TestCleanUploadStates(t *testing.T) {
+	uploadsPath = t.TempDir()
+
+	base := Base{cycler: &Cycler{name: "test_sftp"}}
+	save := func(storage, remotePath string, modTime time.Time) *uploadState {
+		state := &uploadState{
+			Storage:    storage,
+			RemotePath: remotePath,
+			ModTime:    modTime,
+			fileName:   filepath.Join(uploadsPath, remotePath+".json"),
+		}
+		assert.NoError(t, state.save())
+		return state
+	}
+
+	stale := save("test_sftp", "stale", time.Now().Add(-ResumeMaxAge-time.Hour))
+	recent := save("test_sftp", "recent", time.Now())
+	other := save("test_s3", "other", time.Now().Add(-ResumeMaxAge-time.Hour))
+
+	s := &fakeAbortStorage{}
+	base.cleanUploadStates(s)
+	assert.Equal(t, []string{"stale"}, s.aborted)
+
+	_, err := os.Stat(stale.fileName)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(recent.fileName)
+	assert.NoError(t, err)
+	_, err = os.Stat(other.fileName)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/gigcodes/launch-util/helper"
+)
+
+// Retention is the generation-based (GFS) retention policy of a storage
+//
+//	retention:
+//	  daily: 7
+//	  weekly: 4
+//	  monthly: 12
+//	  yearly: 3
+//	  max_age: 400d
+//	  dry_run: false
+//
+// The newest package of each day, ISO week, month and year is kept until the
+// count of that generation is reached, a package is kept if any generation or
+// `keep` selects it. Packages older than `max_age` are always removed, except
+// the latest one. With `dry_run`, the packages to remove are only printed.
+type Retention struct {
+	Daily   int
+	Weekly  int
+	Monthly int
+	Yearly  int
+	MaxAge  time.Duration
+	DryRun  bool
+}
+
+func newRetention(v *viper.Viper) (r Retention, err error) {
+	if v == nil {
+		return
+	}
+
+	r = Retention{
+		Daily:   v.GetInt("daily"),
+		Weekly:  v.GetInt("weekly"),
+		Monthly: v.GetInt("monthly"),
+		Yearly:  v.GetInt("yearly"),
+		DryRun:  v.GetBool("dry_run"),
+	}
+
+	if maxAge := v.GetString("max_age"); len(maxAge) > 0 {
+		r.MaxAge, err = helper.ParseDuration(maxAge)
+		if err != nil {
+			return r, fmt.Errorf("invalid retention max_age %q: %v", maxAge, err)
+		}
+	}
+
+	return
+}
+
+func (r Retention) enabled() bool {
+	return r.Daily > 0 || r.Weekly > 0 || r.Monthly > 0 || r.Yearly > 0 || r.MaxAge > 0
+}
+
+func (r Retention) hasGenerations() bool {
+	return r.Daily > 0 || r.Weekly > 0 || r.Monthly > 0 || r.Yearly > 0
+}
+
+// expired split the packages into the kept and the expired by the retention policy and `keep`, at `now`
+func (r Retention) expired(packages PackageList, keep int, now time.Time) (kept PackageList, expired PackageList) {
+	if len(packages) == 0 {
+		return packages, nil
+	}
+
+	// Newest first
+	sorted := make(PackageList, len(packages))
+	copy(sorted, packages)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	selected := make([]bool, len(sorted))
+	if !r.hasGenerations() && keep == 0 {
+		// Only `max_age`
+		for i := range selected {
+			selected[i] = true
+		}
+	}
+
+	for i := 0; i < keep && i < len(sorted); i++ {
+		selected[i] = true
+	}
+
+	generations := []struct {
+		count  int
+		bucket func(t time.Time) string
+	}{
+		{r.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{r.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{r.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+		{r.Yearly, func(t time.Time) string { return t.Format("2006") }},
+	}
+	for _, g := range generations {
+		last := ""
+		count := 0
+		for i, pkg := range sorted {
+			if count >= g.count {
+				break
+			}
+			bucket := g.bucket(pkg.CreatedAt)
+			if bucket != last {
+				selected[i] = true
+				last = bucket
+				count++
+			}
+		}
+	}
+
+	if r.MaxAge > 0 {
+		for i, pkg := range sorted {
+			if now.Sub(pkg.CreatedAt) > r.MaxAge {
+				selected[i] = false
+			}
+		}
+	}
+
+	// Always keep the latest package
+	selected[0] = true
+
+	for i, pkg := range sorted {
+		if !selected[i] {
+			expired = append(expired, pkg)
+		}
+	}
+
+	// Keep the original order of the cycler
+	for _, pkg := range packages {
+		if !containsPackage(expired, pkg) {
+			kept = append(kept, pkg)
+		}
+	}
+
+	return kept, expired
+}
+
+func containsPackage(packages PackageList, pkg Package) bool {
+	for _, p := range packages {
+		if p.FileKey == pkg.FileKey && p.CreatedAt.Equal(pkg.CreatedAt) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
+)
+
+// dailyPackages create a package per day for `days` days before `now`, the oldest first
+func dailyPackages(now time.Time, days int) PackageList {
+	var packages PackageList
+	for i := days - 1; i >= 0; i-- {
+		createdAt := now.AddDate(0, 0, -i)
+		packages = append(packages, Package{
+			FileKey:   createdAt.Format("2006.01.02.15.04.05") + ".tar.gz",
+			CreatedAt: createdAt,
+		})
+	}
+	return packages
+}
+
+func fileKeys(packages PackageList) (keys []string) {
+	for _, pkg := range packages {
+		keys = append(keys, pkg.FileKey)
+	}
+	return
+}
+
+func TestRetention_expired(t *testing.T) {
+	// Sunday
+	now := time.Date(2023, 12, 31, 1, 0, 0, 0, time.UTC)
+	packages := dailyPackages(now, 400)
+
+	retention := Retention{Daily: 7, Weekly: 4, Monthly: 12, Yearly: 2}
+	kept, expired := retention.expired(packages, 0, now)
+	assert.Equal(t, 400, len(kept)+len(expired))
+
+	keys := fileKeys(kept)
+	assert.Equal(t, []string{
+		// yearly
+		"2022.12.31.01.00.00.tar.gz",
+		// monthly
+		"2023.01.31.01.00.00.tar.gz",
+		"2023.02.28.01.00.00.tar.gz",
+		"2023.03.31.01.00.00.tar.gz",
+		"2023.04.30.01.00.00.tar.gz",
+		"2023.05.31.01.00.00.tar.gz",
+		"2023.06.30.01.00.00.tar.gz",
+		"2023.07.31.01.00.00.tar.gz",
+		"2023.08.31.01.00.00.tar.gz",
+		"2023.09.30.01.00.00.tar.gz",
+		"2023.10.31.01.00.00.tar.gz",
+		"2023.11.30.01.00.00.tar.gz",
+		// weekly, ISO weeks end on Sunday
+		"2023.12.10.01.00.00.tar.gz",
+		"2023.12.17.01.00.00.tar.gz",
+		"2023.12.24.01.00.00.tar.gz",
+		// daily
+		"2023.12.25.01.00.00.tar.gz",
+		"2023.12.26.01.00.00.tar.gz",
+		"2023.12.27.01.00.00.tar.gz",
+		"2023.12.28.01.00.00.tar.gz",
+		"2023.12.29.01.00.00.tar.gz",
+		"2023.12.30.01.00.00.tar.gz",
+		"2023.12.31.01.00.00.tar.gz",
+	}, keys)
+}
+
+func TestRetention_expired_maxAge(t *testing.T) {
+	now := time.Date(2023, 12, 31, 1, 0, 0, 0, time.UTC)
+	packages := dailyPackages(now, 30)
+
+	// Only max_age
+	retention := Retention{MaxAge: 10 * 24 * time.Hour}
+	kept, expired := retention.expired(packages, 0, now)
+	assert.Equal(t, 11, len(kept))
+	assert.Equal(t, 19, len(expired))
+	assert.Equal(t, "2023.12.21.01.00.00.tar.gz", kept[0].FileKey)
+
+	// max_age limits the generations and keep
+	retention = Retention{Monthly: 12, MaxAge: 10 * 24 * time.Hour}
+	kept, _ = retention.expired(packages, 3, now)
+	assert.Equal(t, []string{
+		"2023.12.29.01.00.00.tar.gz",
+		"2023.12.30.01.00.00.tar.gz",
+		"2023.12.31.01.00.00.tar.gz",
+	}, fileKeys(kept))
+
+	// The latest package is always kept
+	retention = Retention{MaxAge: time.Hour}
+	kept, _ = retention.expired(dailyPackages(now.AddDate(0, 0, -5), 3), 0, now)
+	assert.Equal(t, []string{"2023.12.26.01.00.00.tar.gz"}, fileKeys(kept))
+}
+
+func TestCycler_run_retention(t *testing.T) {
+	cyclerPath = t.TempDir()
+
+	now := time.Now()
+	cycler := Cycler{name: "test"}
+	cycler.packages = dailyPackages(now.AddDate(0, 0, -1), 10)
+	cycler.isLoaded = true
+	cycler.save(cycler.fileName())
+
+	var deleted []string
+	deleteFn := func(fileKey string) error {
+		deleted = append(deleted, fileKey)
+		return nil
+	}
+
+	// Dry run only prints the expired packages
+	cycler = Cycler{name: "test"}
+	cycler.run(Package{FileKey: "new.tar.gz"}, 0, Retention{Daily: 3, DryRun: true}, deleteFn)
+	assert.Equal(t, 0, len(deleted))
+	assert.Equal(t, 11, len(cycler.packages))
+
+	cycler = Cycler{name: "test"}
+	cycler.run(Package{FileKey: "new2.tar.gz", Checksum: "abc"}, 0, Retention{Daily: 3}, deleteFn)
+	// new.tar.gz is replaced by new2.tar.gz in the same day
+	assert.Equal(t, 9, len(deleted))
+	assert.Equal(t, "new.tar.gz", deleted[0])
+	assert.Equal(t, []string{
+		now.AddDate(0, 0, -2).Format("2006.01.02.15.04.05") + ".tar.gz",
+		now.AddDate(0, 0, -1).Format("2006.01.02.15.04.05") + ".tar.gz",
+		"new2.tar.gz",
+	}, fileKeys(cycler.packages))
+}
+
+func TestNewRetention(t *testing.T) {
+	r, err := newRetention(nil)
+	assert.NoError(t, err)
+	assert.False(t, r.enabled())
+
+	v := viper.New()
+	v.Set("daily", 7)
+	v.Set("weekly", 4)
+	v.Set("max_age", "90d")
+	v.Set("dry_run", true)
+	r, err = newRetention(v)
+	assert.NoError(t, err)
+	assert.Equal(t, Retention{Daily: 7, Weekly: 4, MaxAge: 90 * 24 * time.Hour, DryRun: true}, r)
+
+	v.Set("max_age", "2160h")
+	r, err = newRetention(v)
+	assert.NoError(t, err)
+	assert.Equal(t, 90*24*time.Hour, r.MaxAge)
+
+	v.Set("max_age", "foo")
+	_, err = newRetention(v)
+	assert.Error(t, err)
+	assert.Equal(t, `invalid retention max_age "foo": time: invalid duration "foo"`, fmt.Sprint(err))
+}
@@ -1,14 +1,18 @@
 package storage
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -18,6 +22,9 @@ import (
 	"github.com/gigcodes/launch-util/logger"
 )
 
+// Files larger than the part size are uploaded in parts and resumed after a failure
+var s3PartSize int64 = 64 * 1024 * 1024 // 64MiB
+
 // S3 - S3 Compatible storage
 //
 // type: s3
@@ -126,7 +133,17 @@ func (s *S3) upload(fileKey string) (err error) {
 
 		defer f.Close()
 
-		progress := helper.NewProgressBar(loggerT, f)
+		progress := helper.NewProgressBar(loggerT, f, s.limiters()...)
+
+		// Upload the large file in parts which can be resumed
+		if progress.FileLength > s3PartSize {
+			if err := s.uploadMultipart(f, progress, remotePath); err != nil {
+				return progress.Errorf("%v", err)
+			}
+			progress.Done(remotePath)
+			loggerT.Info("=>", fmt.Sprintf("s3://%s/%s", s.bucket, remotePath))
+			continue
+		}
 
 		input := &s3manager.UploadInput{
 			Bucket: aws.String(s.bucket),
@@ -142,20 +159,8 @@ func (s *S3) upload(fileKey string) (err error) {
 		}
 
 		result, err := s.client.Upload(input, func(uploader *s3manager.Uploader) {
-			// set the part size as low as possible to avoid timeouts and aborts
-			// also set concurrency to 1 for the same reason
-			var partSize int64 = 64 * 1024 * 1024 // 64MiB
-			maxParts := progress.FileLength / partSize
-
-			// 10000 parts is the limit for AWS S3. If the resulting number of parts would exceed that limit, increase the
-			// part size as much as needed but as little possible
-			if maxParts > 10000 {
-				partSize = int64(math.Ceil(float64(progress.FileLength) / 10000))
-			}
-
 			uploader.Concurrency = 1
 			uploader.LeavePartsOnError = false
-			uploader.PartSize = partSize
 		})
 
 		if err != nil {
@@ -171,6 +176,189 @@ func (s *S3) upload(fileKey string) (err error) {
 	return nil
 }
 
+// uploadMultipart upload the file in parts, the upload ID and the uploaded parts are kept in the upload state,
+// so the upload continues from the last uploaded part after a failure.
+// The abandoned uploads are aborted after ResumeMaxAge, see cleanUploadStates.
+func (s *S3) uploadMultipart(f *os.File, progress helper.ProgressBar, remotePath string) error {
+	loggerT := logger.Tag("S3 Storage")
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// 10000 parts is the limit for AWS S3. If the resulting number of parts would exceed that limit, increase the
+	// part size as much as needed but as little possible
+	partSize := s3PartSize
+	if info.Size()/partSize >= 10000 {
+		partSize = int64(math.Ceil(float64(info.Size()) / 10000))
+	}
+
+	state := s.loadUploadState(remotePath, info)
+	if state.resumed && len(state.UploadID) > 0 && state.PartSize == partSize {
+		parts, err := s.listParts(remotePath, state.UploadID)
+		if err != nil {
+			loggerT.Warnf("Resume upload of %s failed, start over: %v", remotePath, err)
+			state.UploadID = ""
+		} else {
+			state.Parts = parts
+			loggerT.Infof("Resume upload of %s with %d uploaded parts", remotePath, len(parts))
+		}
+	} else {
+		state.UploadID = ""
+	}
+
+	if len(state.UploadID) == 0 {
+		input := &s3.CreateMultipartUploadInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(remotePath),
+		}
+		if len(s.storageClass) > 0 {
+			input.StorageClass = aws.String(s.storageClass)
+		}
+		output, err := s.client.S3.CreateMultipartUpload(input)
+		if err != nil {
+			return err
+		}
+		state.UploadID = aws.StringValue(output.UploadId)
+		state.PartSize = partSize
+		state.Parts = nil
+	}
+	if err := state.save(); err != nil {
+		return err
+	}
+
+	uploaded := map[int64]uploadedPart{}
+	for _, part := range state.Parts {
+		uploaded[part.Number] = part
+	}
+
+	buf := make([]byte, partSize)
+	for number, offset := int64(1), int64(0); offset < info.Size(); number, offset = number+1, offset+partSize {
+		size := min(partSize, info.Size()-offset)
+		if part, ok := uploaded[number]; ok && part.Size == size {
+			progress.Skip(size)
+			continue
+		}
+
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(progress.Reader, buf[:size]); err != nil {
+			return err
+		}
+
+		output, err := s.client.S3.UploadPart(&s3.UploadPartInput{
+			Bucket:     aws.String(s.bucket),
+			Key:        aws.String(remotePath),
+			UploadId:   aws.String(state.UploadID),
+			PartNumber: aws.Int64(number),
+			Body:       bytes.NewReader(buf[:size]),
+		})
+		if err != nil {
+			return fmt.Errorf("upload part %d failed: %v", number, err)
+		}
+
+		part := uploadedPart{Number: number, ETag: aws.StringValue(output.ETag), Size: size}
+		uploaded[number] = part
+		state.Parts = append(state.Parts, part)
+		if err := state.save(); err != nil {
+			return err
+		}
+	}
+
+	var completed []*s3.CompletedPart
+	for number := int64(1); number <= int64(len(uploaded)); number++ {
+		completed = append(completed, &s3.CompletedPart{
+			ETag:       aws.String(uploaded[number].ETag),
+			PartNumber: aws.Int64(number),
+		})
+	}
+
+	_, err = s.client.S3.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(remotePath),
+		UploadId:        aws.String(state.UploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return err
+	}
+
+	state.remove()
+	return nil
+}
+
+// listParts return the uploaded parts of the multipart upload
+func (s *S3) listParts(remotePath, uploadID string) ([]uploadedPart, error) {
+	var parts []uploadedPart
+	err := s.client.S3.ListPartsPages(&s3.ListPartsInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(remotePath),
+		UploadId: aws.String(uploadID),
+	}, func(output *s3.ListPartsOutput, lastPage bool) bool {
+		for _, part := range output.Parts {
+			parts = append(parts, uploadedPart{
+				Number: aws.Int64Value(part.PartNumber),
+				ETag:   aws.StringValue(part.ETag),
+				Size:   aws.Int64Value(part.Size),
+			})
+		}
+		return true
+	})
+
+	return parts, err
+}
+
+// abortUpload abort the multipart upload of the state
+func (s *S3) abortUpload(state *uploadState) error {
+	if len(state.UploadID) == 0 {
+		return nil
+	}
+
+	_, err := s.client.S3.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(state.RemotePath),
+		UploadId: aws.String(state.UploadID),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchUpload {
+		return nil
+	}
+	return err
+}
+
+// abortOrphanUploads abort the multipart uploads under the path initiated before `before` and not `active`,
+// such as the uploads of a killed process, their parts are billed until they are aborted.
+func (s *S3) abortOrphanUploads(before time.Time, active map[string]bool) error {
+	loggerT := logger.Tag("S3 Storage")
+
+	var orphans []*s3.MultipartUpload
+	err := s.client.S3.ListMultipartUploadsPages(&s3.ListMultipartUploadsInput{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(listPrefix(s.path)),
+	}, func(output *s3.ListMultipartUploadsOutput, lastPage bool) bool {
+		for _, upload := range output.Uploads {
+			if aws.TimeValue(upload.Initiated).Before(before) && !active[aws.StringValue(upload.UploadId)] {
+				orphans = append(orphans, upload)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, upload := range orphans {
+		loggerT.Infof("Abort the orphan multipart upload of %s", aws.StringValue(upload.Key))
+		err := s.abortUpload(&uploadState{RemotePath: aws.StringValue(upload.Key), UploadID: aws.StringValue(upload.UploadId)})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *S3) delete(fileKey string) (err error) {
 	remotePath := filepath.Join(s.path, fileKey)
 	input := &s3.DeleteObjectInput{
@@ -183,7 +371,7 @@ func (s *S3) delete(fileKey string) (err error) {
 
 // List the objects in the bucket with the prefix = parent
 func (s *S3) list(parent string) ([]FileItem, error) {
-	remotePath := filepath.Join(s.path, parent)
+	remotePath := listPrefix(filepath.Join(s.path, parent))
 	continueToken := ""
 	var items []FileItem
 
@@ -206,8 +394,12 @@ func (s *S3) list(parent string) ([]FileItem, error) {
 		}
 
 		for _, object := range result.Contents {
+			key, ok := relativeKey(s.path, *object.Key)
+			if !ok {
+				continue
+			}
 			items = append(items, FileItem{
-				Filename:     *object.Key,
+				Filename:     key,
 				Size:         *object.Size,
 				LastModified: *object.LastModified,
 			})
@@ -223,11 +415,12 @@ func (s *S3) list(parent string) ([]FileItem, error) {
 	return items, nil
 }
 
-// Get the object download URL by fileKey (include remote_path)
+// Get the object download URL by fileKey
 func (s *S3) download(fileKey string) (string, error) {
+	remotePath := filepath.Join(s.path, fileKey)
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
-		Key:    aws.String(fileKey),
+		Key:    aws.String(remotePath),
 	}
 
 	req, _ := s.client.S3.GetObjectRequest(input)
@@ -238,3 +431,71 @@ func (s *S3) download(fileKey string) (string, error) {
 
 	return url, nil
 }
+
+func (s *S3) fetch(fileKey string, localPath string) error {
+	remotePath := filepath.Join(s.path, fileKey)
+
+	f, err := createLocalFile(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	downloader := s3manager.NewDownloaderWithClient(s.client.S3)
+	_, err = downloader.Download(f, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(remotePath),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download s3://%s/%s, %v", s.bucket, remotePath, err)
+	}
+
+	return nil
+}
+
+func (s *S3) uploadStream(fileKey string, r io.Reader) error {
+	loggerT := logger.Tag("S3 Storage")
+
+	remotePath := filepath.Join(s.path, fileKey)
+	input := &s3manager.UploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(remotePath),
+		Body:   r,
+	}
+	if len(s.storageClass) > 0 {
+		input.StorageClass = aws.String(s.storageClass)
+	}
+
+	loggerT.Info("-> Uploading (stream)...")
+	// The size is unknown, multipart upload with 64MiB parts allows 640GiB at most
+	_, err := s.client.Upload(input, func(uploader *s3manager.Uploader) {
+		uploader.Concurrency = 1
+		uploader.LeavePartsOnError = false
+		uploader.PartSize = 64 * 1024 * 1024
+	})
+	if err != nil {
+		return err
+	}
+
+	loggerT.Info("=>", fmt.Sprintf("s3://%s/%s", s.bucket, remotePath))
+	return nil
+}
+
+// remoteMD5 return the ETag of the object, which is the MD5 only when it was not uploaded by multipart
+func (s *S3) remoteMD5(fileKey string) (string, error) {
+	remotePath := filepath.Join(s.path, fileKey)
+	output, err := s.client.S3.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(remotePath),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	etag := strings.Trim(aws.StringValue(output.ETag), `"`)
+	if strings.Contains(etag, "-") {
+		return "", nil
+	}
+
+	return etag, nil
+}
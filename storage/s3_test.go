@@ -1,9 +1,22 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/logger"
 	"github.com/longbridgeapp/assert"
 	"github.com/spf13/viper"
 )
@@ -80,3 +93,117 @@ func Test_providerName(t *testing.T) {
 	}
 
 }
+
+// fakeMultipartS3 keep the parts of one multipart upload, the upload of `failPart` fails once
+type fakeMultipartS3 struct {
+	s3iface.S3API
+	parts    map[int64][]byte
+	failPart int64
+	creates  int
+	uploads  []int64
+	object   []byte
+	pending  []*s3.MultipartUpload
+	aborted  []string
+}
+
+func (f *fakeMultipartS3) CreateMultipartUpload(*s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error) {
+	f.creates++
+	f.parts = map[int64][]byte{}
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String(fmt.Sprintf("upload-%d", f.creates))}, nil
+}
+
+func (f *fakeMultipartS3) UploadPart(input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+	number := aws.Int64Value(input.PartNumber)
+	f.uploads = append(f.uploads, number)
+	if number == f.failPart {
+		f.failPart = 0
+		return nil, errors.New("connection reset by peer")
+	}
+
+	data, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.parts[number] = data
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf("etag-%d", number))}, nil
+}
+
+func (f *fakeMultipartS3) ListPartsPages(input *s3.ListPartsInput, fn func(*s3.ListPartsOutput, bool) bool) error {
+	output := &s3.ListPartsOutput{}
+	for number, data := range f.parts {
+		output.Parts = append(output.Parts, &s3.Part{
+			PartNumber: aws.Int64(number),
+			ETag:       aws.String(fmt.Sprintf("etag-%d", number)),
+			Size:       aws.Int64(int64(len(data))),
+		})
+	}
+	fn(output, true)
+	return nil
+}
+
+func (f *fakeMultipartS3) CompleteMultipartUpload(input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error) {
+	parts := input.MultipartUpload.Parts
+	sort.Slice(parts, func(i, j int) bool { return *parts[i].PartNumber < *parts[j].PartNumber })
+	for _, part := range parts {
+		f.object = append(f.object, f.parts[*part.PartNumber]...)
+	}
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (f *fakeMultipartS3) ListMultipartUploadsPages(input *s3.ListMultipartUploadsInput, fn func(*s3.ListMultipartUploadsOutput, bool) bool) error {
+	fn(&s3.ListMultipartUploadsOutput{Uploads: f.pending}, true)
+	return nil
+}
+
+func (f *fakeMultipartS3) AbortMultipartUpload(input *s3.AbortMultipartUploadInput) (*s3.AbortMultipartUploadOutput, error) {
+	f.aborted = append(f.aborted, aws.StringValue(input.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func TestS3_uploadMultipart_resume(t *testing.T) {
+	uploadsPath = t.TempDir()
+	partSize := s3PartSize
+	s3PartSize = 5
+	defer func() { s3PartSize = partSize }()
+
+	localPath := filepath.Join(t.TempDir(), "foo.tar.gz")
+	assert.NoError(t, os.WriteFile(localPath, []byte("hello world, resume"), 0660))
+
+	fake := &fakeMultipartS3{failPart: 3}
+	s := &S3{
+		Base:   Base{cycler: &Cycler{name: "test_s3"}},
+		bucket: "bucket",
+		client: &s3manager.Uploader{S3: fake},
+	}
+
+	upload := func() error {
+		f, err := os.Open(localPath)
+		assert.NoError(t, err)
+		defer f.Close()
+		return s.uploadMultipart(f, helper.NewProgressBar(logger.Tag("S3"), f), "backups/foo.tar.gz")
+	}
+
+	assert.Error(t, upload())
+	assert.NoError(t, upload())
+
+	assert.Equal(t, 1, fake.creates)
+	assert.Equal(t, []int64{1, 2, 3, 3, 4}, fake.uploads)
+	assert.Equal(t, "hello world, resume", string(fake.object))
+}
+
+func TestS3_abortOrphanUploads(t *testing.T) {
+	now := time.Now()
+	fake := &fakeMultipartS3{pending: []*s3.MultipartUpload{
+		{Key: aws.String("backups/a.tar.gz"), UploadId: aws.String("stale"), Initiated: aws.Time(now.Add(-48 * time.Hour))},
+		{Key: aws.String("backups/b.tar.gz"), UploadId: aws.String("active"), Initiated: aws.Time(now.Add(-48 * time.Hour))},
+		{Key: aws.String("backups/c.tar.gz"), UploadId: aws.String("recent"), Initiated: aws.Time(now)},
+	}}
+	s := &S3{
+		bucket: "bucket",
+		path:   "backups",
+		client: &s3manager.Uploader{S3: fake},
+	}
+
+	assert.NoError(t, s.abortOrphanUploads(now.Add(-24*time.Hour), map[string]bool{"active": true}))
+	assert.Equal(t, []string{"stale"}, fake.aborted)
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
 	"path"
@@ -90,7 +91,7 @@ func (s *SCP) open() (err error) {
 	s.client = sshClient
 
 	// mkdir
-	if err := s.run(fmt.Sprintf("mkdir -p %s", s.path)); err != nil {
+	if err := s.run("mkdir -p " + shellQuote(s.path)); err != nil {
 		return err
 	}
 
@@ -111,6 +112,16 @@ func (s *SCP) run(cmd string) error {
 	return nil
 }
 
+// shellQuote quote the remote path for the shell of the remote host, the leading `~/` is kept unquoted
+// to expand to the home directory
+func shellQuote(remotePath string) string {
+	var home string
+	if strings.HasPrefix(remotePath, "~/") {
+		home, remotePath = "~/", strings.TrimPrefix(remotePath, "~/")
+	}
+	return home + "'" + strings.ReplaceAll(remotePath, "'", `'\''`) + "'"
+}
+
 func (s *SCP) close() {
 	s.client.Close()
 }
@@ -134,7 +145,7 @@ func (s *SCP) upload(fileKey string) error {
 		remotePath := filepath.Join(s.path, key)
 
 		// mkdir
-		if err := s.run(fmt.Sprintf("mkdir -p %s", filepath.Dir(remotePath))); err != nil {
+		if err := s.run("mkdir -p " + shellQuote(filepath.Dir(remotePath))); err != nil {
 			return err
 		}
 
@@ -166,7 +177,7 @@ func (s *SCP) up(localPath, remotePath string) error {
 	}
 	defer file.Close()
 
-	progress := helper.NewProgressBar(logger, file)
+	progress := helper.NewProgressBar(logger, file, s.limiters()...)
 	if err := client.CopyFile(context.Background(), progress.Reader, remotePath, "0644"); err != nil {
 		return progress.Errorf("store %s failed: %v", remotePath, err)
 	}
@@ -184,7 +195,7 @@ func (s *SCP) delete(fileKey string) (err error) {
 	if strings.HasSuffix(fileKey, "/") {
 		rmCmd = "rmdir"
 	}
-	if err := s.run(fmt.Sprintf("%s %s", rmCmd, remotePath)); err != nil {
+	if err := s.run(rmCmd + " " + shellQuote(remotePath)); err != nil {
 		return err
 	}
 
@@ -276,3 +287,54 @@ func (s *SCP) list(parent string) ([]FileItem, error) {
 func (s *SCP) download(fileKey string) (string, error) {
 	return "", fmt.Errorf("SCP not support download")
 }
+
+func (s *SCP) fetch(fileKey string, localPath string) error {
+	remotePath := path.Join(s.path, fileKey)
+
+	client, err := scp.NewClientBySSH(s.client)
+	if err != nil {
+		return err
+	}
+	if err := client.Connect(); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	f, err := createLocalFile(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := client.CopyFromRemote(context.Background(), f, remotePath); err != nil {
+		return fmt.Errorf("fetch %s failed: %v", remotePath, err)
+	}
+
+	return nil
+}
+
+// uploadStream pipe the stream into `cat` on the remote host, scp requires the file size in advance
+func (s *SCP) uploadStream(fileKey string, r io.Reader) error {
+	logger := logger.Tag("SCP")
+
+	remotePath := path.Join(s.path, fileKey)
+	// The chunks and the locks of the repository are in sub directories
+	if err := s.run("mkdir -p " + shellQuote(path.Dir(remotePath))); err != nil {
+		return err
+	}
+
+	session, err := s.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create session: %v", err)
+	}
+	defer session.Close()
+
+	session.Stdin = r
+	logger.Info("-> upload (stream) to", remotePath)
+	if err := session.Run("cat > " + shellQuote(remotePath)); err != nil {
+		return fmt.Errorf("store %s failed: %v", remotePath, err)
+	}
+	logger.Infof("Store %s succeeded", remotePath)
+
+	return nil
+}
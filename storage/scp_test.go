@@ -0,0 +1,14 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/longbridgeapp/assert"
+)
+
+func Test_shellQuote(t *testing.T) {
+	assert.Equal(t, "'/backups/foo.tar.gz'", shellQuote("/backups/foo.tar.gz"))
+	assert.Equal(t, `'/backups/it'\''s.tar.gz'`, shellQuote("/backups/it's.tar.gz"))
+	assert.Equal(t, `'/backups/$(rm -rf ~)'`, shellQuote("/backups/$(rm -rf ~)"))
+	assert.Equal(t, "~/'backups/locks'", shellQuote("~/backups/locks"))
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 
@@ -94,8 +95,8 @@ func (s *SFTP) upload(fileKey string) error {
 		// 2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000
 		fileKeys = s.fileKeys
 
-		remotePath := filepath.Join(s.path, fileKey)
-		remoteDir := filepath.Dir(remotePath)
+		// 2022.12.04.07.09.47
+		remoteDir := filepath.Join(s.path, fileKey)
 
 		// mkdir
 		if err := s.client.MkdirAll(remoteDir); err != nil {
@@ -130,23 +131,61 @@ func (s *SFTP) up(localPath, remotePath string) error {
 	}
 	defer file.Close()
 
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Append to the remote file of the unfinished upload
+	var offset int64
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	state := s.loadUploadState(remotePath, info)
+	if state.resumed {
+		if remoteInfo, err := s.client.Stat(remotePath); err == nil && remoteInfo.Size() <= info.Size() {
+			offset = remoteInfo.Size()
+			flags = os.O_WRONLY | os.O_CREATE
+			logger.Infof("Resume upload of %s from %s", remotePath, humanize.Bytes(uint64(offset)))
+		}
+	}
+	if err := state.save(); err != nil {
+		return err
+	}
+
 	logger.Info("-> upload to", remotePath)
-	remoteFile, err := s.client.OpenFile(remotePath, (os.O_WRONLY | os.O_CREATE | os.O_TRUNC))
+	remoteFile, err := s.client.OpenFile(remotePath, flags)
 	if err != nil {
 		logger.Errorf("Unable to open remote file %s: %v", remotePath, err)
 		return err
 	}
 	defer remoteFile.Close()
 
-	if _, err := io.Copy(remoteFile, file); err != nil {
+	if offset > 0 {
+		if _, err := remoteFile.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.Copy(remoteFile, helper.LimitReader(file, s.limiters()...)); err != nil {
 		logger.Errorf("Unable to upload local file %s: %v", localPath, err)
 		return err
 	}
+	state.remove()
 	logger.Infof("Store %s succeeded", remotePath)
 
 	return nil
 }
 
+// abortUpload remove the partial remote file of the unfinished upload
+func (s *SFTP) abortUpload(state *uploadState) error {
+	if err := s.client.Remove(state.RemotePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 func (s *SFTP) delete(fileKey string) error {
 	logger := logger.Tag("SFTP")
 
@@ -163,18 +202,26 @@ func (s *SFTP) list(parent string) ([]FileItem, error) {
 	remotePath := path.Join(s.path, parent)
 	var items []FileItem
 
-	fileInfos, err := s.client.ReadDir(remotePath)
-	if err != nil {
-		return nil, err
-	}
-	for _, fileInfo := range fileInfos {
-		if !fileInfo.IsDir() {
-			items = append(items, FileItem{
-				Filename:     fileInfo.Name(),
-				Size:         fileInfo.Size(),
-				LastModified: fileInfo.ModTime(),
-			})
+	walker := s.client.Walk(remotePath)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return nil, err
+		}
+
+		fileInfo := walker.Stat()
+		if fileInfo.IsDir() {
+			continue
 		}
+
+		key, ok := relativeKey(s.path, walker.Path())
+		if !ok {
+			continue
+		}
+		items = append(items, FileItem{
+			Filename:     key,
+			Size:         fileInfo.Size(),
+			LastModified: fileInfo.ModTime(),
+		})
 	}
 
 	return items, nil
@@ -183,3 +230,46 @@ func (s *SFTP) list(parent string) ([]FileItem, error) {
 func (s *SFTP) download(fileKey string) (string, error) {
 	return "", fmt.Errorf("SFTP not support download")
 }
+
+func (s *SFTP) fetch(fileKey string, localPath string) error {
+	remotePath := path.Join(s.path, fileKey)
+
+	remoteFile, err := s.client.Open(remotePath)
+	if err != nil {
+		return fmt.Errorf("unable to open remote file %s: %v", remotePath, err)
+	}
+	defer remoteFile.Close()
+
+	f, err := createLocalFile(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, remoteFile)
+	return err
+}
+
+func (s *SFTP) uploadStream(fileKey string, r io.Reader) error {
+	logger := logger.Tag("SFTP")
+
+	remotePath := path.Join(s.path, fileKey)
+	// The chunks and the locks of the repository are in sub directories
+	if err := s.client.MkdirAll(path.Dir(remotePath)); err != nil {
+		return fmt.Errorf("unable to create remote dir %s: %v", path.Dir(remotePath), err)
+	}
+
+	logger.Info("-> upload (stream) to", remotePath)
+	remoteFile, err := s.client.OpenFile(remotePath, (os.O_WRONLY | os.O_CREATE | os.O_TRUNC))
+	if err != nil {
+		return fmt.Errorf("unable to open remote file %s: %v", remotePath, err)
+	}
+	defer remoteFile.Close()
+
+	if _, err := io.Copy(remoteFile, r); err != nil {
+		return err
+	}
+	logger.Infof("Store %s succeeded", remotePath)
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/pkg/sftp"
+)
+
+// newTestSFTPClient connect a client to an in-process SFTP server through pipes
+func newTestSFTPClient(t *testing.T) *sftp.Client {
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverReader, serverWriter})
+	assert.NoError(t, err)
+	go server.Serve() //nolint:errcheck
+
+	client, err := sftp.NewClientPipe(clientReader, clientWriter)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+
+	return client
+}
+
+func TestSFTP_fetch(t *testing.T) {
+	remoteDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(remoteDir, "foo.tar.gz"), []byte("hello"), 0660))
+
+	s := &SFTP{
+		path:   remoteDir,
+		client: newTestSFTPClient(t),
+	}
+
+	localPath := filepath.Join(t.TempDir(), "foo.tar.gz")
+	assert.NoError(t, s.fetch("foo.tar.gz", localPath))
+
+	data, err := os.ReadFile(localPath)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), "hello")
+
+	assert.Error(t, s.fetch("not-found.tar.gz", localPath))
+}
+
+func TestSFTP_list(t *testing.T) {
+	remoteDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(remoteDir, "2022.12.04.07.09.47"), 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(remoteDir, "2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000"), []byte("hello"), 0660))
+	assert.NoError(t, os.WriteFile(filepath.Join(remoteDir, "foo.tar.gz"), []byte("hello"), 0660))
+
+	s := &SFTP{
+		path:   remoteDir,
+		client: newTestSFTPClient(t),
+	}
+
+	items, err := s.list("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000", "foo.tar.gz"}, fileItemNames(items))
+}
+
+func TestSFTP_up_resume(t *testing.T) {
+	uploadsPath = t.TempDir()
+
+	localPath := filepath.Join(t.TempDir(), "foo.tar.gz")
+	assert.NoError(t, os.WriteFile(localPath, []byte("hello world"), 0660))
+	info, err := os.Stat(localPath)
+	assert.NoError(t, err)
+
+	// The previous upload died after 5 bytes
+	remotePath := filepath.Join(t.TempDir(), "foo.tar.gz")
+	assert.NoError(t, os.WriteFile(remotePath, []byte("hello"), 0660))
+
+	s := &SFTP{
+		Base:   Base{cycler: &Cycler{name: "test_sftp"}},
+		client: newTestSFTPClient(t),
+	}
+	state := s.loadUploadState(remotePath, info)
+	assert.NoError(t, state.save())
+
+	assert.NoError(t, s.up(localPath, remotePath))
+
+	data, err := os.ReadFile(remotePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+
+	_, err = os.Stat(state.fileName)
+	assert.True(t, os.IsNotExist(err))
+
+	// Without the state the remote file is overwritten
+	assert.NoError(t, os.WriteFile(remotePath, []byte("HELLO"), 0660))
+	assert.NoError(t, s.up(localPath, remotePath))
+	data, err = os.ReadFile(remotePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+}
@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/logger"
+)
+
+var (
+	// 2022.12.04.07.09.47.tar.xz, see compressor archiveFilePath
+	packageNameRegexp = regexp.MustCompile(`^\d{4}\.\d{2}\.\d{2}\.\d{2}\.\d{2}\.\d{2}`)
+)
+
+// SyncResult is the difference between the cycler state and the remote files of a storage
+type SyncResult struct {
+	Storage string
+	// Packages in the storage but not tracked by the cycler
+	Orphaned PackageList
+	// Packages tracked by the cycler but missing (or partially missing) in the storage
+	Missing PackageList
+}
+
+// Sync reconcile the cycler state of all storages of the model with the remote files.
+// Orphaned packages are added to the cycler and missing packages are removed from it,
+// with `dryRun` the cycler state is only compared and left unchanged.
+func Sync(model config.ModelConfig, dryRun bool) ([]SyncResult, error) {
+	var results []SyncResult
+	var errors []error
+
+	names := make([]string, 0, len(model.Storages))
+	for name := range model.Storages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		storageConfig := model.Storages[name]
+		result, err := syncStorage(model, storageConfig, dryRun)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("[%s] %v", name, err))
+			continue
+		}
+		result.Storage = name
+		results = append(results, result)
+	}
+
+	if len(errors) != 0 {
+		return results, fmt.Errorf("Sync errors: %v", errors)
+	}
+
+	return results, nil
+}
+
+func syncStorage(model config.ModelConfig, storageConfig config.SubConfig, dryRun bool) (SyncResult, error) {
+	base, s, err := new(model, "", storageConfig)
+	if err != nil {
+		return SyncResult{}, err
+	}
+
+	if err := base.openStorage(s); err != nil {
+		return SyncResult{}, err
+	}
+	defer s.close()
+
+	return syncCycler(base, s, dryRun)
+}
+
+// syncCycler reconcile the cycler of the opened storage with the remote files
+func syncCycler(base Base, s Storage, dryRun bool) (result SyncResult, err error) {
+	logger := logger.Tag("Cycler")
+
+	items, err := s.list("")
+	if err != nil {
+		return result, fmt.Errorf("list remote files failed: %v", err)
+	}
+
+	cyclerFileName := base.cycler.fileName()
+	base.cycler.load(cyclerFileName)
+
+	packages, orphaned, missing, sidecars := reconcile(base.cycler.packages, items)
+	result.Orphaned, result.Missing = orphaned, missing
+
+	// Recover the checksum from the sidecar
+	if len(sidecars) > 0 && !dryRun {
+		tempDir, err := os.MkdirTemp("", "launch-agent-sync")
+		if err != nil {
+			return result, err
+		}
+		defer os.RemoveAll(tempDir)
+
+		for i := range packages {
+			if len(packages[i].Checksum) > 0 || !sidecars[strings.TrimSuffix(packages[i].FileKey, "/")] {
+				continue
+			}
+			checksum, err := fetchSidecar(s, packages[i], tempDir)
+			if err != nil {
+				logger.Warnf("Read checksum of %s failed: %v", packages[i].FileKey, err)
+				continue
+			}
+			packages[i].Checksum = checksum
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	base.cycler.packages = packages
+	base.cycler.save(cyclerFileName)
+	logger.Infof("Synced %s: %d packages, %d orphaned, %d missing", base.cycler.name, len(packages), len(orphaned), len(missing))
+
+	return result, nil
+}
+
+// syncIfNeeded reconcile the cycler with the remote files when the state file is missing, empty or unreadable,
+// e.g. it is lost after the host has been rebuilt, otherwise `keep` never removes the backups before. With the
+// `fileKey` looked up by download or verify, the state which does not track it is also reconciled. The remote
+// is not listed when the state is consistent, the backups removed by hand or by a lifecycle rule are reconciled
+// by `cycler sync`. The storages without list, e.g. SCP, are left as is.
+func syncIfNeeded(base Base, s Storage, fileKey string) {
+	logger := logger.Tag("Cycler")
+
+	var reason string
+	cycler := Cycler{name: base.cycler.name}
+	data, err := os.ReadFile(cycler.fileName())
+	switch {
+	case os.IsNotExist(err):
+		reason = "not found"
+	case err != nil:
+		reason = fmt.Sprintf("unreadable: %v", err)
+	default:
+		if err := json.Unmarshal(data, &cycler.packages); err != nil {
+			reason = fmt.Sprintf("unreadable: %v", err)
+		} else if len(cycler.packages) == 0 {
+			reason = "empty"
+		} else if len(fileKey) > 0 && cycler.find(fileKey) == nil {
+			reason = fmt.Sprintf("does not track %s", fileKey)
+		}
+	}
+	if len(reason) == 0 {
+		return
+	}
+
+	logger.Infof("State of %s %s, reconcile with remote", base.cycler.name, reason)
+	if _, err := syncCycler(base, s, false); err != nil {
+		logger.Warnf("Reconcile %s failed: %v", base.cycler.name, err)
+	}
+}
+
+// reconcile compare the cycler packages with the remote files, return the reconciled packages
+// sorted by `CreatedAt`, the orphaned and missing packages, and the file keys which have a sidecar.
+func reconcile(state PackageList, items []FileItem) (packages, orphaned, missing PackageList, sidecars map[string]bool) {
+	remoteKeys := map[string]bool{}
+	for _, item := range items {
+		remoteKeys[item.Filename] = true
+	}
+
+	remote, sidecars := remotePackages(items)
+
+	tracked := map[string]bool{}
+	for _, pkg := range state {
+		tracked[strings.TrimSuffix(pkg.FileKey, "/")] = true
+
+		exists := remoteKeys[pkg.FileKey]
+		if len(pkg.FileKeys) > 0 {
+			exists = true
+			for _, key := range pkg.FileKeys {
+				if !remoteKeys[key] {
+					exists = false
+					break
+				}
+			}
+		}
+
+		if exists {
+			packages = append(packages, pkg)
+		} else {
+			missing = append(missing, pkg)
+		}
+	}
+
+	for _, pkg := range remote {
+		if !tracked[pkg.FileKey] {
+			orphaned = append(orphaned, pkg)
+			packages = append(packages, pkg)
+		}
+	}
+
+	sort.SliceStable(packages, func(i, j int) bool {
+		return packages[i].CreatedAt.Before(packages[j].CreatedAt)
+	})
+
+	return
+}
+
+// remotePackages build the packages from the remote files, the files not created by backup are ignored
+//
+//	2022.12.04.07.09.47.tar.xz
+//	2022.12.04.07.09.47.tar.xz.sha256
+//	2022.12.04.07.09.48/2022.12.04.07.09.48.tar.xz-000
+//	2022.12.04.07.09.48/2022.12.04.07.09.48.tar.xz-001
+//	2022.12.04.07.09.48.sha256
+func remotePackages(items []FileItem) (packages PackageList, sidecars map[string]bool) {
+	sidecars = map[string]bool{}
+	parts := map[string]*Package{}
+
+	for _, item := range items {
+		dir, name := path.Split(item.Filename)
+		dir = strings.TrimSuffix(dir, "/")
+		if !packageNameRegexp.MatchString(name) {
+			continue
+		}
+
+		switch {
+		case strings.Contains(dir, "/"):
+			continue
+		case len(dir) > 0:
+			if !packageNameRegexp.MatchString(dir) || !partSuffixRegexp.MatchString(name) {
+				continue
+			}
+			pkg, ok := parts[dir]
+			if !ok {
+				pkg = &Package{FileKey: dir, CreatedAt: packageTime(dir, item.LastModified)}
+				parts[dir] = pkg
+			}
+			pkg.FileKeys = append(pkg.FileKeys, item.Filename)
+		case strings.HasSuffix(name, ".sha256"):
+			sidecars[strings.TrimSuffix(name, ".sha256")] = true
+		default:
+			packages = append(packages, Package{FileKey: name, CreatedAt: packageTime(name, item.LastModified)})
+		}
+	}
+
+	for _, pkg := range parts {
+		sort.Strings(pkg.FileKeys)
+		packages = append(packages, *pkg)
+	}
+
+	sort.SliceStable(packages, func(i, j int) bool {
+		return packages[i].CreatedAt.Before(packages[j].CreatedAt)
+	})
+
+	return
+}
+
+// packageTime parse the creation time from the package name, fallback to the modified time
+func packageTime(name string, lastModified time.Time) time.Time {
+	t, err := time.ParseInLocation("2006.01.02.15.04.05", packageNameRegexp.FindString(name), time.Local)
+	if err != nil {
+		return lastModified
+	}
+	return t
+}
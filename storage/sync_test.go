@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
+)
+
+func fileItemNames(items []FileItem) (names []string) {
+	for _, item := range items {
+		names = append(names, item.Filename)
+	}
+	sort.Strings(names)
+	return
+}
+
+func TestRemotePackages(t *testing.T) {
+	modTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	packages, sidecars := remotePackages([]FileItem{
+		{Filename: "2022.12.04.07.09.49.tar.xz"},
+		{Filename: "2022.12.04.07.09.48/2022.12.04.07.09.48.tar.xz-001"},
+		{Filename: "2022.12.04.07.09.48/2022.12.04.07.09.48.tar.xz-000"},
+		{Filename: "2022.12.04.07.09.48.sha256"},
+		{Filename: "2022.12.04.07.09.47.tar.xz"},
+		{Filename: "2022.12.04.07.09.47.tar.xz.sha256"},
+		{Filename: "README.md", LastModified: modTime},
+		{Filename: "other/2022.12.04.07.09.47.tar.xz"},
+	})
+
+	assert.Equal(t, []string{"2022.12.04.07.09.47.tar.xz", "2022.12.04.07.09.48", "2022.12.04.07.09.49.tar.xz"}, fileKeys(packages))
+	assert.Equal(t, []string{
+		"2022.12.04.07.09.48/2022.12.04.07.09.48.tar.xz-000",
+		"2022.12.04.07.09.48/2022.12.04.07.09.48.tar.xz-001",
+	}, packages[1].FileKeys)
+	assert.Equal(t, time.Date(2022, 12, 4, 7, 9, 47, 0, time.Local), packages[0].CreatedAt)
+	assert.Equal(t, map[string]bool{"2022.12.04.07.09.47.tar.xz": true, "2022.12.04.07.09.48": true}, sidecars)
+}
+
+func TestReconcile(t *testing.T) {
+	state := PackageList{
+		{FileKey: "2022.12.04.07.09.47.tar.xz", Checksum: "abc", CreatedAt: time.Date(2022, 12, 4, 7, 9, 47, 0, time.Local)},
+		{FileKey: "2022.12.04.07.09.48/", FileKeys: []string{
+			"2022.12.04.07.09.48/2022.12.04.07.09.48.tar.xz-000",
+			"2022.12.04.07.09.48/2022.12.04.07.09.48.tar.xz-001",
+		}},
+		{FileKey: "2022.12.04.07.09.50.tar.xz"},
+	}
+
+	packages, orphaned, missing, _ := reconcile(state, []FileItem{
+		{Filename: "2022.12.04.07.09.46.tar.xz"},
+		{Filename: "2022.12.04.07.09.47.tar.xz"},
+		{Filename: "2022.12.04.07.09.48/2022.12.04.07.09.48.tar.xz-000"},
+	})
+
+	assert.Equal(t, []string{"2022.12.04.07.09.46.tar.xz", "2022.12.04.07.09.47.tar.xz"}, fileKeys(packages))
+	assert.Equal(t, "abc", packages[1].Checksum)
+	assert.Equal(t, []string{"2022.12.04.07.09.46.tar.xz"}, fileKeys(orphaned))
+	assert.Equal(t, []string{"2022.12.04.07.09.48/", "2022.12.04.07.09.50.tar.xz"}, fileKeys(missing))
+}
+
+func TestSync(t *testing.T) {
+	cyclerPath = t.TempDir()
+
+	storagePath := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(storagePath, "2022.12.04.07.09.47.tar.xz"), []byte("hello"), 0660))
+	assert.NoError(t, os.WriteFile(filepath.Join(storagePath, "2022.12.04.07.09.47.tar.xz.sha256"), []byte("abc  2022.12.04.07.09.47.tar.xz\n"), 0660))
+	assert.NoError(t, os.WriteFile(filepath.Join(storagePath, "2022.12.04.07.09.48.tar.xz"), []byte("world"), 0660))
+
+	viper := viper.New()
+	viper.Set("path", storagePath)
+	model := config.ModelConfig{
+		Name: "test",
+		Storages: map[string]config.SubConfig{
+			"local": {Name: "local", Type: "local", Viper: viper},
+		},
+	}
+
+	results, err := Sync(model, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "local", results[0].Storage)
+	assert.Equal(t, 2, len(results[0].Orphaned))
+
+	// Dry run does not touch the state
+	cycler := Cycler{name: "test_local"}
+	assert.Equal(t, 0, len(loadPackages(t, cycler)))
+
+	_, err = Sync(model, false)
+	assert.NoError(t, err)
+	packages := loadPackages(t, cycler)
+	assert.Equal(t, []string{"2022.12.04.07.09.47.tar.xz", "2022.12.04.07.09.48.tar.xz"}, fileKeys(packages))
+	assert.Equal(t, "abc", packages[0].Checksum)
+
+	assert.NoError(t, os.Remove(filepath.Join(storagePath, "2022.12.04.07.09.48.tar.xz")))
+	results, err = Sync(model, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(results[0].Orphaned))
+	assert.Equal(t, []string{"2022.12.04.07.09.48.tar.xz"}, fileKeys(results[0].Missing))
+	assert.Equal(t, []string{"2022.12.04.07.09.47.tar.xz"}, fileKeys(loadPackages(t, cycler)))
+}
+
+// listCounter count the listings of the storage
+type listCounter struct {
+	Storage
+	lists int
+}
+
+func (c *listCounter) list(parent string) ([]FileItem, error) {
+	c.lists++
+	return c.Storage.list(parent)
+}
+
+func TestSyncIfNeeded(t *testing.T) {
+	cyclerPath = t.TempDir()
+
+	local := newTestLocal(t)
+	local.cycler = &Cycler{name: "test_local"}
+	s := &listCounter{Storage: local}
+	assert.NoError(t, os.WriteFile(filepath.Join(local.path, "2022.12.04.07.09.47.tar.xz"), []byte("hello"), 0660))
+	assert.NoError(t, os.WriteFile(filepath.Join(local.path, "2022.12.04.07.09.48.tar.xz"), []byte("world"), 0660))
+
+	// The state file is lost, keep still removes the old backups
+	var deleted []string
+	deleteFn := func(fileKey string) error {
+		deleted = append(deleted, fileKey)
+		return os.Remove(filepath.Join(local.path, fileKey))
+	}
+	syncIfNeeded(local.Base, s, "")
+	assert.Equal(t, 1, s.lists)
+	local.cycler.run(Package{FileKey: "2022.12.04.07.09.48.tar.xz"}, 2, Retention{}, deleteFn)
+	assert.Equal(t, 0, len(deleted))
+	assert.Equal(t, []string{"2022.12.04.07.09.47.tar.xz", "2022.12.04.07.09.48.tar.xz"}, fileKeys(local.cycler.packages))
+
+	// The remote is not listed while the state is consistent, even when a backup has been copied in by hand
+	assert.NoError(t, os.WriteFile(filepath.Join(local.path, "2022.12.04.07.09.46.tar.xz"), []byte("old"), 0660))
+	syncIfNeeded(local.Base, s, "")
+	syncIfNeeded(local.Base, s, "2022.12.04.07.09.48.tar.xz")
+	assert.Equal(t, 1, s.lists)
+	assert.Equal(t, []string{"2022.12.04.07.09.47.tar.xz", "2022.12.04.07.09.48.tar.xz"}, fileKeys(loadPackages(t, *local.cycler)))
+
+	// The package looked up is not tracked
+	syncIfNeeded(local.Base, s, "2022.12.04.07.09.46.tar.xz")
+	assert.Equal(t, 2, s.lists)
+	synced := fileKeys(loadPackages(t, *local.cycler))
+	sort.Strings(synced)
+	assert.Equal(t, []string{"2022.12.04.07.09.46.tar.xz", "2022.12.04.07.09.47.tar.xz", "2022.12.04.07.09.48.tar.xz"}, synced)
+
+	// The unreadable state is rebuilt
+	assert.NoError(t, os.WriteFile(local.cycler.fileName(), []byte("{"), 0660))
+	syncIfNeeded(local.Base, s, "")
+	assert.Equal(t, 3, s.lists)
+	assert.Equal(t, 3, len(loadPackages(t, *local.cycler)))
+}
+
+func loadPackages(t *testing.T, cycler Cycler) PackageList {
+	cycler.packages = nil
+	cycler.load(cycler.fileName())
+	return cycler.packages
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/gigcodes/launch-util/logger"
+)
+
+// md5Storage is implemented by the storages which expose the MD5 of the remote object,
+// an empty MD5 is returned when the object has no plain MD5, e.g. multipart uploads.
+type md5Storage interface {
+	remoteMD5(fileKey string) (string, error)
+}
+
+// Verify check the package `fileKey` in all storages of the model against the SHA-256 recorded
+// at backup time, the latest package of the default storage is used when `fileKey` is empty.
+//
+// The package in the default storage is always fetched into `targetDir` and the local archive
+// path is returned for checking the content. The other storages are compared with the remote
+// MD5 when the storage exposes one, otherwise the package is fetched and hashed too.
+func Verify(model config.ModelConfig, fileKey string, targetDir string) (archivePath string, err error) {
+	if _, ok := model.Storages[model.DefaultStorage]; !ok {
+		return "", fmt.Errorf("default storage %s not found in model %s", model.DefaultStorage, model.Name)
+	}
+
+	// Verify the default storage first to resolve the latest file key
+	names := []string{model.DefaultStorage}
+	for name := range model.Storages {
+		if name != model.DefaultStorage {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+
+	var errors []error
+	for i, name := range names {
+		isDefault := i == 0
+		dir := filepath.Join(targetDir, name)
+
+		localPath, pkg, err := verifyStorage(model, model.Storages[name], fileKey, dir, isDefault)
+		if err != nil {
+			if isDefault {
+				return "", err
+			}
+			errors = append(errors, err)
+			continue
+		}
+
+		if isDefault {
+			fileKey, archivePath = pkg.FileKey, localPath
+		}
+	}
+
+	if len(errors) != 0 {
+		return "", fmt.Errorf("Verify errors: %v", errors)
+	}
+
+	return archivePath, nil
+}
+
+// verifyStorage verify the package in one storage, return the local archive path when it has been fetched
+func verifyStorage(model config.ModelConfig, storageConfig config.SubConfig, fileKey string, targetDir string, fetch bool) (string, *Package, error) {
+	logger := logger.Tag("Verify")
+
+	base, s, err := new(model, "", storageConfig)
+	if err != nil {
+		return "", nil, err
+	}
+
+	logger.Info("=> Storage | " + storageConfig.Type)
+	if err := base.openStorage(s); err != nil {
+		return "", nil, err
+	}
+	defer s.close()
+
+	syncIfNeeded(base, s, fileKey)
+	base.cycler.load(base.cycler.fileName())
+	pkg := base.cycler.find(fileKey)
+	if pkg == nil {
+		if len(fileKey) == 0 {
+			return "", nil, fmt.Errorf("no backup found for %s in cycler", base.cycler.name)
+		}
+		// Not tracked by cycler, assume it is a single file and rely on the sidecar
+		pkg = &Package{FileKey: fileKey}
+	}
+
+	if err := helper.MkdirP(targetDir); err != nil {
+		return "", nil, err
+	}
+
+	checksum := pkg.Checksum
+	if len(checksum) == 0 {
+		var err error
+		checksum, err = fetchSidecar(s, *pkg, targetDir)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	if ms, ok := s.(md5Storage); ok && !fetch && len(pkg.FileKeys) == 0 && len(pkg.MD5) > 0 {
+		remoteMD5, err := ms.remoteMD5(pkg.FileKey)
+		if err != nil {
+			return "", nil, fmt.Errorf("[%s] get MD5 of %s failed: %v", storageConfig.Name, pkg.FileKey, err)
+		}
+		if len(remoteMD5) > 0 {
+			if remoteMD5 != pkg.MD5 {
+				return "", nil, fmt.Errorf("[%s] MD5 mismatch of %s, expected %s but got %s", storageConfig.Name, pkg.FileKey, pkg.MD5, remoteMD5)
+			}
+			logger.Info("MD5 matched", pkg.FileKey)
+			return "", pkg, nil
+		}
+	}
+
+	localPath, err := fetchPackage(s, *pkg, targetDir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	archivePath := localPath
+	if len(pkg.FileKeys) != 0 {
+		archivePath = filepath.Join(targetDir, archiveName(*pkg))
+		if err := joinParts(targetDir, pkg.FileKeys, archivePath); err != nil {
+			return "", nil, err
+		}
+		if err := os.RemoveAll(localPath); err != nil {
+			logger.Warnf("Remove %s failed: %v", localPath, err)
+		}
+	}
+
+	sum, _, err := helper.FileChecksum(archivePath)
+	if err != nil {
+		return "", nil, err
+	}
+	if sum != checksum {
+		return "", nil, fmt.Errorf("[%s] SHA-256 mismatch of %s, expected %s but got %s", storageConfig.Name, pkg.FileKey, checksum, sum)
+	}
+	logger.Info("SHA-256 matched", pkg.FileKey)
+
+	if !fetch {
+		if err := os.RemoveAll(archivePath); err != nil {
+			logger.Warnf("Remove %s failed: %v", archivePath, err)
+		}
+		return "", pkg, nil
+	}
+
+	return archivePath, pkg, nil
+}
+
+// fetchSidecar read the SHA-256 from the `.sha256` sidecar of the package
+func fetchSidecar(s Storage, pkg Package, targetDir string) (string, error) {
+	key := sidecarKey(pkg.FileKey)
+	localPath := filepath.Join(targetDir, key)
+	if err := fetchFile(s, key, localPath); err != nil {
+		return "", fmt.Errorf("no checksum recorded for %s: %v", pkg.FileKey, err)
+	}
+	defer os.Remove(localPath)
+
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return "", err
+	}
+
+	// <sha256>  2022.12.04.07.09.47.tar.xz
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("checksum file %s is empty", key)
+	}
+
+	return fields[0], nil
+}
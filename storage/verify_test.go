@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/gigcodes/launch-util/helper"
+	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
+)
+
+func newTestVerifyModel(t *testing.T) config.ModelConfig {
+	cyclerPath = t.TempDir()
+
+	archivePath := filepath.Join(t.TempDir(), "2022.12.04.07.09.47.tar.gz")
+	assert.NoError(t, os.WriteFile(archivePath, []byte("hello world"), 0660))
+
+	modelViper := viper.New()
+	model := config.ModelConfig{
+		Name:           "test",
+		DefaultStorage: "local1",
+		Storages:       map[string]config.SubConfig{},
+		Viper:          modelViper,
+	}
+
+	sha256sum, md5sum, err := helper.FileChecksum(archivePath)
+	assert.NoError(t, err)
+	modelViper.Set("Checksum", sha256sum)
+	modelViper.Set("MD5", md5sum)
+
+	for _, name := range []string{"local1", "local2"} {
+		storageViper := viper.New()
+		storageViper.Set("path", t.TempDir())
+		model.Storages[name] = config.SubConfig{Name: name, Type: "local", Viper: storageViper}
+	}
+
+	_, err = Run(model, archivePath)
+	assert.NoError(t, err)
+	return model
+}
+
+func TestVerify(t *testing.T) {
+	model := newTestVerifyModel(t)
+
+	sidecar, err := os.ReadFile(filepath.Join(model.Storages["local2"].Viper.GetString("path"), "2022.12.04.07.09.47.tar.gz.sha256"))
+	assert.NoError(t, err)
+	assert.Equal(t, "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9  2022.12.04.07.09.47.tar.gz\n", string(sidecar))
+
+	targetDir := t.TempDir()
+	archivePath, err := Verify(model, "", targetDir)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(targetDir, "local1", "2022.12.04.07.09.47.tar.gz"), archivePath)
+
+	data, err := os.ReadFile(archivePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+}
+
+func TestVerify_mismatch(t *testing.T) {
+	model := newTestVerifyModel(t)
+
+	corrupted := filepath.Join(model.Storages["local2"].Viper.GetString("path"), "2022.12.04.07.09.47.tar.gz")
+	assert.NoError(t, os.WriteFile(corrupted, []byte("hello w0rld"), 0660))
+
+	_, err := Verify(model, "2022.12.04.07.09.47.tar.gz", t.TempDir())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "[local2] SHA-256 mismatch")
+}
+
+func TestVerify_sidecar(t *testing.T) {
+	model := newTestVerifyModel(t)
+
+	// The cycler state is lost, the checksum is recovered from the sidecar
+	cyclerPath = t.TempDir()
+	_, err := Verify(model, "", t.TempDir())
+	assert.NoError(t, err)
+
+	sidecar := filepath.Join(model.Storages["local1"].Viper.GetString("path"), "2022.12.04.07.09.47.tar.gz.sha256")
+	assert.NoError(t, os.Remove(sidecar))
+	cyclerPath = t.TempDir()
+	_, err = Verify(model, "2022.12.04.07.09.47.tar.gz", t.TempDir())
+	assert.Error(t, err)
+}
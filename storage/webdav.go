@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -66,8 +67,8 @@ func (s *WebDAV) upload(fileKey string) error {
 		// 2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000
 		fileKeys = s.fileKeys
 
-		remotePath := filepath.Join(s.path, fileKey)
-		remoteDir := filepath.Dir(remotePath)
+		// 2022.12.04.07.09.47
+		remoteDir := filepath.Join(s.path, fileKey)
 
 		// mkdir
 		if err := s.client.MkdirAll(remoteDir, 0644); err != nil {
@@ -89,7 +90,7 @@ func (s *WebDAV) upload(fileKey string) error {
 		}
 		defer f.Close()
 
-		progress := helper.NewProgressBar(logger, f)
+		progress := helper.NewProgressBar(logger, f, s.limiters()...)
 		if err := s.client.WriteStream(remotePath, progress.Reader, 0644); err != nil {
 			return progress.Errorf("upload failed %v", err)
 		}
@@ -118,13 +119,20 @@ func (s *WebDAV) list(parent string) ([]FileItem, error) {
 
 	var items []FileItem
 	for _, entry := range entries {
-		if !entry.IsDir() {
-			items = append(items, FileItem{
-				Filename:     entry.Name(),
-				Size:         entry.Size(),
-				LastModified: entry.ModTime(),
-			})
+		if entry.IsDir() {
+			children, err := s.list(path.Join(parent, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, children...)
+			continue
 		}
+
+		items = append(items, FileItem{
+			Filename:     path.Join(parent, entry.Name()),
+			Size:         entry.Size(),
+			LastModified: entry.ModTime(),
+		})
 	}
 
 	return items, nil
@@ -133,3 +141,35 @@ func (s *WebDAV) list(parent string) ([]FileItem, error) {
 func (s *WebDAV) download(fileKey string) (string, error) {
 	return "", fmt.Errorf("WebDAV not support download")
 }
+
+func (s *WebDAV) fetch(fileKey string, localPath string) error {
+	remotePath := path.Join(s.path, fileKey)
+
+	reader, err := s.client.ReadStream(remotePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	f, err := createLocalFile(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, reader)
+	return err
+}
+
+func (s *WebDAV) uploadStream(fileKey string, r io.Reader) error {
+	logger := logger.Tag("WebDAV")
+
+	remotePath := path.Join(s.path, fileKey)
+	logger.Info("-> Uploading (stream)...")
+	if err := s.client.WriteStream(remotePath, r, 0644); err != nil {
+		return fmt.Errorf("upload failed %v", err)
+	}
+	logger.Info("Store succeeded", remotePath)
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gigcodes/launch-util/config"
+	"github.com/longbridgeapp/assert"
+	"github.com/spf13/viper"
+	"golang.org/x/net/webdav"
+)
+
+func TestWebDAV_fetch(t *testing.T) {
+	server := httptest.NewServer(&webdav.Handler{
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	})
+	defer server.Close()
+
+	viper := viper.New()
+	viper.Set("root", server.URL)
+	viper.Set("path", "backups")
+
+	base, err := newBase(config.ModelConfig{}, "", config.SubConfig{Type: "webdav", Viper: viper})
+	assert.NoError(t, err)
+
+	s := &WebDAV{Base: base}
+	assert.NoError(t, s.open())
+	assert.NoError(t, s.client.Write("backups/foo.tar.gz", []byte("hello"), 0644))
+
+	localPath := filepath.Join(t.TempDir(), "foo.tar.gz")
+	assert.NoError(t, s.fetch("foo.tar.gz", localPath))
+
+	data, err := os.ReadFile(localPath)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), "hello")
+
+	assert.Error(t, s.fetch("not-found.tar.gz", localPath))
+}
+
+func TestWebDAV_list(t *testing.T) {
+	server := httptest.NewServer(&webdav.Handler{
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	})
+	defer server.Close()
+
+	viper := viper.New()
+	viper.Set("root", server.URL)
+	viper.Set("path", "backups")
+
+	base, err := newBase(config.ModelConfig{}, "", config.SubConfig{Type: "webdav", Viper: viper})
+	assert.NoError(t, err)
+
+	s := &WebDAV{Base: base}
+	assert.NoError(t, s.open())
+	assert.NoError(t, s.client.MkdirAll("backups/2022.12.04.07.09.47", 0755))
+	assert.NoError(t, s.client.Write("backups/2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000", []byte("hello"), 0644))
+	assert.NoError(t, s.client.Write("backups/foo.tar.gz", []byte("hello"), 0644))
+
+	items, err := s.list("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2022.12.04.07.09.47/2022.12.04.07.09.47.tar.xz-000", "foo.tar.gz"}, fileItemNames(items))
+}